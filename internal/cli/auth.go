@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	config "wired/internal/config"
+	scrobbler "wired/internal/scrobbler"
+)
+
+var (
+	ErrUnknownService       = errors.New("unknown service, expected: wired auth lastfm")
+	ErrMissingCredentials   = errors.New("set lastfm.api_key and lastfm.api_secret in the config first")
+	ErrInvalidConfiguration = errors.New("the config has errors, run wired to see them")
+)
+
+const authTimeout = 30 * time.Second
+
+// Auth links wired to an account of a scrobbling service and saves the session in the config
+func Auth(args []string) error {
+	if len(args) != 1 || args[0] != "lastfm" {
+		return ErrUnknownService
+	}
+
+	cfg, errs, _ := config.Load()
+	if len(errs) > 0 {
+		return errors.Join(append([]error{ErrInvalidConfiguration}, errs...)...)
+	}
+
+	if cfg.LastFM.APIKey == "" || cfg.LastFM.APISecret == "" {
+		return ErrMissingCredentials
+	}
+
+	lastfm := scrobbler.NewLastFM(cfg.LastFM)
+
+	ctx, cancel := context.WithTimeout(context.Background(), authTimeout)
+	token, err := lastfm.Token(ctx)
+	cancel()
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("allow wired to scrobble to your account by opening:")
+	fmt.Println()
+	fmt.Println("  " + lastfm.AuthURL(token))
+	fmt.Println()
+	fmt.Print("then press enter to continue...")
+
+	if _, err := bufio.NewReader(os.Stdin).ReadString('\n'); err != nil {
+		return err
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), authTimeout)
+	sessionKey, name, err := lastfm.Session(ctx, token)
+	cancel()
+
+	if err != nil {
+		return err
+	}
+
+	cfg.LastFM.Enabled = true
+	if err := cfg.SetAndSaveLastFMSession(sessionKey); err != nil {
+		return err
+	}
+
+	fmt.Printf("scrobbling to last.fm as %s\n", name)
+
+	return nil
+}
@@ -1,4 +1,4 @@
-// Package cli provides terminal utility functions
+// Package cli provides terminal utility functions and the subcommands that run without the ui
 package cli
 
 import (
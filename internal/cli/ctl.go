@@ -0,0 +1,234 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	control "wired/internal/control"
+	playback "wired/internal/playback"
+)
+
+var (
+	ErrUnknownCommand = errors.New("unknown command, run wired ctl help to see them all")
+	ErrBadArgument    = errors.New("bad argument, run wired ctl help for usage")
+)
+
+const ctlUsage = `usage: wired ctl <command>
+
+  play [index]              resume, or play the queue entry at index (from 0)
+  pause                     pause
+  toggle                    play or pause
+  stop                      stop
+  next                      skip to the next track
+  previous                  go back to the previous track
+  seek [+|-]seconds         jump to a position, or relative to the current one
+  volume [[+|-]percent]     set or change the volume, prints it without an argument
+  add [--next] [--play] path...
+                            queue files or stream urls
+  queue [--json]            list the queue, the current entry is marked with >
+  clear                     empty the queue
+  status [--json]           print what's playing
+  subscribe                 print every event as a json line until interrupted`
+
+// Ctl drives a running wired through its control socket
+func Ctl(args []string) error {
+	if len(args) == 0 || args[0] == "help" || args[0] == "--help" || args[0] == "-h" {
+		fmt.Println(ctlUsage)
+		return nil
+	}
+
+	client, err := control.Dial()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	command, args := args[0], args[1:]
+
+	switch command {
+	case "play":
+		var params control.PlayParams
+
+		if len(args) > 0 {
+			index, err := strconv.Atoi(args[0])
+			if err != nil {
+				return ErrBadArgument
+			}
+
+			params.Index = &index
+		}
+
+		return client.Call(control.MethodPlay, params, nil)
+
+	case "pause":
+		return client.Call(control.MethodPause, nil, nil)
+
+	case "toggle":
+		return client.Call(control.MethodToggle, nil, nil)
+
+	case "stop":
+		return client.Call(control.MethodStop, nil, nil)
+
+	case "next":
+		return client.Call(control.MethodNext, nil, nil)
+
+	case "previous", "prev":
+		return client.Call(control.MethodPrevious, nil, nil)
+
+	case "seek":
+		if len(args) != 1 {
+			return ErrBadArgument
+		}
+
+		seconds, err := strconv.ParseFloat(args[0], 64)
+		if err != nil {
+			return ErrBadArgument
+		}
+
+		return client.Call(control.MethodSeek, control.SeekParams{Seconds: seconds, Relative: isRelative(args[0])}, nil)
+
+	case "volume", "vol":
+		var params control.VolumeParams
+
+		if len(args) > 0 {
+			volume, err := strconv.Atoi(args[0])
+			if err != nil {
+				return ErrBadArgument
+			}
+
+			params.Volume = &volume
+			params.Relative = isRelative(args[0])
+		}
+
+		var volume int
+		if err := client.Call(control.MethodVolume, params, &volume); err != nil {
+			return err
+		}
+
+		fmt.Printf("%d%%\n", volume)
+
+		return nil
+
+	case "add":
+		return add(client, args)
+
+	case "status":
+		var status control.Status
+		if err := client.Call(control.MethodStatus, nil, &status); err != nil {
+			return err
+		}
+
+		if len(args) > 0 && args[0] == "--json" {
+			return json.NewEncoder(os.Stdout).Encode(status)
+		}
+
+		fmt.Println(statusLine(status))
+
+		return nil
+
+	case "queue":
+		var queue control.Queue
+		if err := client.Call(control.MethodQueueList, nil, &queue); err != nil {
+			return err
+		}
+
+		if len(args) > 0 && args[0] == "--json" {
+			return json.NewEncoder(os.Stdout).Encode(queue)
+		}
+
+		for index, track := range queue.Tracks {
+			marker := " "
+			if index == queue.Current {
+				marker = ">"
+			}
+
+			fmt.Printf("%s %d. %s\n", marker, index, trackLine(track))
+		}
+
+		return nil
+
+	case "clear":
+		return client.Call(control.MethodQueueClear, nil, nil)
+
+	case "subscribe":
+		encoder := json.NewEncoder(os.Stdout)
+
+		return client.Subscribe(func(event control.Event) bool {
+			return encoder.Encode(event) == nil
+		})
+
+	default:
+		return ErrUnknownCommand
+	}
+}
+
+func add(client *control.Client, args []string) error {
+	var params control.QueueAddParams
+
+	for _, arg := range args {
+		switch arg {
+		case "--next":
+			params.Next = true
+		case "--play":
+			params.Play = true
+		default:
+			// the server runs somewhere else, relative paths mean nothing to it
+			if !playback.IsStream(arg) {
+				path, err := filepath.Abs(arg)
+				if err != nil {
+					return err
+				}
+
+				arg = path
+			}
+
+			params.Paths = append(params.Paths, arg)
+		}
+	}
+
+	if len(params.Paths) == 0 {
+		return ErrBadArgument
+	}
+
+	return client.Call(control.MethodQueueAdd, params, nil)
+}
+
+func isRelative(arg string) bool {
+	return strings.HasPrefix(arg, "+") || strings.HasPrefix(arg, "-")
+}
+
+// statusLine is short enough for a status bar
+func statusLine(status control.Status) string {
+	if status.Track == nil {
+		return status.State
+	}
+
+	title := trackLine(*status.Track)
+
+	position := time.Duration(status.Position * float64(time.Second)).Truncate(time.Second)
+	if status.Duration == 0 {
+		return fmt.Sprintf("[%s] %s (%s)", status.State, title, position)
+	}
+
+	duration := time.Duration(status.Duration * float64(time.Second)).Truncate(time.Second)
+
+	return fmt.Sprintf("[%s] %s (%s / %s)", status.State, title, position, duration)
+}
+
+func trackLine(track control.Track) string {
+	if track.StreamTitle != "" {
+		return track.StreamTitle
+	}
+
+	if track.Artist != "" {
+		return track.Artist + " - " + track.Title
+	}
+
+	return track.Title
+}
@@ -2,11 +2,14 @@
 package config
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
-	"regexp"
+	"reflect"
+	"slices"
 	"strings"
 
 	"github.com/pelletier/go-toml/v2"
@@ -27,44 +30,160 @@ type Notification struct {
 	NotificationStackMax     int `toml:"notification_stack_max"`
 }
 
+type Covers struct {
+	MinImageBytes int `toml:"min_image_bytes"`
+	// auto picks kitty or sixel when the terminal supports them, falling back to halfblocks
+	Protocol string `toml:"protocol"`
+}
+
+type LastFM struct {
+	Enabled   bool   `toml:"enabled"`
+	APIKey    string `toml:"api_key"`
+	APISecret string `toml:"api_secret"`
+	// filled in by `wired auth lastfm`
+	SessionKey string `toml:"session_key"`
+	Endpoint   string `toml:"endpoint"`
+	AuthURL    string `toml:"auth_url"`
+}
+
+type ListenBrainz struct {
+	Enabled bool `toml:"enabled"`
+	// from https://listenbrainz.org/settings/
+	Token    string `toml:"token"`
+	Endpoint string `toml:"endpoint"`
+}
+
+type Radio struct {
+	Enabled     bool   `toml:"enabled"`
+	BindAddress string `toml:"bind_address"`
+	Port        int    `toml:"port"`
+	Mount       string `toml:"mount"`
+	Format      string `toml:"format"`  // mp3 or ogg through ffmpeg, wav only where bandwidth doesn't matter
+	Bitrate     int    `toml:"bitrate"` // kbps, wav ignores it
+}
+
+// MPD has no passwords, anyone who can reach the address can drive wired
+type MPD struct {
+	Enabled     bool   `toml:"enabled"`
+	BindAddress string `toml:"bind_address"`
+	Port        int    `toml:"port"`
+}
+
+type MPRIS struct {
+	Enabled bool `toml:"enabled"`
+}
+
+// Station is an internet radio that can be opened by name
+type Station struct {
+	Name string `toml:"name"`
+	URL  string `toml:"url"`
+}
+
+// ColorPalette is every color of the ui. In the config these override single colors of the theme,
+// once loaded it's the theme with the overrides on top
 type ColorPalette struct {
-	Border              string `toml:"border"`
-	TextInactive        string `toml:"text_inactive"`
-	CursorForeground    string `toml:"cursor_fg"`
-	NotificationInfo    string `toml:"notification_info"`
-	NotificationError   string `toml:"notification_error"`
-	NotificationSuccess string `toml:"notification_success"`
-	HeaderActiveBg      string `toml:"header_active_bg"`
-	HeaderActiveFg      string `toml:"header_active_fg"`
-	HeaderInactiveFg    string `toml:"header_inactive_fg"`
-	FooterBarFg         string `toml:"footer_bar_fg"`
-	FooterLabelBg       string `toml:"footer_label_bg"`
-	FooterLabelFg       string `toml:"footer_label_fg"`
-	FooterErrorBg       string `toml:"footer_error_bg"`
-	FooterErrorFg       string `toml:"footer_error_fg"`
-	FooterHintFg        string `toml:"footer_hint_fg"`
+	Border              string `toml:"border,omitempty"`
+	TextInactive        string `toml:"text_inactive,omitempty"`
+	CursorForeground    string `toml:"cursor_fg,omitempty"`
+	NotificationInfo    string `toml:"notification_info,omitempty"`
+	NotificationError   string `toml:"notification_error,omitempty"`
+	NotificationSuccess string `toml:"notification_success,omitempty"`
+	HeaderActiveBg      string `toml:"header_active_bg,omitempty"`
+	HeaderActiveFg      string `toml:"header_active_fg,omitempty"`
+	HeaderInactiveFg    string `toml:"header_inactive_fg,omitempty"`
+	FooterBarFg         string `toml:"footer_bar_fg,omitempty"`
+	FooterLabelBg       string `toml:"footer_label_bg,omitempty"`
+	FooterLabelFg       string `toml:"footer_label_fg,omitempty"`
+	FooterErrorBg       string `toml:"footer_error_bg,omitempty"`
+	FooterErrorFg       string `toml:"footer_error_fg,omitempty"`
+	FooterHintFg        string `toml:"footer_hint_fg,omitempty"`
 }
 
+// KeybindMapping binds actions to keys, keys separated by spaces make a sequence like "g g",
+// which have to be typed within key_sequence_timeout_ms of each other
 type KeybindMapping struct {
-	MoveLeft       []string `toml:"move_left"`
-	MoveDown       []string `toml:"move_down"`
-	MoveUp         []string `toml:"move_up"`
-	Select         []string `toml:"select"`
-	Cancel         []string `toml:"cancel"`
-	Quit           []string `toml:"quit"`
-	ScanFiles      []string `toml:"scan_files"`
-	ViewLibrary    []string `toml:"view_library"`
-	ViewPlaylist   []string `toml:"view_playlist"`
-	ViewStatistics []string `toml:"view_statistics"`
+	MoveLeft        []string `toml:"move_left"`
+	MoveDown        []string `toml:"move_down"`
+	MoveUp          []string `toml:"move_up"`
+	MoveTop         []string `toml:"move_top"`
+	MoveBottom      []string `toml:"move_bottom"`
+	Center          []string `toml:"center"`
+	Select          []string `toml:"select"`
+	Cancel          []string `toml:"cancel"`
+	Quit            []string `toml:"quit"`
+	ScanFiles       []string `toml:"scan_files"`
+	ViewLibrary     []string `toml:"view_library"`
+	ViewPlaylist    []string `toml:"view_playlist"`
+	ViewStatistics  []string `toml:"view_statistics"`
+	TogglePause     []string `toml:"toggle_pause"`
+	NextTrack       []string `toml:"next_track"`
+	PreviousTrack   []string `toml:"previous_track"`
+	SeekForward     []string `toml:"seek_forward"`
+	SeekBackward    []string `toml:"seek_backward"`
+	QueueAppend     []string `toml:"queue_append"`
+	QueueInsertNext []string `toml:"queue_insert_next"`
+	QueueMoveUp     []string `toml:"queue_move_up"`
+	QueueMoveDown   []string `toml:"queue_move_down"`
+	QueueRemove     []string `toml:"queue_remove"`
+	QueueClear      []string `toml:"queue_clear"`
+	OpenStream      []string `toml:"open_stream"`
+	Search          []string `toml:"search"`
+	// only used inside the search overlay, where letters are typed rather than bound
+	SearchSelect    []string `toml:"search_select"`
+	SearchQueue     []string `toml:"search_queue"`
+	SearchQueueNext []string `toml:"search_queue_next"`
+	SearchDown      []string `toml:"search_down"`
+	SearchUp        []string `toml:"search_up"`
+	CommandLine     []string `toml:"command_line"`
+	// only used inside the command line
+	CommandSubmit          []string `toml:"command_submit"`
+	CommandComplete        []string `toml:"command_complete"`
+	CommandHistoryPrevious []string `toml:"command_history_previous"`
+	CommandHistoryNext     []string `toml:"command_history_next"`
+	// only used in prompts, which take typed text too
+	PromptSubmit []string `toml:"prompt_submit"`
 }
 
 type Config struct {
-	Title            string         `toml:"title"`
-	MusicLibraryPath string         `toml:"music_library_path"`
-	InputCharLimit   int            `toml:"input_char_limit"`
-	Notification     Notification   `toml:"notification"`
-	Colors           ColorPalette   `toml:"colors"`
-	Keybinds         KeybindMapping `toml:"keybinds"`
+	Title            string `toml:"title"`
+	MusicLibraryPath string `toml:"music_library_path"`
+	WatchLibrary     bool   `toml:"watch_library"`
+	InputCharLimit   int    `toml:"input_char_limit"`
+	// a preset or a file in the themes directory next to the config
+	Theme string `toml:"theme"`
+	// how long a started key sequence waits for its next key
+	KeySequenceTimeoutMs int            `toml:"key_sequence_timeout_ms"`
+	Notification         Notification   `toml:"notification"`
+	Covers               Covers         `toml:"covers"`
+	LastFM               LastFM         `toml:"lastfm"`
+	ListenBrainz         ListenBrainz   `toml:"listenbrainz"`
+	Radio                Radio          `toml:"radio"`
+	MPRIS                MPRIS          `toml:"mpris"`
+	MPD                  MPD            `toml:"mpd"`
+	Stations             []Station      `toml:"stations"`
+	Colors               ColorPalette   `toml:"colors"`
+	Keybinds             KeybindMapping `toml:"keybinds"`
+	// the [colors] as written, kept for when the theme changes
+	colorOverrides ColorPalette
+}
+
+// tomlName is the key a field is written under, without options like omitempty
+func tomlName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("toml"), ",")
+	return name
+}
+
+// Sequences lists every binding of every action, in keys as they're typed
+func (keybinds KeybindMapping) Sequences() [][]string {
+	var sequences [][]string
+
+	for action := range keybindFields {
+		for _, keys := range keybinds.Keys(action) {
+			sequences = append(sequences, strings.Fields(keys))
+		}
+	}
+
+	return sequences
 }
 
 func Load() (*Config, []error, bool) {
@@ -85,18 +204,17 @@ func Load() (*Config, []error, bool) {
 		return nil, []error{err}, false
 	}
 
-	cfg := DefaultValues()
-	if err = toml.Unmarshal(data, &cfg); err != nil {
-		return nil, []error{err}, false
-	}
-
-	if errs := validateValues(cfg); errs != nil {
+	cfg, errs := parse(data)
+	if errs != nil {
 		return nil, errs, false
 	}
 
-	// Persist so any newly-added default keys are written to the file
-	if err = cfg.Save(); err != nil {
-		return nil, []error{err}, false
+	// keys added since the file was written go in, the rest stays as the user left it. A file
+	// too unusual to edit safely is left alone, the missing keys just keep their defaults
+	if merged, err := mergeDefaults(data); err == nil && !bytes.Equal(merged, data) {
+		if err = writeFile(path, merged); err != nil {
+			return nil, []error{err}, false
+		}
 	}
 
 	// If music library is not correct, we clear it so the prompt shows up
@@ -111,18 +229,25 @@ func Load() (*Config, []error, bool) {
 	return &cfg, nil, musicLibraryPathCleared
 }
 
-func (cfg *Config) Save() error {
-	path, err := getPath()
-	if err != nil {
-		return err
+// parse reads the config on top of the defaults, so keys missing from the file keep their default
+func parse(data []byte) (Config, []error) {
+	cfg := DefaultValues()
+	if err := toml.Unmarshal(data, &cfg); err != nil {
+		return cfg, []error{err}
 	}
 
-	data, err := toml.Marshal(cfg)
-	if err != nil {
-		return err
+	if errs := validateValues(cfg); errs != nil {
+		return cfg, errs
 	}
 
-	return os.WriteFile(path, data, filePerm)
+	// wired used to write every color of the default theme into the file, those copies aren't overrides
+	cfg.colorOverrides = cfg.Colors.without(presets[DefaultTheme])
+
+	if err := cfg.SetTheme(cfg.Theme); err != nil {
+		return cfg, []error{err}
+	}
+
+	return cfg, nil
 }
 
 func (cfg *Config) SetAndSaveMusicLibraryPath(path string) error {
@@ -133,7 +258,13 @@ func (cfg *Config) SetAndSaveMusicLibraryPath(path string) error {
 
 	cfg.MusicLibraryPath = expanded
 
-	return cfg.Save()
+	return setString("", "music_library_path", expanded)
+}
+
+func (cfg *Config) SetAndSaveLastFMSession(sessionKey string) error {
+	cfg.LastFM.SessionKey = sessionKey
+
+	return setString("lastfm", "session_key", sessionKey)
 }
 
 func (cfg *Config) IsMusicLibraryPathValid(path string) (string, error) {
@@ -162,16 +293,35 @@ func validateValues(cfg Config) []error {
 		}
 	}
 
+	nonNegative := func(name string, val int) {
+		if val < 0 {
+			errs = append(errs, fmt.Errorf("%s must be >= 0, got %d", name, val))
+		}
+	}
+
 	nonEmpty := func(name string, val string) {
 		if val == "" {
 			errs = append(errs, fmt.Errorf("%s must not be empty", name))
 		}
 	}
 
-	hexColorPattern := regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
-	hexColor := func(name string, val string) {
-		if !hexColorPattern.MatchString(val) {
-			errs = append(errs, fmt.Errorf("%s must be a #RRGGBB hex color, got %q", name, val))
+	oneOf := func(name string, val string, options ...string) {
+		if !slices.Contains(options, val) {
+			errs = append(errs, fmt.Errorf("%s must be one of %s, got %q", name, strings.Join(options, ", "), val))
+		}
+	}
+
+	httpURL := func(name string, val string) {
+		parsed, err := url.Parse(val)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			errs = append(errs, fmt.Errorf("%s must be an http(s) url, got %q", name, val))
+		}
+	}
+
+	// colors are optional, left out they come from the theme
+	color := func(name string, val string) {
+		if _, ok := normalizeColor(val); val != "" && !ok {
+			errs = append(errs, colorError(name, val))
 		}
 	}
 
@@ -179,13 +329,22 @@ func validateValues(cfg Config) []error {
 		if len(val) == 0 {
 			errs = append(errs, fmt.Errorf("%s must have at least one binding", name))
 		}
+
+		for _, keys := range val {
+			if keys == "" || strings.Join(strings.Fields(keys), " ") != keys {
+				errs = append(errs, fmt.Errorf("%s must separate the keys of a sequence by single spaces, got %q", name, keys))
+			}
+		}
 	}
 
 	// TODO: maybe there's a better way to do this?
 
 	nonEmpty("title", cfg.Title)
+	nonEmpty("theme", cfg.Theme)
 	positive("input_char_limit", cfg.InputCharLimit)
 	maxLimit("input_char_limit", cfg.InputCharLimit, 2056)
+	positive("key_sequence_timeout_ms", cfg.KeySequenceTimeoutMs)
+	maxLimit("key_sequence_timeout_ms", cfg.KeySequenceTimeoutMs, 10000)
 
 	positive("notification.notification_max_width", cfg.Notification.NotificationMaxWidth)
 	maxLimit("notification.notification_max_width", cfg.Notification.NotificationMaxWidth, 256)
@@ -202,25 +361,63 @@ func validateValues(cfg Config) []error {
 	positive("notification.notification_stack_max", cfg.Notification.NotificationStackMax)
 	maxLimit("notification.notification_stack_max", cfg.Notification.NotificationStackMax, 128)
 
-	hexColor("colors.border", cfg.Colors.Border)
-	hexColor("colors.text_inactive", cfg.Colors.TextInactive)
-	hexColor("colors.cursor_fg", cfg.Colors.CursorForeground)
-	hexColor("colors.notification_info", cfg.Colors.NotificationInfo)
-	hexColor("colors.notification_error", cfg.Colors.NotificationError)
-	hexColor("colors.notification_success", cfg.Colors.NotificationSuccess)
-	hexColor("colors.header_active_bg", cfg.Colors.HeaderActiveBg)
-	hexColor("colors.header_active_fg", cfg.Colors.HeaderActiveFg)
-	hexColor("colors.header_inactive_fg", cfg.Colors.HeaderInactiveFg)
-	hexColor("colors.footer_bar_fg", cfg.Colors.FooterBarFg)
-	hexColor("colors.footer_label_bg", cfg.Colors.FooterLabelBg)
-	hexColor("colors.footer_label_fg", cfg.Colors.FooterLabelFg)
-	hexColor("colors.footer_error_bg", cfg.Colors.FooterErrorBg)
-	hexColor("colors.footer_error_fg", cfg.Colors.FooterErrorFg)
-	hexColor("colors.footer_hint_fg", cfg.Colors.FooterHintFg)
+	nonNegative("covers.min_image_bytes", cfg.Covers.MinImageBytes)
+	oneOf("covers.protocol", cfg.Covers.Protocol, "auto", "halfblocks", "kitty", "sixel", "none")
+
+	httpURL("lastfm.endpoint", cfg.LastFM.Endpoint)
+	httpURL("lastfm.auth_url", cfg.LastFM.AuthURL)
+	if cfg.LastFM.Enabled {
+		nonEmpty("lastfm.api_key", cfg.LastFM.APIKey)
+		nonEmpty("lastfm.api_secret", cfg.LastFM.APISecret)
+	}
+
+	httpURL("listenbrainz.endpoint", cfg.ListenBrainz.Endpoint)
+	if cfg.ListenBrainz.Enabled {
+		nonEmpty("listenbrainz.token", cfg.ListenBrainz.Token)
+	}
+
+	nonEmpty("radio.bind_address", cfg.Radio.BindAddress)
+	positive("radio.port", cfg.Radio.Port)
+	maxLimit("radio.port", cfg.Radio.Port, 65536)
+	if !strings.HasPrefix(cfg.Radio.Mount, "/") {
+		errs = append(errs, fmt.Errorf("radio.mount must start with /, got %q", cfg.Radio.Mount))
+	}
+	oneOf("radio.format", cfg.Radio.Format, "mp3", "ogg", "wav")
+	if cfg.Radio.Bitrate < 32 || cfg.Radio.Bitrate > 320 {
+		errs = append(errs, fmt.Errorf("radio.bitrate must be between 32 and 320 kbps, got %d", cfg.Radio.Bitrate))
+	}
+
+	nonEmpty("mpd.bind_address", cfg.MPD.BindAddress)
+	positive("mpd.port", cfg.MPD.Port)
+	maxLimit("mpd.port", cfg.MPD.Port, 65536)
+
+	for i, station := range cfg.Stations {
+		nonEmpty(fmt.Sprintf("stations[%d].name", i), station.Name)
+		httpURL(fmt.Sprintf("stations[%d].url", i), station.URL)
+	}
+
+	color("colors.border", cfg.Colors.Border)
+	color("colors.text_inactive", cfg.Colors.TextInactive)
+	color("colors.cursor_fg", cfg.Colors.CursorForeground)
+	color("colors.notification_info", cfg.Colors.NotificationInfo)
+	color("colors.notification_error", cfg.Colors.NotificationError)
+	color("colors.notification_success", cfg.Colors.NotificationSuccess)
+	color("colors.header_active_bg", cfg.Colors.HeaderActiveBg)
+	color("colors.header_active_fg", cfg.Colors.HeaderActiveFg)
+	color("colors.header_inactive_fg", cfg.Colors.HeaderInactiveFg)
+	color("colors.footer_bar_fg", cfg.Colors.FooterBarFg)
+	color("colors.footer_label_bg", cfg.Colors.FooterLabelBg)
+	color("colors.footer_label_fg", cfg.Colors.FooterLabelFg)
+	color("colors.footer_error_bg", cfg.Colors.FooterErrorBg)
+	color("colors.footer_error_fg", cfg.Colors.FooterErrorFg)
+	color("colors.footer_hint_fg", cfg.Colors.FooterHintFg)
 
 	keybind("keybinds.move_left", cfg.Keybinds.MoveLeft)
 	keybind("keybinds.move_down", cfg.Keybinds.MoveDown)
 	keybind("keybinds.move_up", cfg.Keybinds.MoveUp)
+	keybind("keybinds.move_top", cfg.Keybinds.MoveTop)
+	keybind("keybinds.move_bottom", cfg.Keybinds.MoveBottom)
+	keybind("keybinds.center", cfg.Keybinds.Center)
 	keybind("keybinds.select", cfg.Keybinds.Select)
 	keybind("keybinds.cancel", cfg.Keybinds.Cancel)
 	keybind("keybinds.quit", cfg.Keybinds.Quit)
@@ -228,6 +425,31 @@ func validateValues(cfg Config) []error {
 	keybind("keybinds.view_library", cfg.Keybinds.ViewLibrary)
 	keybind("keybinds.view_playlist", cfg.Keybinds.ViewPlaylist)
 	keybind("keybinds.view_statistics", cfg.Keybinds.ViewStatistics)
+	keybind("keybinds.toggle_pause", cfg.Keybinds.TogglePause)
+	keybind("keybinds.next_track", cfg.Keybinds.NextTrack)
+	keybind("keybinds.previous_track", cfg.Keybinds.PreviousTrack)
+	keybind("keybinds.seek_forward", cfg.Keybinds.SeekForward)
+	keybind("keybinds.seek_backward", cfg.Keybinds.SeekBackward)
+	keybind("keybinds.queue_append", cfg.Keybinds.QueueAppend)
+	keybind("keybinds.queue_insert_next", cfg.Keybinds.QueueInsertNext)
+	keybind("keybinds.queue_move_up", cfg.Keybinds.QueueMoveUp)
+	keybind("keybinds.queue_move_down", cfg.Keybinds.QueueMoveDown)
+	keybind("keybinds.queue_remove", cfg.Keybinds.QueueRemove)
+	keybind("keybinds.queue_clear", cfg.Keybinds.QueueClear)
+	keybind("keybinds.open_stream", cfg.Keybinds.OpenStream)
+	keybind("keybinds.search", cfg.Keybinds.Search)
+	keybind("keybinds.search_select", cfg.Keybinds.SearchSelect)
+	keybind("keybinds.search_queue", cfg.Keybinds.SearchQueue)
+	keybind("keybinds.search_queue_next", cfg.Keybinds.SearchQueueNext)
+	keybind("keybinds.search_down", cfg.Keybinds.SearchDown)
+	keybind("keybinds.search_up", cfg.Keybinds.SearchUp)
+	keybind("keybinds.command_line", cfg.Keybinds.CommandLine)
+	keybind("keybinds.command_submit", cfg.Keybinds.CommandSubmit)
+	keybind("keybinds.command_complete", cfg.Keybinds.CommandComplete)
+	keybind("keybinds.command_history_previous", cfg.Keybinds.CommandHistoryPrevious)
+	keybind("keybinds.command_history_next", cfg.Keybinds.CommandHistoryNext)
+	keybind("keybinds.prompt_submit", cfg.Keybinds.PromptSubmit)
+	errs = append(errs, keybindConflicts(cfg.Keybinds)...)
 
 	if len(errs) == 0 {
 		return nil
@@ -255,7 +477,7 @@ func ensureExists(path string) error {
 		return err
 	}
 
-	return os.WriteFile(path, data, filePerm)
+	return writeFile(path, data)
 }
 
 func expandPath(path string) string {
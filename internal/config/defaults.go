@@ -4,8 +4,11 @@ import "github.com/pelletier/go-toml/v2"
 
 func DefaultValues() Config {
 	return Config{
-		Title:          "wire(d)",
-		InputCharLimit: 256,
+		Title:                "wire(d)",
+		Theme:                DefaultTheme,
+		WatchLibrary:         true,
+		InputCharLimit:       256,
+		KeySequenceTimeoutMs: 1000,
 		Notification: Notification{
 			NotificationMaxWidth:     44,
 			NotificationMaxHeight:    10,
@@ -13,34 +16,69 @@ func DefaultValues() Config {
 			NotificationDurationSecs: 4,
 			NotificationStackMax:     32,
 		},
-		Colors: ColorPalette{
-			Border:              "#6f3d49",
-			TextInactive:        "#44262d",
-			CursorForeground:    "#965363",
-			NotificationInfo:    "#539686",
-			NotificationError:   "#a52a2a",
-			NotificationSuccess: "#639653",
-			HeaderActiveBg:      "#6f3d49",
-			HeaderActiveFg:      "#1a0f12",
-			HeaderInactiveFg:    "#44262d",
-			FooterBarFg:         "#965363",
-			FooterLabelBg:       "#6f3d49",
-			FooterLabelFg:       "#1a0f12",
-			FooterErrorBg:       "#a52a2a",
-			FooterErrorFg:       "#1a0f12",
-			FooterHintFg:        "#44262d",
+		Covers: Covers{
+			MinImageBytes: 10 * 1024,
+			Protocol:      "auto",
+		},
+		LastFM: LastFM{
+			Endpoint: "https://ws.audioscrobbler.com/2.0/",
+			AuthURL:  "https://www.last.fm/api/auth/",
+		},
+		ListenBrainz: ListenBrainz{
+			Endpoint: "https://api.listenbrainz.org",
+		},
+		Radio: Radio{
+			BindAddress: "0.0.0.0",
+			Port:        8000,
+			Mount:       "/wired",
+			Format:      "mp3",
+			Bitrate:     192,
+		},
+		MPRIS: MPRIS{
+			Enabled: true,
+		},
+		MPD: MPD{
+			BindAddress: "127.0.0.1",
+			Port:        6600,
 		},
 		Keybinds: KeybindMapping{
-			MoveLeft:       []string{"h", "left"},
-			MoveDown:       []string{"j", "down"},
-			MoveUp:         []string{"k", "up"},
-			Select:         []string{"enter", "l", "right"},
-			Cancel:         []string{"ctrl+c", "esc"},
-			Quit:           []string{"ctrl+c"},
-			ScanFiles:      []string{"ctrl+s"},
-			ViewLibrary:    []string{"L"},
-			ViewPlaylist:   []string{"P"},
-			ViewStatistics: []string{"S"},
+			MoveLeft:               []string{"h", "left"},
+			MoveDown:               []string{"j", "down"},
+			MoveUp:                 []string{"k", "up"},
+			MoveTop:                []string{"g g", "home"},
+			MoveBottom:             []string{"G", "end"},
+			Center:                 []string{"z z"},
+			Select:                 []string{"enter", "l", "right"},
+			Cancel:                 []string{"ctrl+c", "esc"},
+			Quit:                   []string{"ctrl+c"},
+			ScanFiles:              []string{"ctrl+s"},
+			ViewLibrary:            []string{"L"},
+			ViewPlaylist:           []string{"P"},
+			ViewStatistics:         []string{"S"},
+			TogglePause:            []string{"space"},
+			NextTrack:              []string{">"},
+			PreviousTrack:          []string{"<"},
+			SeekForward:            []string{"."},
+			SeekBackward:           []string{","},
+			QueueAppend:            []string{"a"},
+			QueueInsertNext:        []string{"A"},
+			QueueMoveUp:            []string{"K"},
+			QueueMoveDown:          []string{"J"},
+			QueueRemove:            []string{"x", "d d", "delete"},
+			QueueClear:             []string{"C"},
+			OpenStream:             []string{"o"},
+			Search:                 []string{"/"},
+			SearchSelect:           []string{"enter"},
+			SearchQueue:            []string{"tab"},
+			SearchQueueNext:        []string{"shift+tab"},
+			SearchDown:             []string{"down", "ctrl+n"},
+			SearchUp:               []string{"up", "ctrl+p"},
+			CommandLine:            []string{":"},
+			CommandSubmit:          []string{"enter"},
+			CommandComplete:        []string{"tab"},
+			CommandHistoryPrevious: []string{"up", "ctrl+p"},
+			CommandHistoryNext:     []string{"down", "ctrl+n"},
+			PromptSubmit:           []string{"enter"},
 		},
 	}
 }
@@ -0,0 +1,359 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
+)
+
+// document is the config file as text, edited a line at a time so the comments,
+// order and formatting of whatever the user wrote survive wired writing to it
+type document struct {
+	lines []string
+	// the first section holds the keys before any [table]
+	sections []section
+	// new lines, by the line they go in front of
+	inserts map[int][]string
+}
+
+type section struct {
+	name  string
+	array bool
+	// the lines after the header, up to the next one
+	start int
+	end   int
+	// where the string values are in the file, by key
+	strings map[string]unstable.Range
+}
+
+func parseDocument(data []byte) (document, error) {
+	doc := document{
+		lines:    strings.SplitAfter(string(data), "\n"),
+		sections: []section{{strings: map[string]unstable.Range{}}},
+		inserts:  map[int][]string{},
+	}
+
+	var parser unstable.Parser
+	parser.Reset(data)
+
+	for parser.NextExpression() {
+		expression := parser.Expression()
+
+		switch expression.Kind {
+		case unstable.Table, unstable.ArrayTable:
+			name, offset := keyName(expression.Key())
+			line := bytes.Count(data[:offset], []byte("\n"))
+
+			doc.sections[len(doc.sections)-1].end = line
+			doc.sections = append(doc.sections, section{
+				name:    name,
+				array:   expression.Kind == unstable.ArrayTable,
+				start:   line + 1,
+				strings: map[string]unstable.Range{},
+			})
+
+		case unstable.KeyValue:
+			if value := expression.Value(); value.Kind == unstable.String {
+				name, _ := keyName(expression.Key())
+				doc.sections[len(doc.sections)-1].strings[name] = value.Raw
+			}
+		}
+	}
+
+	if err := parser.Error(); err != nil {
+		return document{}, err
+	}
+
+	doc.sections[len(doc.sections)-1].end = len(doc.lines)
+
+	return doc, nil
+}
+
+// keyName joins a dotted key back together, along with where it starts in the file
+func keyName(key unstable.Iterator) (string, uint32) {
+	var parts []string
+	var offset uint32
+
+	for key.Next() {
+		if len(parts) == 0 {
+			offset = key.Node().Raw.Offset
+		}
+
+		parts = append(parts, string(key.Node().Data))
+	}
+
+	return strings.Join(parts, "."), offset
+}
+
+// section finds the [name] table, "" being the keys before any table. Tables only
+// written as dotted keys or inline aren't found, nothing can be added to those safely
+func (doc *document) section(name string) *section {
+	for i := range doc.sections {
+		if doc.sections[i].name == name && !doc.sections[i].array {
+			return &doc.sections[i]
+		}
+	}
+
+	return nil
+}
+
+// insert adds key = value after the last key of the section, ahead of any blank
+// lines or comments, which tend to belong to the table after it
+func (doc *document) insert(section *section, key string, value any) error {
+	encoded, err := encodeValue(value)
+	if err != nil {
+		return err
+	}
+
+	line := section.start
+
+	for i := section.end - 1; i >= section.start; i-- {
+		trimmed := strings.TrimSpace(doc.lines[i])
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			line = i + 1
+			break
+		}
+	}
+
+	if line > 0 && !strings.HasSuffix(doc.lines[line-1], "\n") {
+		doc.lines[line-1] += "\n"
+	}
+
+	doc.inserts[line] = append(doc.inserts[line], key+" = "+encoded+"\n")
+
+	return nil
+}
+
+func (doc document) String() string {
+	var text strings.Builder
+
+	for i, line := range doc.lines {
+		for _, inserted := range doc.inserts[i] {
+			text.WriteString(inserted)
+		}
+
+		text.WriteString(line)
+	}
+
+	return text.String()
+}
+
+// encodeValue writes a single value the way go-toml would in a whole document
+func encodeValue(value any) (string, error) {
+	data, err := toml.Marshal(map[string]any{"v": value})
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(strings.TrimPrefix(string(data), "v = "), "\n"), nil
+}
+
+// mergeDefaults adds the keys wired knows about but the file doesn't have yet,
+// usually settings that came with an update, and leaves everything else alone
+func mergeDefaults(data []byte) ([]byte, error) {
+	var present map[string]any
+	if err := toml.Unmarshal(data, &present); err != nil {
+		return nil, err
+	}
+
+	doc, err := parseDocument(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var tables strings.Builder
+
+	defaults := reflect.ValueOf(DefaultValues())
+
+	for i := range defaults.NumField() {
+		name := tomlName(defaults.Type().Field(i))
+		value := defaults.Field(i)
+
+		switch {
+		case !defaults.Type().Field(i).IsExported():
+			// not part of the file
+
+		case value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.Struct:
+			// arrays of tables like [[stations]] are the user's own, there's nothing to fill in
+
+		case value.Kind() == reflect.Struct:
+			if _, ok := present[name]; !ok {
+				table, err := toml.Marshal(map[string]any{name: value.Interface()})
+				if err != nil {
+					return nil, err
+				}
+
+				tables.WriteString("\n")
+				tables.Write(table)
+
+				continue
+			}
+
+			table, _ := present[name].(map[string]any)
+			section := doc.section(name)
+
+			if section == nil {
+				continue
+			}
+
+			for j := range value.NumField() {
+				field := value.Type().Field(j)
+				key := tomlName(field)
+
+				// optional keys like the colors of the theme don't need writing down
+				if _, ok := table[key]; ok || isOmitted(field, value.Field(j)) {
+					continue
+				}
+
+				if err := doc.insert(section, key, value.Field(j).Interface()); err != nil {
+					return nil, err
+				}
+			}
+
+		default:
+			if _, ok := present[name]; ok {
+				continue
+			}
+
+			if err := doc.insert(doc.section(""), name, value.Interface()); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	merged := doc.String()
+	if tables.Len() > 0 && merged != "" && !strings.HasSuffix(merged, "\n") {
+		merged += "\n"
+	}
+
+	merged += tables.String()
+
+	// whatever the edits did, they mustn't break a file that was fine
+	var check map[string]any
+	if err := toml.Unmarshal([]byte(merged), &check); err != nil {
+		return nil, err
+	}
+
+	return []byte(merged), nil
+}
+
+func isOmitted(field reflect.StructField, value reflect.Value) bool {
+	return strings.Contains(field.Tag.Get("toml"), ",omitempty") && value.IsZero()
+}
+
+// setString changes a single string in the config file, adding it when it isn't there
+func setString(table string, key string, value string) error {
+	path, err := getPath()
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	doc, err := parseDocument(data)
+	if err != nil {
+		return err
+	}
+
+	section := doc.section(table)
+
+	var position unstable.Range
+	var found bool
+	if section != nil {
+		position, found = section.strings[key]
+	}
+
+	var edited []byte
+
+	switch {
+	case section == nil:
+		encoded, err := toml.Marshal(map[string]any{table: map[string]any{key: value}})
+		if err != nil {
+			return err
+		}
+
+		if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+			data = append(data, '\n')
+		}
+
+		edited = slices.Concat(data, []byte("\n"), encoded)
+
+	case !found:
+		if err := doc.insert(section, key, value); err != nil {
+			return err
+		}
+
+		edited = []byte(doc.String())
+
+	default:
+		encoded, err := encodeValue(value)
+		if err != nil {
+			return err
+		}
+
+		edited = slices.Concat(data[:position.Offset], []byte(encoded), data[position.Offset+position.Length:])
+	}
+
+	// e.g. a table written inline can't take another key this way, better not to write at all
+	var check map[string]any
+	if err := toml.Unmarshal(edited, &check); err != nil {
+		return err
+	}
+
+	return writeFile(path, edited)
+}
+
+// writeFile swaps in a temporary file written next to path, so a crash midway
+// leaves either the old file or the new one and never half of it
+func writeFile(path string, data []byte) error {
+	// a config linked from a dotfiles repo stays a link, it's the file it points to that's replaced
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	perm := os.FileMode(filePerm)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+
+	// nothing left to remove once it's been renamed
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(file.Name(), perm); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
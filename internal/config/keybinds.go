@@ -0,0 +1,186 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// KeybindContext is a set of actions listening for keys at the same time, a key can only mean one of them.
+// the ui looks keys up through these, so what's checked for conflicts is exactly what's listened for
+type KeybindContext struct {
+	Name    string
+	Actions []string
+	// whether keys go through the sequence engine, overlays read single keys straight away
+	Sequences bool
+	// whether it has a text input, whatever would type a character there can't be bound
+	Text bool
+}
+
+var (
+	// checked first and along with every panel, since they're matched before the panel sees a key
+	GlobalKeybinds = KeybindContext{"every panel", []string{
+		"command_line", "quit", "scan_files", "view_library", "view_playlist", "view_statistics",
+		"toggle_pause", "next_track", "previous_track", "seek_forward", "seek_backward", "search", "open_stream",
+	}, true, false}
+	LibraryKeybinds = KeybindContext{"the library", []string{
+		"move_down", "move_up", "move_top", "move_bottom", "center", "move_left", "select",
+		"queue_append", "queue_insert_next",
+	}, true, false}
+	PlaylistKeybinds = KeybindContext{"the playlist", []string{
+		"move_down", "move_up", "move_top", "move_bottom", "center", "select",
+		"queue_move_down", "queue_move_up", "queue_remove", "queue_clear",
+	}, true, false}
+	StatisticsKeybinds = KeybindContext{"the statistics", []string{"move_left", "select"}, true, false}
+	DialogKeybinds     = KeybindContext{"dialogs", []string{"quit"}, false, false}
+	PromptKeybinds     = KeybindContext{"prompts", []string{"prompt_submit", "cancel"}, false, true}
+	SearchKeybinds     = KeybindContext{"the search overlay", []string{
+		"cancel", "search_down", "search_up", "search_select", "search_queue", "search_queue_next",
+	}, false, true}
+	CommandLineKeybinds = KeybindContext{"the command line", []string{
+		"cancel", "command_submit", "command_history_previous", "command_history_next", "command_complete",
+	}, false, true}
+)
+
+// keybindContexts are what's listening at once, the panels along with the global keybinds
+func keybindContexts() []KeybindContext {
+	contexts := []KeybindContext{GlobalKeybinds}
+
+	for _, panel := range []KeybindContext{LibraryKeybinds, PlaylistKeybinds, StatisticsKeybinds} {
+		panel.Actions = slices.Concat(GlobalKeybinds.Actions, panel.Actions)
+		contexts = append(contexts, panel)
+	}
+
+	return append(contexts, DialogKeybinds, PromptKeybinds, SearchKeybinds, CommandLineKeybinds)
+}
+
+// keybindFields finds the field of every action by its name in the config, fields that aren't
+// lists of keys aren't actions
+var keybindFields = func() map[string]int {
+	fields := map[string]int{}
+
+	mapping := reflect.TypeFor[KeybindMapping]()
+	for i := range mapping.NumField() {
+		if mapping.Field(i).Type == reflect.TypeFor[[]string]() {
+			fields[tomlName(mapping.Field(i))] = i
+		}
+	}
+
+	return fields
+}()
+
+// Keys are the bindings of an action, by its name in the config
+func (keybinds KeybindMapping) Keys(action string) []string {
+	field, ok := keybindFields[action]
+	if !ok {
+		return nil
+	}
+
+	keys, _ := reflect.ValueOf(keybinds).Field(field).Interface().([]string)
+
+	return keys
+}
+
+// Action is what key means in context, the first of its actions bound to it, or "" for none
+func (keybinds KeybindMapping) Action(context KeybindContext, key string) string {
+	for _, action := range context.Actions {
+		if slices.Contains(keybinds.Keys(action), key) {
+			return action
+		}
+	}
+
+	return ""
+}
+
+// keybindConflicts finds keys bound to two actions of a context, which would leave one of them
+// unreachable depending on what's checked first, and sequences that start with another binding,
+// which would hold that binding back until the sequence times out
+func keybindConflicts(keybinds KeybindMapping) []error {
+	contexts := keybindContexts()
+
+	sequenced := map[string]bool{}
+	for _, context := range contexts {
+		for _, action := range context.Actions {
+			sequenced[action] = sequenced[action] || context.Sequences
+		}
+	}
+
+	var errs []error
+	// the global actions come up in every panel, but their conflicts are only worth one error
+	seen := map[string]bool{}
+
+	report := func(key string, err error) {
+		if !seen[key] {
+			seen[key] = true
+			errs = append(errs, err)
+		}
+	}
+
+	for _, context := range contexts {
+		for i, action := range context.Actions {
+			for _, keys := range keybinds.Keys(action) {
+				if !sequenced[action] && strings.Contains(keys, " ") {
+					report(action+"\x00"+keys, fmt.Errorf(
+						"keybinds.%s can't be a sequence, keys in %s are read one at a time, got %q", action, context.Name, keys,
+					))
+				}
+
+				if context.Text && typed(keys) {
+					report(action+"\x00"+keys, fmt.Errorf(
+						"keybinds.%s can't be %q, it would be typed into %s instead", action, keys, context.Name,
+					))
+				}
+			}
+
+			for _, other := range context.Actions[i+1:] {
+				for _, keys := range keybinds.Keys(action) {
+					for _, otherKeys := range keybinds.Keys(other) {
+						key := strings.Join([]string{action, keys, other, otherKeys}, "\x00")
+
+						if keys == otherKeys {
+							report(key, fmt.Errorf(
+								"keybinds.%s and keybinds.%s are both bound to %q in %s", action, other, keys, context.Name,
+							))
+
+							continue
+						}
+
+						if !context.Sequences {
+							continue
+						}
+
+						if isPrefix(keys, otherKeys) {
+							report(key, prefixError(action, keys, other, otherKeys, context.Name))
+						} else if isPrefix(otherKeys, keys) {
+							report(key, prefixError(other, otherKeys, action, keys, context.Name))
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return errs
+}
+
+// typed tells keys that are a character of text apart from enter, arrows, ctrl+ and the like
+func typed(keys string) bool {
+	character, size := utf8.DecodeRuneInString(keys)
+
+	return keys != "" && size == len(keys) && unicode.IsPrint(character)
+}
+
+func isPrefix(prefix string, keys string) bool {
+	prefixFields, keysFields := strings.Fields(prefix), strings.Fields(keys)
+
+	return len(prefixFields) < len(keysFields) && slices.Equal(prefixFields, keysFields[:len(prefixFields)])
+}
+
+func prefixError(action string, keys string, longer string, longerKeys string, context string) error {
+	return fmt.Errorf(
+		"keybinds.%s is bound to %q, which starts keybinds.%s's %q in %s", action, keys, longer, longerKeys, context,
+	)
+}
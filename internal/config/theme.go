@@ -0,0 +1,256 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// DefaultTheme is the palette wired has always had
+const DefaultTheme = "lain"
+
+var ErrUnknownTheme = errors.New("no such theme")
+
+var hexColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// the ANSI-16 colors by index, the bright ones come with a bright_ prefix
+var colorNames = []string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+
+// presets ship with wired, a file in the themes directory with the same name takes their place
+var presets = map[string]ColorPalette{
+	"lain": {
+		Border:              "#6f3d49",
+		TextInactive:        "#44262d",
+		CursorForeground:    "#965363",
+		NotificationInfo:    "#539686",
+		NotificationError:   "#a52a2a",
+		NotificationSuccess: "#639653",
+		HeaderActiveBg:      "#6f3d49",
+		HeaderActiveFg:      "#1a0f12",
+		HeaderInactiveFg:    "#44262d",
+		FooterBarFg:         "#965363",
+		FooterLabelBg:       "#6f3d49",
+		FooterLabelFg:       "#1a0f12",
+		FooterErrorBg:       "#a52a2a",
+		FooterErrorFg:       "#1a0f12",
+		FooterHintFg:        "#44262d",
+	},
+	"light": {
+		Border:              "#c9a9b1",
+		TextInactive:        "#9a8a8f",
+		CursorForeground:    "#8a3b50",
+		NotificationInfo:    "#2f6f62",
+		NotificationError:   "#b02a2a",
+		NotificationSuccess: "#3f7a32",
+		HeaderActiveBg:      "#8a3b50",
+		HeaderActiveFg:      "#fdf6f7",
+		HeaderInactiveFg:    "#9a8a8f",
+		FooterBarFg:         "#8a3b50",
+		FooterLabelBg:       "#8a3b50",
+		FooterLabelFg:       "#fdf6f7",
+		FooterErrorBg:       "#b02a2a",
+		FooterErrorFg:       "#fdf6f7",
+		FooterHintFg:        "#9a8a8f",
+	},
+	// only the terminal's own grays, so it follows whatever the terminal is set to
+	"monochrome": {
+		Border:              "bright_black",
+		TextInactive:        "bright_black",
+		CursorForeground:    "bright_white",
+		NotificationInfo:    "white",
+		NotificationError:   "bright_white",
+		NotificationSuccess: "white",
+		HeaderActiveBg:      "white",
+		HeaderActiveFg:      "black",
+		HeaderInactiveFg:    "bright_black",
+		FooterBarFg:         "white",
+		FooterLabelBg:       "white",
+		FooterLabelFg:       "black",
+		FooterErrorBg:       "bright_white",
+		FooterErrorFg:       "black",
+		FooterHintFg:        "bright_black",
+	},
+}
+
+// LoadTheme finds a theme in the themes directory, or among the presets. A theme file
+// can leave colors out, those come from the default theme
+func LoadTheme(name string) (ColorPalette, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return ColorPalette{}, fmt.Errorf("%w: %q", ErrUnknownTheme, name)
+	}
+
+	dir, err := getThemesPath()
+	if err != nil {
+		return ColorPalette{}, err
+	}
+
+	palette, ok := presets[name]
+
+	data, err := os.ReadFile(filepath.Join(dir, name+".toml"))
+	switch {
+	case err == nil:
+		var theme ColorPalette
+		if err := toml.Unmarshal(data, &theme); err != nil {
+			return ColorPalette{}, fmt.Errorf("theme %s: %w", name, err)
+		}
+
+		palette = theme.over(presets[DefaultTheme])
+
+	case !errors.Is(err, fs.ErrNotExist):
+		return ColorPalette{}, err
+
+	case !ok:
+		return ColorPalette{}, fmt.Errorf("%w: %q", ErrUnknownTheme, name)
+	}
+
+	palette, errs := palette.normalize()
+	if errs != nil {
+		return ColorPalette{}, fmt.Errorf("theme %s: %w", name, errors.Join(errs...))
+	}
+
+	return palette, nil
+}
+
+// DefaultPalette is the default theme as it ships, for whatever has to be drawn before there's a config
+func DefaultPalette() ColorPalette {
+	palette, _ := presets[DefaultTheme].normalize()
+	return palette
+}
+
+// Themes lists the presets and the theme files, for picking one
+func Themes() []string {
+	var names []string
+	for name := range presets {
+		names = append(names, name)
+	}
+
+	if dir, err := getThemesPath(); err == nil {
+		entries, _ := os.ReadDir(dir)
+
+		for _, entry := range entries {
+			if name, ok := strings.CutSuffix(entry.Name(), ".toml"); ok && !entry.IsDir() {
+				names = append(names, name)
+			}
+		}
+	}
+
+	slices.Sort(names)
+
+	return slices.Compact(names)
+}
+
+// SetTheme switches to another theme, the [colors] of the config still go on top
+func (cfg *Config) SetTheme(name string) error {
+	palette, err := LoadTheme(name)
+	if err != nil {
+		return err
+	}
+
+	cfg.Theme = name
+	// validated along with the rest of the config, so there's nothing left to go wrong
+	cfg.Colors, _ = cfg.colorOverrides.over(palette).normalize()
+
+	return nil
+}
+
+// SetAndSaveTheme switches to another theme and writes it to the config, so it outlasts the next reload
+func (cfg *Config) SetAndSaveTheme(name string) error {
+	if err := cfg.SetTheme(name); err != nil {
+		return err
+	}
+
+	return setString("", "theme", name)
+}
+
+// over fills the colors missing from palette with those of base
+func (palette ColorPalette) over(base ColorPalette) ColorPalette {
+	value := reflect.ValueOf(&palette).Elem()
+	baseValue := reflect.ValueOf(base)
+
+	for i := range value.NumField() {
+		if value.Field(i).String() == "" {
+			value.Field(i).SetString(baseValue.Field(i).String())
+		}
+	}
+
+	return palette
+}
+
+// without clears the colors that are the same as in base
+func (palette ColorPalette) without(base ColorPalette) ColorPalette {
+	value := reflect.ValueOf(&palette).Elem()
+	baseValue := reflect.ValueOf(base)
+
+	for i := range value.NumField() {
+		if value.Field(i).String() == baseValue.Field(i).String() {
+			value.Field(i).SetString("")
+		}
+	}
+
+	return palette
+}
+
+// normalize turns the color names into ANSI indices, what lipgloss reads next to #RRGGBB
+func (palette ColorPalette) normalize() (ColorPalette, []error) {
+	var errs []error
+
+	value := reflect.ValueOf(&palette).Elem()
+
+	for i := range value.NumField() {
+		color, ok := normalizeColor(value.Field(i).String())
+		if !ok {
+			errs = append(errs, colorError(tomlName(value.Type().Field(i)), value.Field(i).String()))
+			continue
+		}
+
+		value.Field(i).SetString(color)
+	}
+
+	return palette, errs
+}
+
+func normalizeColor(color string) (string, bool) {
+	if hexColorPattern.MatchString(color) {
+		return color, true
+	}
+
+	if index, err := strconv.Atoi(color); err == nil && index >= 0 && index <= 255 && strconv.Itoa(index) == color {
+		return color, true
+	}
+
+	name, bright := strings.CutPrefix(color, "bright_")
+
+	index := slices.Index(colorNames, name)
+	if index < 0 {
+		return "", false
+	}
+
+	if bright {
+		index += len(colorNames)
+	}
+
+	return strconv.Itoa(index), true
+}
+
+func colorError(name string, color string) error {
+	return fmt.Errorf("%s must be a #RRGGBB hex color, an ANSI index from 0 to 255 or a name like red or bright_red, got %q", name, color)
+}
+
+// themes sit next to the config
+func getThemesPath() (string, error) {
+	path, err := getPath()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(filepath.Dir(path), "themes"), nil
+}
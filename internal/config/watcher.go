@@ -0,0 +1,142 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// editors tend to write in a few steps, so a reload waits for them to settle
+const reloadQuietPeriod = 200 * time.Millisecond
+
+// WatchEvent is the config as it now is on disk, or the reasons it can't be used
+type WatchEvent struct {
+	Config *Config
+	Errors []error
+}
+
+// Watcher reloads the config file whenever it changes. Plenty of editors save by
+// replacing the file rather than writing to it, so it's the directory that's watched
+type Watcher struct {
+	notify *fsnotify.Watcher
+	events chan WatchEvent
+	done   chan struct{}
+	path   string
+}
+
+func Watch() (*Watcher, error) {
+	path, err := getPath()
+	if err != nil {
+		return nil, err
+	}
+
+	notify, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := notify.Add(filepath.Dir(path)); err != nil {
+		notify.Close()
+		return nil, err
+	}
+
+	watcher := &Watcher{
+		notify: notify,
+		events: make(chan WatchEvent),
+		done:   make(chan struct{}),
+		path:   path,
+	}
+
+	go watcher.run()
+
+	return watcher, nil
+}
+
+func (watcher *Watcher) Events() <-chan WatchEvent {
+	return watcher.events
+}
+
+func (watcher *Watcher) Close() error {
+	select {
+	case <-watcher.done:
+		return nil
+	default:
+		close(watcher.done)
+	}
+
+	return watcher.notify.Close()
+}
+
+func (watcher *Watcher) run() {
+	var quiet <-chan time.Time
+
+	for {
+		select {
+		case <-watcher.done:
+			return
+
+		case event, ok := <-watcher.notify.Events:
+			if !ok {
+				return
+			}
+
+			if filepath.Clean(event.Name) != watcher.path {
+				continue
+			}
+
+			if event.Has(fsnotify.Create) || event.Has(fsnotify.Write) {
+				quiet = time.After(reloadQuietPeriod)
+			}
+
+		case err, ok := <-watcher.notify.Errors:
+			if !ok {
+				return
+			}
+
+			if !watcher.send(WatchEvent{Errors: []error{err}}) {
+				return
+			}
+
+		case <-quiet:
+			quiet = nil
+
+			cfg, errs := reload(watcher.path)
+			if !watcher.send(WatchEvent{Config: cfg, Errors: errs}) {
+				return
+			}
+		}
+	}
+}
+
+func (watcher *Watcher) send(event WatchEvent) bool {
+	select {
+	case watcher.events <- event:
+		return true
+	case <-watcher.done:
+		return false
+	}
+}
+
+// reload reads the config the way Load does, except nothing is written back and an
+// invalid music library path is an error rather than a reason to ask for a new one
+func reload(path string) (*Config, []error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	cfg, errs := parse(data)
+	if errs != nil {
+		return nil, errs
+	}
+
+	if cfg.MusicLibraryPath != "" {
+		if _, err := cfg.IsMusicLibraryPathValid(cfg.MusicLibraryPath); err != nil {
+			return nil, []error{err}
+		}
+	}
+
+	return &cfg, nil
+}
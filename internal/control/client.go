@@ -0,0 +1,135 @@
+package control
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"strconv"
+)
+
+var ErrNotRunning = errors.New("wired isn't running, nothing is listening on the control socket")
+
+// Client talks to a running wired, it's meant for one caller at a time
+type Client struct {
+	conn    net.Conn
+	scanner *bufio.Scanner
+	nextID  int
+}
+
+func Dial() (*Client, error) {
+	conn, err := net.Dial("unix", SocketPath())
+	if err != nil {
+		return nil, errors.Join(ErrNotRunning, err)
+	}
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), maxRequestSize)
+
+	return &Client{conn: conn, scanner: scanner}, nil
+}
+
+func (client *Client) Close() error {
+	return client.conn.Close()
+}
+
+// Call sends a request and decodes its result into result, which may be nil when it doesn't matter
+func (client *Client) Call(method string, params any, result any) error {
+	client.nextID++
+	id := json.RawMessage(strconv.Itoa(client.nextID))
+
+	if err := client.send(Request{ID: id, Method: method}, params); err != nil {
+		return err
+	}
+
+	for {
+		response, err := client.read()
+		if err != nil {
+			return err
+		}
+
+		// events of an earlier subscribe may arrive in between
+		if string(response.ID) != string(id) {
+			continue
+		}
+
+		if response.Error != nil {
+			return response.Error
+		}
+
+		if result == nil {
+			return nil
+		}
+
+		return json.Unmarshal(response.Result, result)
+	}
+}
+
+// Subscribe calls callback with every event until the connection drops or callback returns false
+func (client *Client) Subscribe(callback func(event Event) bool) error {
+	if err := client.Call(MethodSubscribe, nil, nil); err != nil {
+		return err
+	}
+
+	return client.listen(callback)
+}
+
+// listen hands events to callback once subscribed, until it returns false or the connection goes away
+func (client *Client) listen(callback func(event Event) bool) error {
+	for {
+		response, err := client.read()
+		if err != nil {
+			return err
+		}
+
+		if response.Method != NotificationEvent {
+			continue
+		}
+
+		var event Event
+		if err := json.Unmarshal(response.Params, &event); err != nil {
+			return err
+		}
+
+		if !callback(event) {
+			return nil
+		}
+	}
+}
+
+func (client *Client) send(request Request, params any) error {
+	request.JSONRPC = jsonrpcVersion
+
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+
+		request.Params = data
+	}
+
+	data, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.conn.Write(append(data, '\n'))
+
+	return err
+}
+
+func (client *Client) read() (Response, error) {
+	if !client.scanner.Scan() {
+		if err := client.scanner.Err(); err != nil {
+			return Response{}, err
+		}
+
+		return Response{}, net.ErrClosed
+	}
+
+	var response Response
+	err := json.Unmarshal(client.scanner.Bytes(), &response)
+
+	return response, err
+}
@@ -0,0 +1,282 @@
+// Package control lets other programs drive wired through JSON-RPC 2.0 over a unix socket,
+// one json object per line in both directions
+package control
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	engine "wired/internal/engine"
+	playback "wired/internal/playback"
+)
+
+const (
+	dirPerm = 0o700
+
+	jsonrpcVersion = "2.0"
+
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeServerError    = -32000
+)
+
+// methods understood by the server
+const (
+	MethodPlay        = "play"
+	MethodPause       = "pause"
+	MethodToggle      = "toggle"
+	MethodStop        = "stop"
+	MethodNext        = "next"
+	MethodPrevious    = "previous"
+	MethodSeek        = "seek"
+	MethodVolume      = "volume"
+	MethodQueueAdd    = "queue.add"
+	MethodQueueList   = "queue.list"
+	MethodQueueMove   = "queue.move"
+	MethodQueueRemove = "queue.remove"
+	MethodQueueClear  = "queue.clear"
+	MethodStatus      = "status"
+	MethodSubscribe   = "subscribe"
+
+	// sent by the server to subscribed connections
+	NotificationEvent = "event"
+)
+
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"` // only set on notifications
+	Params  json.RawMessage `json:"params,omitempty"` // only set on notifications
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("%s (%d)", err.Message, err.Code)
+}
+
+type PlayParams struct {
+	// plays this queue entry instead of resuming, counted from 0
+	Index *int `json:"index,omitempty"`
+}
+
+type SeekParams struct {
+	Seconds  float64 `json:"seconds"`
+	Relative bool    `json:"relative,omitempty"`
+}
+
+type VolumeParams struct {
+	// leaving it out only reads the volume
+	Volume   *int `json:"volume,omitempty"`
+	Relative bool `json:"relative,omitempty"`
+}
+
+type QueueAddParams struct {
+	// absolute file paths or stream urls
+	Paths []string `json:"paths,omitempty"`
+	// whole entries instead of paths, for clients that already know the metadata
+	Tracks []Track `json:"tracks,omitempty"`
+	// right after the current track instead of at the end
+	Next bool `json:"next,omitempty"`
+	// start playing the first of them
+	Play bool `json:"play,omitempty"`
+}
+
+type QueueMoveParams struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+type QueueRemoveParams struct {
+	Index int `json:"index"`
+}
+
+type Track struct {
+	Path        string  `json:"path"`
+	Title       string  `json:"title,omitempty"`
+	Artist      string  `json:"artist,omitempty"`
+	Album       string  `json:"album,omitempty"`
+	AlbumArtist string  `json:"album_artist,omitempty"`
+	TrackNumber int     `json:"track_number,omitempty"`
+	Duration    float64 `json:"duration,omitempty"` // seconds
+	Cover       string  `json:"cover,omitempty"`
+	StreamTitle string  `json:"stream_title,omitempty"`
+}
+
+type Status struct {
+	State    string  `json:"state"`
+	Track    *Track  `json:"track,omitempty"`
+	Index    int     `json:"index"`
+	Position float64 `json:"position"` // seconds
+	Duration float64 `json:"duration"` // seconds
+	Volume   int     `json:"volume"`
+	Queue    int     `json:"queue_length"`
+}
+
+type Queue struct {
+	Tracks  []Track `json:"tracks"`
+	Current int     `json:"current"` // -1 until something is played
+}
+
+type Event struct {
+	Type  string `json:"type"`
+	Track *Track `json:"track,omitempty"`
+	Error string `json:"error,omitempty"`
+	// only on track_ended, see engine.Event
+	Started   int64   `json:"started,omitempty"`  // unix seconds
+	Listened  float64 `json:"listened,omitempty"` // seconds
+	Completed bool    `json:"completed,omitempty"`
+}
+
+// SocketPath is where the server listens, under $XDG_RUNTIME_DIR when there is one
+func SocketPath() string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), fmt.Sprintf("wired-%d", os.Getuid()))
+	}
+
+	return filepath.Join(dir, "wired", "control.sock")
+}
+
+// newTrack is for events and the status, which leave the track out when there is none
+func newTrack(track engine.Track) *Track {
+	if track.Path == "" {
+		return nil
+	}
+
+	converted := toTrack(track)
+
+	return &converted
+}
+
+func toTrack(track engine.Track) Track {
+	return Track{
+		Path:        track.Path,
+		Title:       track.Title,
+		Artist:      track.Artist,
+		Album:       track.Album,
+		AlbumArtist: track.AlbumArtist,
+		TrackNumber: track.TrackNumber,
+		Duration:    track.Duration.Seconds(),
+		Cover:       track.Cover,
+		StreamTitle: track.StreamTitle,
+	}
+}
+
+// engineTrack is the other way around, for clients handing tracks back to an engine
+func (track Track) engineTrack() engine.Track {
+	return engine.Track{
+		Path:        track.Path,
+		Title:       track.Title,
+		Artist:      track.Artist,
+		Album:       track.Album,
+		AlbumArtist: track.AlbumArtist,
+		TrackNumber: track.TrackNumber,
+		Duration:    time.Duration(track.Duration * float64(time.Second)),
+		Cover:       track.Cover,
+		StreamTitle: track.StreamTitle,
+	}
+}
+
+func newEvent(event engine.Event) Event {
+	notification := Event{Type: eventName(event.Type), Track: newTrack(event.Track), Completed: event.Completed}
+
+	if event.Error != nil {
+		notification.Error = event.Error.Error()
+	}
+
+	if event.Type == engine.TrackEnded {
+		notification.Started = event.Started.Unix()
+		notification.Listened = event.Listened.Seconds()
+	}
+
+	return notification
+}
+
+func (event Event) engineEvent() engine.Event {
+	converted := engine.Event{Type: eventType(event.Type), Completed: event.Completed}
+
+	if event.Track != nil {
+		converted.Track = event.Track.engineTrack()
+	}
+
+	if event.Error != "" {
+		converted.Error = errors.New(event.Error)
+	}
+
+	if event.Started != 0 {
+		converted.Started = time.Unix(event.Started, 0)
+		converted.Listened = time.Duration(event.Listened * float64(time.Second))
+	}
+
+	return converted
+}
+
+func stateName(state playback.State) string {
+	switch state {
+	case playback.Playing:
+		return "playing"
+	case playback.Paused:
+		return "paused"
+	default:
+		return "stopped"
+	}
+}
+
+func parseState(name string) playback.State {
+	switch name {
+	case "playing":
+		return playback.Playing
+	case "paused":
+		return playback.Paused
+	default:
+		return playback.Stopped
+	}
+}
+
+var eventNames = map[engine.EventType]string{
+	engine.TrackStarted:    "track_started",
+	engine.TrackEnded:      "track_ended",
+	engine.TrackFailed:     "track_failed",
+	engine.StateChanged:    "state_changed",
+	engine.QueueChanged:    "queue_changed",
+	engine.MetadataChanged: "metadata_changed",
+	engine.Seeked:          "seeked",
+}
+
+func eventName(eventType engine.EventType) string {
+	if name, ok := eventNames[eventType]; ok {
+		return name
+	}
+
+	return "unknown"
+}
+
+// eventType falls back to a state change, which makes clients look at the status again
+func eventType(name string) engine.EventType {
+	for eventType, candidate := range eventNames {
+		if candidate == name {
+			return eventType
+		}
+	}
+
+	return engine.StateChanged
+}
@@ -0,0 +1,212 @@
+package control
+
+import (
+	"sync"
+	"time"
+
+	engine "wired/internal/engine"
+	library "wired/internal/library"
+	playback "wired/internal/playback"
+)
+
+// Remote drives the engine of a wired running elsewhere, e.g. `wired --daemon`, with the same
+// methods the engine has so a frontend can't tell them apart
+type Remote struct {
+	calls       *Client
+	events      *Client
+	subscribers map[chan engine.Event]bool
+	closed      bool
+	callMutex   sync.Mutex
+	mutex       sync.Mutex
+}
+
+// Attach connects to the running wired, failing with ErrNotRunning when there is none
+func Attach() (*Remote, error) {
+	calls, err := Dial()
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := Dial()
+	if err != nil {
+		calls.Close()
+		return nil, err
+	}
+
+	// subscribed before returning so no event of what the caller does next gets lost
+	if err := events.Call(MethodSubscribe, nil, nil); err != nil {
+		calls.Close()
+		events.Close()
+
+		return nil, err
+	}
+
+	remote := &Remote{calls: calls, events: events, subscribers: map[chan engine.Event]bool{}}
+
+	go remote.follow()
+
+	return remote, nil
+}
+
+// follow relays the daemon's events, subscribers' channels are closed once the daemon goes away
+func (remote *Remote) follow() {
+	remote.events.listen(func(event Event) bool {
+		remote.mutex.Lock()
+		defer remote.mutex.Unlock()
+
+		for channel := range remote.subscribers {
+			select {
+			case channel <- event.engineEvent():
+			default:
+			}
+		}
+
+		return true
+	})
+
+	remote.mutex.Lock()
+	defer remote.mutex.Unlock()
+
+	remote.closed = true
+
+	for channel := range remote.subscribers {
+		delete(remote.subscribers, channel)
+		close(channel)
+	}
+}
+
+func (remote *Remote) Subscribe() (<-chan engine.Event, func()) {
+	remote.mutex.Lock()
+	defer remote.mutex.Unlock()
+
+	channel := make(chan engine.Event, 64)
+
+	if remote.closed {
+		close(channel)
+		return channel, func() {}
+	}
+
+	remote.subscribers[channel] = true
+
+	return channel, func() {
+		remote.mutex.Lock()
+		defer remote.mutex.Unlock()
+
+		if remote.subscribers[channel] {
+			delete(remote.subscribers, channel)
+			close(channel)
+		}
+	}
+}
+
+func (remote *Remote) call(method string, params any, result any) error {
+	remote.callMutex.Lock()
+	defer remote.callMutex.Unlock()
+
+	return remote.calls.Call(method, params, result)
+}
+
+// Status reads as stopped when the daemon can't be reached, subscribers find out why
+func (remote *Remote) Status() engine.Status {
+	var status Status
+	if err := remote.call(MethodStatus, nil, &status); err != nil {
+		return engine.Status{State: playback.Stopped, Index: -1}
+	}
+
+	converted := engine.Status{
+		State:    parseState(status.State),
+		Index:    status.Index,
+		Position: time.Duration(status.Position * float64(time.Second)),
+		Duration: time.Duration(status.Duration * float64(time.Second)),
+		Volume:   status.Volume,
+	}
+
+	if status.Track != nil {
+		converted.Track = status.Track.engineTrack()
+	}
+
+	return converted
+}
+
+func (remote *Remote) Queue() ([]engine.Track, int) {
+	var queue Queue
+	if err := remote.call(MethodQueueList, nil, &queue); err != nil {
+		return nil, -1
+	}
+
+	tracks := make([]engine.Track, len(queue.Tracks))
+	for i, track := range queue.Tracks {
+		tracks[i] = track.engineTrack()
+	}
+
+	return tracks, queue.Current
+}
+
+func (remote *Remote) PlayIndex(index int) error {
+	return remote.call(MethodPlay, PlayParams{Index: &index}, nil)
+}
+
+func (remote *Remote) TogglePause() error {
+	return remote.call(MethodToggle, nil, nil)
+}
+
+func (remote *Remote) Next() error {
+	return remote.call(MethodNext, nil, nil)
+}
+
+func (remote *Remote) Previous() error {
+	return remote.call(MethodPrevious, nil, nil)
+}
+
+func (remote *Remote) Seek(position time.Duration) error {
+	return remote.call(MethodSeek, SeekParams{Seconds: position.Seconds()}, nil)
+}
+
+func (remote *Remote) SetVolume(volume int) {
+	_ = remote.call(MethodVolume, VolumeParams{Volume: &volume}, nil)
+}
+
+func (remote *Remote) add(tracks []engine.Track, next bool, play bool) error {
+	if len(tracks) == 0 {
+		return nil
+	}
+
+	params := QueueAddParams{Tracks: make([]Track, len(tracks)), Next: next, Play: play}
+	for i, track := range tracks {
+		params.Tracks[i] = toTrack(track)
+	}
+
+	return remote.call(MethodQueueAdd, params, nil)
+}
+
+func (remote *Remote) Append(tracks ...engine.Track) error {
+	return remote.add(tracks, false, false)
+}
+
+func (remote *Remote) InsertNext(tracks ...engine.Track) error {
+	return remote.add(tracks, true, false)
+}
+
+func (remote *Remote) PlayNow(tracks ...engine.Track) error {
+	return remote.add(tracks, false, true)
+}
+
+func (remote *Remote) Move(from int, to int) error {
+	return remote.call(MethodQueueMove, QueueMoveParams{From: from, To: to}, nil)
+}
+
+func (remote *Remote) Remove(index int) error {
+	return remote.call(MethodQueueRemove, QueueRemoveParams{Index: index}, nil)
+}
+
+func (remote *Remote) Clear() error {
+	return remote.call(MethodQueueClear, nil, nil)
+}
+
+// Refresh does nothing, the daemon keeps its own library up to date
+func (remote *Remote) Refresh(lib *library.Library) {}
+
+func (remote *Remote) Close() error {
+	remote.events.Close()
+	return remote.calls.Close()
+}
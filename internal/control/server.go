@@ -0,0 +1,366 @@
+package control
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	engine "wired/internal/engine"
+)
+
+// requests are single lines, a huge queue.add is the biggest thing expected
+const maxRequestSize = 4 * 1024 * 1024
+
+var ErrAlreadyRunning = errors.New("another wired is already listening on the control socket")
+
+type Server struct {
+	engine   *engine.Engine
+	listener net.Listener
+	path     string
+	conns    map[net.Conn]struct{}
+	closed   bool
+	mutex    sync.Mutex
+}
+
+// Listen opens the control socket, a socket left behind by a crashed wired is replaced
+func Listen(player *engine.Engine) (*Server, error) {
+	path := SocketPath()
+
+	if err := os.MkdirAll(filepath.Dir(path), dirPerm); err != nil {
+		return nil, err
+	}
+
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, ErrAlreadyRunning
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	server := &Server{
+		engine:   player,
+		listener: listener,
+		path:     path,
+		conns:    map[net.Conn]struct{}{},
+	}
+
+	go server.accept()
+
+	return server, nil
+}
+
+func (server *Server) Close() error {
+	server.mutex.Lock()
+	server.closed = true
+
+	for conn := range server.conns {
+		conn.Close()
+	}
+
+	server.mutex.Unlock()
+
+	err := server.listener.Close()
+	_ = os.Remove(server.path)
+
+	return err
+}
+
+func (server *Server) accept() {
+	for {
+		conn, err := server.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		server.mutex.Lock()
+		if server.closed {
+			server.mutex.Unlock()
+			conn.Close()
+
+			return
+		}
+
+		server.conns[conn] = struct{}{}
+		server.mutex.Unlock()
+
+		go server.serve(conn)
+	}
+}
+
+// connection serializes writes, events and responses may be sent at the same time
+type connection struct {
+	conn    net.Conn
+	encoder *json.Encoder
+	mutex   sync.Mutex
+}
+
+func (connection *connection) send(response Response) error {
+	connection.mutex.Lock()
+	defer connection.mutex.Unlock()
+
+	response.JSONRPC = jsonrpcVersion
+
+	return connection.encoder.Encode(response)
+}
+
+func (server *Server) serve(conn net.Conn) {
+	connection := &connection{conn: conn, encoder: json.NewEncoder(conn)}
+	unsubscribe := func() {}
+
+	defer func() {
+		unsubscribe()
+		conn.Close()
+
+		server.mutex.Lock()
+		delete(server.conns, conn)
+		server.mutex.Unlock()
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), maxRequestSize)
+
+	for scanner.Scan() {
+		var request Request
+		if err := json.Unmarshal(scanner.Bytes(), &request); err != nil {
+			connection.send(Response{Error: &Error{Code: codeParseError, Message: err.Error()}})
+			continue
+		}
+
+		if request.JSONRPC != jsonrpcVersion || request.Method == "" {
+			connection.send(Response{ID: request.ID, Error: &Error{Code: codeInvalidRequest, Message: "not a json-rpc 2.0 request"}})
+			continue
+		}
+
+		var result any
+		var err *Error
+
+		if request.Method == MethodSubscribe {
+			unsubscribe()
+			unsubscribe = server.subscribe(connection)
+			result = true
+		} else {
+			result, err = server.handle(request)
+		}
+
+		// requests without an id are notifications and get no answer
+		if request.ID == nil {
+			continue
+		}
+
+		response := Response{ID: request.ID, Error: err}
+		if err == nil {
+			data, marshalErr := json.Marshal(result)
+			if marshalErr != nil {
+				response.Error = &Error{Code: codeServerError, Message: marshalErr.Error()}
+			} else {
+				response.Result = data
+			}
+		}
+
+		if connection.send(response) != nil {
+			return
+		}
+	}
+}
+
+// subscribe forwards every engine event to the connection until it goes away
+func (server *Server) subscribe(connection *connection) func() {
+	events, stop := server.engine.Subscribe()
+
+	go func() {
+		for event := range events {
+			params, err := json.Marshal(newEvent(event))
+			if err != nil {
+				continue
+			}
+
+			if connection.send(Response{Method: NotificationEvent, Params: params}) != nil {
+				// the reader notices the broken connection and unsubscribes
+				connection.conn.Close()
+			}
+		}
+	}()
+
+	return stop
+}
+
+func (server *Server) handle(request Request) (any, *Error) {
+	player := server.engine
+
+	switch request.Method {
+	case MethodPlay:
+		var params PlayParams
+		if err := decodeParams(request.Params, &params); err != nil {
+			return nil, err
+		}
+
+		if params.Index != nil {
+			return true, serverError(player.PlayIndex(*params.Index))
+		}
+
+		return true, serverError(player.Play())
+
+	case MethodPause:
+		player.Pause()
+		return true, nil
+
+	case MethodToggle:
+		return true, serverError(player.TogglePause())
+
+	case MethodStop:
+		player.Stop()
+		return true, nil
+
+	case MethodNext:
+		return true, serverError(player.Next())
+
+	case MethodPrevious:
+		return true, serverError(player.Previous())
+
+	case MethodSeek:
+		var params SeekParams
+		if err := decodeParams(request.Params, &params); err != nil {
+			return nil, err
+		}
+
+		position := time.Duration(params.Seconds * float64(time.Second))
+		if params.Relative {
+			position += player.Status().Position
+		}
+
+		return true, serverError(player.Seek(position))
+
+	case MethodVolume:
+		var params VolumeParams
+		if err := decodeParams(request.Params, &params); err != nil {
+			return nil, err
+		}
+
+		if params.Volume != nil {
+			volume := *params.Volume
+			if params.Relative {
+				volume += player.Status().Volume
+			}
+
+			player.SetVolume(volume)
+		}
+
+		return player.Status().Volume, nil
+
+	case MethodQueueAdd:
+		var params QueueAddParams
+		if err := decodeParams(request.Params, &params); err != nil {
+			return nil, err
+		}
+
+		if len(params.Paths) == 0 && len(params.Tracks) == 0 {
+			return nil, &Error{Code: codeInvalidParams, Message: "paths or tracks must not be empty"}
+		}
+
+		// nothing could play them, and a queue entry without a path stands for no track at all
+		if slices.Contains(params.Paths, "") || slices.ContainsFunc(params.Tracks, func(track Track) bool { return track.Path == "" }) {
+			return nil, &Error{Code: codeInvalidParams, Message: "every path must be set"}
+		}
+
+		tracks := make([]engine.Track, 0, len(params.Paths)+len(params.Tracks))
+		for _, path := range params.Paths {
+			tracks = append(tracks, player.Resolve(path))
+		}
+
+		for _, track := range params.Tracks {
+			tracks = append(tracks, track.engineTrack())
+		}
+
+		switch {
+		case params.Play:
+			return len(tracks), serverError(player.PlayNow(tracks...))
+		case params.Next:
+			return len(tracks), serverError(player.InsertNext(tracks...))
+		default:
+			return len(tracks), serverError(player.Append(tracks...))
+		}
+
+	case MethodQueueList:
+		tracks, current := player.Queue()
+		queue := Queue{Tracks: make([]Track, len(tracks)), Current: current}
+
+		for i, track := range tracks {
+			queue.Tracks[i] = toTrack(track)
+		}
+
+		return queue, nil
+
+	case MethodQueueMove:
+		var params QueueMoveParams
+		if err := decodeParams(request.Params, &params); err != nil {
+			return nil, err
+		}
+
+		return true, serverError(player.Move(params.From, params.To))
+
+	case MethodQueueRemove:
+		var params QueueRemoveParams
+		if err := decodeParams(request.Params, &params); err != nil {
+			return nil, err
+		}
+
+		return true, serverError(player.Remove(params.Index))
+
+	case MethodQueueClear:
+		return true, serverError(player.Clear())
+
+	case MethodStatus:
+		return server.status(), nil
+
+	default:
+		return nil, &Error{Code: codeMethodNotFound, Message: "unknown method " + request.Method}
+	}
+}
+
+func (server *Server) status() Status {
+	status := server.engine.Status()
+	queue, _ := server.engine.Queue()
+
+	return Status{
+		State:    stateName(status.State),
+		Track:    newTrack(status.Track),
+		Index:    status.Index,
+		Position: status.Position.Seconds(),
+		Duration: status.Duration.Seconds(),
+		Volume:   status.Volume,
+		Queue:    len(queue),
+	}
+}
+
+func decodeParams(data json.RawMessage, params any) *Error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(data, params); err != nil {
+		return &Error{Code: codeInvalidParams, Message: err.Error()}
+	}
+
+	return nil
+}
+
+func serverError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+
+	return &Error{Code: codeServerError, Message: err.Error()}
+}
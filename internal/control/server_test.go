@@ -0,0 +1,75 @@
+package control
+
+import (
+	"errors"
+	"testing"
+
+	engine "wired/internal/engine"
+	playback "wired/internal/playback"
+)
+
+// testServer listens on a socket of the test's own, with an engine that has nothing queued
+func testServer(t *testing.T) *Client {
+	t.Helper()
+
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	// the queue is saved as it changes
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	player := engine.New(playback.NewNullOutput())
+	t.Cleanup(func() { player.Close() })
+
+	server, err := Listen(player)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { server.Close() })
+
+	client, err := Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { client.Close() })
+
+	return client
+}
+
+func TestQueueAddEmptyPath(t *testing.T) {
+	client := testServer(t)
+
+	requests := []QueueAddParams{
+		{Paths: []string{"/music/a.flac", ""}},
+		{Tracks: []Track{{Path: "/music/a.flac", Title: "a"}, {Title: "no path"}}},
+	}
+
+	for _, params := range requests {
+		var rpcErr *Error
+		if err := client.Call(MethodQueueAdd, params, nil); !errors.As(err, &rpcErr) || rpcErr.Code != codeInvalidParams {
+			t.Fatalf("adding %+v gave %v, want invalid params", params, err)
+		}
+	}
+
+	// none of them made it in, and the connection is still there to ask
+	var queue Queue
+	if err := client.Call(MethodQueueList, nil, &queue); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(queue.Tracks) != 0 {
+		t.Fatalf("queued %+v", queue.Tracks)
+	}
+
+	if err := client.Call(MethodQueueAdd, QueueAddParams{Paths: []string{"/music/a.flac"}}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.Call(MethodQueueList, nil, &queue); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(queue.Tracks) != 1 || queue.Tracks[0].Path != "/music/a.flac" {
+		t.Fatalf("queued %+v", queue.Tracks)
+	}
+}
@@ -0,0 +1,190 @@
+// Package daemon runs wired without a terminal, `wired` and `wired ctl` attach to it over the control socket
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	config "wired/internal/config"
+	control "wired/internal/control"
+	engine "wired/internal/engine"
+	library "wired/internal/library"
+	mpd "wired/internal/mpd"
+	mpris "wired/internal/mpris"
+	playback "wired/internal/playback"
+	radio "wired/internal/radio"
+	scrobbler "wired/internal/scrobbler"
+	stats "wired/internal/stats"
+)
+
+var ErrInvalidConfiguration = errors.New("the config has errors, run wired to see them")
+
+// Run plays until it gets SIGINT or SIGTERM, logging whatever goes wrong on the way
+func Run() error {
+	cfg, errs, _ := config.Load()
+	if len(errs) > 0 {
+		return errors.Join(append([]error{ErrInvalidConfiguration}, errs...)...)
+	}
+
+	var output playback.Output
+	device, err := playback.NewDeviceOutput()
+	if err != nil {
+		log.Printf("no audio output available, playing into the void: %v", err)
+		output = playback.NewNullOutput()
+	} else {
+		output = device
+	}
+
+	station := radio.New()
+	defer station.Close()
+
+	player := engine.New(station.Output(output))
+	defer player.Close()
+
+	history, err := stats.Record(player)
+	if err != nil {
+		log.Printf("couldn't read the listening history: %v", err)
+	}
+	defer history.Close()
+
+	// without the socket nobody could reach the daemon, so there's no point in going on
+	server, err := control.Listen(player)
+	if err != nil {
+		return fmt.Errorf("couldn't open the control socket: %w", err)
+	}
+	defer server.Close()
+
+	lib := loadLibrary(cfg)
+	if lib != nil {
+		player.Refresh(lib)
+	}
+
+	targets, errs := scrobbler.Targets(cfg)
+	for _, err := range errs {
+		log.Print(err)
+	}
+
+	var reports <-chan scrobbler.Report
+	if len(targets) > 0 {
+		scrobbles := scrobbler.Start(player, targets...)
+		defer scrobbles.Close()
+
+		reports = scrobbles.Reports()
+	}
+
+	if cfg.Radio.Enabled {
+		if err := station.Serve(cfg.Radio, cfg.Title, player); err != nil {
+			log.Printf("couldn't start the radio: %v", err)
+		} else {
+			log.Printf("on air at %s", station.URL())
+		}
+	}
+
+	if cfg.MPRIS.Enabled {
+		if server, err := mpris.Serve(player); err != nil {
+			log.Printf("couldn't register with the session bus: %v", err)
+		} else {
+			defer server.Close()
+		}
+	}
+
+	var mpdServer *mpd.Server
+	if cfg.MPD.Enabled {
+		if mpdServer, err = mpd.Listen(cfg.MPD, player); err != nil {
+			log.Printf("couldn't start the mpd server: %v", err)
+		} else {
+			defer mpdServer.Close()
+
+			if lib != nil {
+				mpdServer.SetLibrary(lib, cfg.MusicLibraryPath)
+			}
+		}
+	}
+
+	var changes <-chan library.WatchEvent
+	if cfg.WatchLibrary && lib != nil {
+		watcher, err := library.Watch(cfg.MusicLibraryPath, scanOptions(cfg))
+		if err != nil {
+			log.Printf("couldn't watch the library: %v", err)
+		} else {
+			defer watcher.Close()
+
+			changes = watcher.Events()
+		}
+	}
+
+	// nobody is around to close the terminal, but a hangup shouldn't take the music with it
+	signal.Ignore(syscall.SIGHUP)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	log.Printf("listening on %s", control.SocketPath())
+
+	for {
+		select {
+		case <-signals:
+			return nil
+
+		case report := <-reports:
+			if report.Error != nil {
+				log.Printf("%s: %v", report.Target, report.Error)
+			}
+
+		case event := <-changes:
+			if event.Error != nil {
+				log.Printf("watching the library: %v", event.Error)
+				continue
+			}
+
+			lib.ApplyChanges(event.Changes, scanOptions(cfg))
+			player.Refresh(lib)
+
+			if mpdServer != nil {
+				mpdServer.SetLibrary(lib, cfg.MusicLibraryPath)
+			}
+
+			if err := lib.SaveCache(); err != nil {
+				log.Printf("failed to save library cache: %v", err)
+			}
+		}
+	}
+}
+
+func scanOptions(cfg *config.Config) library.ScanOptions {
+	return library.ScanOptions{CoverMinBytes: int64(cfg.Covers.MinImageBytes)}
+}
+
+// loadLibrary rescans the library on top of the cache, only files that changed get read again
+func loadLibrary(cfg *config.Config) *library.Library {
+	cached := library.LoadLibrary()
+	if cfg.MusicLibraryPath == "" {
+		return cached
+	}
+
+	// Scan blocks on every progress update, nobody here wants them
+	progress := make(chan library.ScanProgress)
+	go func() {
+		for range progress {
+		}
+	}()
+
+	lib, _, err := library.Scan(context.Background(), cfg.MusicLibraryPath, cached, scanOptions(cfg), progress)
+	close(progress)
+
+	if err != nil {
+		log.Printf("couldn't scan the library: %v", err)
+		return cached
+	}
+
+	if err := lib.SaveCache(); err != nil {
+		log.Printf("failed to save library cache: %v", err)
+	}
+
+	return lib
+}
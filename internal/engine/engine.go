@@ -0,0 +1,648 @@
+// Package engine ties the player to the play queue, every frontend drives playback through it
+package engine
+
+import (
+	"math"
+	"path/filepath"
+	"sync"
+	"time"
+
+	library "wired/internal/library"
+	playback "wired/internal/playback"
+)
+
+const (
+	dirPerm  = 0o755
+	filePerm = 0o644
+)
+
+const (
+	// going back further into a track than this restarts it instead of playing the previous one
+	restartThreshold = 3 * time.Second
+	// subscribers that fall this far behind start missing events
+	subscriberBuffer = 64
+)
+
+type EventType int
+
+const (
+	TrackStarted EventType = iota
+	// TrackEnded is sent whenever a track stops playing, whether it reached its end or not
+	TrackEnded
+	TrackFailed
+	StateChanged
+	QueueChanged
+	// MetadataChanged is sent when a stream announces what it's playing, see Track.StreamTitle
+	MetadataChanged
+	// Seeked is sent when the position jumps rather than moving along with playback
+	Seeked
+)
+
+type Event struct {
+	Type  EventType
+	Track Track
+	// when the track started and how long it was actually heard, pauses and seeks aside,
+	// both are only set on TrackEnded
+	Started   time.Time
+	Listened  time.Duration
+	Completed bool
+	Error     error
+}
+
+type Status struct {
+	State    playback.State
+	Track    Track // zero while stopped
+	Index    int   // -1 while stopped
+	Position time.Duration
+	Duration time.Duration
+	Volume   int // percent
+}
+
+type Engine struct {
+	player *playback.Player
+	queue  queue
+	// the player stops on its own at the end of a track, state is what the engine last asked for
+	state        playback.State
+	playing      Track
+	listened     time.Duration
+	startedAt    time.Time
+	playingSince time.Time
+	subscribers  map[chan Event]bool
+	// a copy of the library's songs, frontends can't read the library while the ui changes it
+	known map[string]Track
+	// bumped whenever a track is picked or playback stops, so a track still being opened
+	// knows when it isn't wanted anymore
+	requests int
+	opening  *pending
+	done     chan struct{}
+	mutex    sync.Mutex
+}
+
+// pending is a track picked to be played, which is opened once the lock is released
+type pending struct {
+	index   int
+	track   Track
+	request int
+}
+
+func New(output playback.Output) *Engine {
+	engine := &Engine{
+		player:      playback.NewPlayer(output),
+		queue:       loadQueue(),
+		subscribers: map[chan Event]bool{},
+		done:        make(chan struct{}),
+	}
+
+	go engine.run()
+
+	return engine
+}
+
+// Subscribe returns a channel receiving every engine event and a function to stop receiving them,
+// which also closes the channel
+func (engine *Engine) Subscribe() (<-chan Event, func()) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	channel := make(chan Event, subscriberBuffer)
+	engine.subscribers[channel] = true
+
+	return channel, func() {
+		engine.mutex.Lock()
+		defer engine.mutex.Unlock()
+
+		if engine.subscribers[channel] {
+			delete(engine.subscribers, channel)
+			close(channel)
+		}
+	}
+}
+
+func (engine *Engine) emit(event Event) {
+	for channel := range engine.subscribers {
+		select {
+		case channel <- event:
+		default:
+		}
+	}
+}
+
+func (engine *Engine) run() {
+	for {
+		select {
+		case <-engine.done:
+			return
+		case event := <-engine.player.Events():
+			engine.handlePlayerEvent(event)
+		}
+	}
+}
+
+func (engine *Engine) handlePlayerEvent(event playback.Event) {
+	engine.mutex.Lock()
+
+	if event.Type == playback.TitleChanged {
+		if engine.state != playback.Stopped && event.Path == engine.playing.Path {
+			engine.playing.StreamTitle = event.Title
+			engine.emit(Event{Type: MetadataChanged, Track: engine.playing})
+		}
+
+		engine.mutex.Unlock()
+
+		return
+	}
+
+	// another track was started before this event got here
+	if engine.state == playback.Stopped || engine.player.State() != playback.Stopped || event.Path != engine.playing.Path {
+		engine.mutex.Unlock()
+		return
+	}
+
+	if event.Type == playback.TrackFailed {
+		engine.emit(Event{Type: TrackFailed, Track: engine.playing, Error: event.Error})
+	}
+
+	engine.endLocked(event.Type == playback.TrackFinished)
+
+	if !engine.queue.valid(engine.queue.current + 1) {
+		engine.emit(Event{Type: StateChanged})
+		engine.mutex.Unlock()
+
+		return
+	}
+
+	next := engine.pickLocked(engine.queue.current + 1)
+	engine.mutex.Unlock()
+
+	// unplayable tracks are reported and skipped rather than stopping the whole queue
+	for engine.play(next) != nil {
+		engine.mutex.Lock()
+
+		if next.request != engine.requests || !engine.queue.valid(engine.queue.current+1) {
+			engine.mutex.Unlock()
+			return
+		}
+
+		next = engine.pickLocked(engine.queue.current + 1)
+		engine.mutex.Unlock()
+	}
+}
+
+// endLocked reports the track that was playing as done
+func (engine *Engine) endLocked(completed bool) {
+	if engine.state == playback.Stopped {
+		return
+	}
+
+	if engine.state == playback.Playing {
+		engine.listened += time.Since(engine.playingSince)
+	}
+
+	engine.emit(Event{
+		Type:      TrackEnded,
+		Track:     engine.playing,
+		Started:   engine.startedAt,
+		Listened:  engine.listened,
+		Completed: completed,
+	})
+
+	engine.state = playback.Stopped
+	engine.playing = Track{}
+	engine.listened = 0
+}
+
+// pickLocked chooses the track at index to be played next, play then opens it without the lock held
+func (engine *Engine) pickLocked(index int) *pending {
+	engine.requests++
+	engine.opening = &pending{index: index, track: engine.queue.tracks[index], request: engine.requests}
+
+	return engine.opening
+}
+
+// cursorLocked is where next and previous go from, the track being opened counts as current already
+func (engine *Engine) cursorLocked() int {
+	if engine.opening != nil {
+		return engine.opening.index
+	}
+
+	return engine.queue.current
+}
+
+// play opens a picked track outside the lock, a stream can take seconds to connect and nobody
+// should wait on that to ask for the status, then swaps it in unless something else was picked,
+// or playback stopped, in the meantime
+func (engine *Engine) play(next *pending) error {
+	if next == nil {
+		return nil
+	}
+
+	decoder, err := playback.Open(next.track.Path)
+
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	// it may have moved while it was opening, or be gone altogether
+	index := engine.queue.find(next.index, next.track.Path)
+
+	if next.request != engine.requests {
+		if decoder != nil {
+			decoder.Close()
+		}
+
+		return nil
+	}
+
+	engine.opening = nil
+
+	if index < 0 {
+		if decoder != nil {
+			decoder.Close()
+		}
+
+		return nil
+	}
+
+	engine.endLocked(false)
+
+	track := engine.queue.tracks[index]
+	engine.queue.current = index
+
+	if err == nil {
+		err = engine.player.PlayDecoder(track.Path, decoder)
+	}
+
+	if err != nil {
+		engine.player.Stop()
+		engine.emit(Event{Type: TrackFailed, Track: track, Error: err})
+		engine.emit(Event{Type: StateChanged})
+
+		return err
+	}
+
+	engine.state = playback.Playing
+	engine.playing = track
+	engine.startedAt = time.Now()
+	engine.playingSince = engine.startedAt
+
+	engine.emit(Event{Type: TrackStarted, Track: track})
+	engine.emit(Event{Type: StateChanged})
+
+	return engine.queue.save()
+}
+
+func (engine *Engine) stopLocked() {
+	// whatever is still being opened isn't wanted anymore either
+	engine.requests++
+	engine.opening = nil
+
+	if engine.state == playback.Stopped {
+		return
+	}
+
+	engine.endLocked(false)
+	engine.player.Stop()
+	engine.emit(Event{Type: StateChanged})
+}
+
+func (engine *Engine) PlayIndex(index int) error {
+	engine.mutex.Lock()
+
+	if !engine.queue.valid(index) {
+		engine.mutex.Unlock()
+		return nil
+	}
+
+	next := engine.pickLocked(index)
+	engine.mutex.Unlock()
+
+	return engine.play(next)
+}
+
+// TogglePause also starts the queue when nothing is playing
+func (engine *Engine) TogglePause() error {
+	engine.mutex.Lock()
+
+	if engine.state == playback.Playing {
+		engine.pauseLocked()
+		engine.mutex.Unlock()
+
+		return nil
+	}
+
+	next := engine.resumeLocked()
+	engine.mutex.Unlock()
+
+	return engine.play(next)
+}
+
+// Play resumes a paused track or starts the queue, it does nothing while already playing
+func (engine *Engine) Play() error {
+	engine.mutex.Lock()
+
+	if engine.state == playback.Playing {
+		engine.mutex.Unlock()
+		return nil
+	}
+
+	next := engine.resumeLocked()
+	engine.mutex.Unlock()
+
+	return engine.play(next)
+}
+
+func (engine *Engine) Pause() {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	if engine.state == playback.Playing {
+		engine.pauseLocked()
+	}
+}
+
+func (engine *Engine) pauseLocked() {
+	engine.listened += time.Since(engine.playingSince)
+	engine.player.Pause()
+	engine.state = playback.Paused
+
+	engine.emit(Event{Type: StateChanged})
+}
+
+// resumeLocked picks a paused track back up, or picks where the queue starts when stopped
+func (engine *Engine) resumeLocked() *pending {
+	if engine.state == playback.Paused {
+		engine.playingSince = time.Now()
+		engine.player.Resume()
+		engine.state = playback.Playing
+
+		engine.emit(Event{Type: StateChanged})
+
+		return nil
+	}
+
+	index := max(engine.queue.current, 0)
+	if !engine.queue.valid(index) {
+		return nil
+	}
+
+	return engine.pickLocked(index)
+}
+
+func (engine *Engine) Stop() {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	engine.stopLocked()
+}
+
+func (engine *Engine) Next() error {
+	engine.mutex.Lock()
+
+	if !engine.queue.valid(engine.cursorLocked() + 1) {
+		engine.stopLocked()
+		engine.mutex.Unlock()
+
+		return nil
+	}
+
+	next := engine.pickLocked(engine.cursorLocked() + 1)
+	engine.mutex.Unlock()
+
+	return engine.play(next)
+}
+
+func (engine *Engine) Previous() error {
+	engine.mutex.Lock()
+
+	if engine.opening == nil && engine.state != playback.Stopped && engine.player.Position() > restartThreshold {
+		defer engine.mutex.Unlock()
+		return engine.seekLocked(0)
+	}
+
+	if !engine.queue.valid(engine.cursorLocked() - 1) {
+		defer engine.mutex.Unlock()
+
+		if engine.opening == nil && engine.state != playback.Stopped {
+			return engine.seekLocked(0)
+		}
+
+		return nil
+	}
+
+	next := engine.pickLocked(engine.cursorLocked() - 1)
+	engine.mutex.Unlock()
+
+	return engine.play(next)
+}
+
+func (engine *Engine) Seek(position time.Duration) error {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	if engine.state == playback.Stopped {
+		return playback.ErrNothingLoaded
+	}
+
+	return engine.seekLocked(position)
+}
+
+func (engine *Engine) seekLocked(position time.Duration) error {
+	if err := engine.player.Seek(position); err != nil {
+		return err
+	}
+
+	engine.emit(Event{Type: Seeked, Track: engine.playing})
+
+	return nil
+}
+
+// SetVolume takes a percentage, clamped to 0-100
+func (engine *Engine) SetVolume(volume int) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	engine.player.SetVolume(float32(max(0, min(100, volume))) / 100)
+	engine.emit(Event{Type: StateChanged})
+}
+
+func (engine *Engine) volumeLocked() int {
+	return int(math.Round(float64(engine.player.Volume()) * 100))
+}
+
+func (engine *Engine) Status() Status {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	if engine.state == playback.Stopped {
+		return Status{State: playback.Stopped, Index: -1, Volume: engine.volumeLocked()}
+	}
+
+	return Status{
+		State:    engine.state,
+		Track:    engine.playing,
+		Index:    engine.queue.current,
+		Position: engine.player.Position(),
+		Duration: engine.player.Duration(),
+		Volume:   engine.volumeLocked(),
+	}
+}
+
+// Queue returns a copy of the queue and the index of the current track
+func (engine *Engine) Queue() ([]Track, int) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	return engine.queue.snapshot()
+}
+
+func (engine *Engine) Append(tracks ...Track) error {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	engine.queue.append(tracks...)
+
+	return engine.queueChangedLocked()
+}
+
+func (engine *Engine) InsertNext(tracks ...Track) error {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	engine.queue.insertNext(tracks...)
+
+	return engine.queueChangedLocked()
+}
+
+// PlayNow inserts the tracks after the current one and jumps to the first of them
+func (engine *Engine) PlayNow(tracks ...Track) error {
+	engine.mutex.Lock()
+
+	if len(tracks) == 0 {
+		engine.mutex.Unlock()
+		return nil
+	}
+
+	index := engine.queue.insertNext(tracks...)
+
+	if err := engine.queueChangedLocked(); err != nil {
+		engine.mutex.Unlock()
+		return err
+	}
+
+	next := engine.pickLocked(index)
+	engine.mutex.Unlock()
+
+	return engine.play(next)
+}
+
+func (engine *Engine) Move(from int, to int) error {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	if !engine.queue.move(from, to) {
+		return nil
+	}
+
+	return engine.queueChangedLocked()
+}
+
+// Remove drops a track from the queue, the one after it starts if it was playing
+func (engine *Engine) Remove(index int) error {
+	engine.mutex.Lock()
+
+	wasCurrent := engine.queue.remove(index)
+
+	if err := engine.queueChangedLocked(); err != nil {
+		engine.mutex.Unlock()
+		return err
+	}
+
+	if !wasCurrent || engine.state == playback.Stopped {
+		engine.mutex.Unlock()
+		return nil
+	}
+
+	if !engine.queue.valid(engine.queue.current + 1) {
+		engine.stopLocked()
+		engine.mutex.Unlock()
+
+		return nil
+	}
+
+	next := engine.pickLocked(engine.queue.current + 1)
+	engine.mutex.Unlock()
+
+	return engine.play(next)
+}
+
+func (engine *Engine) Clear() error {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	engine.stopLocked()
+	engine.queue.clear()
+
+	return engine.queueChangedLocked()
+}
+
+func (engine *Engine) queueChangedLocked() error {
+	engine.emit(Event{Type: QueueChanged})
+	return engine.queue.save()
+}
+
+// Refresh updates the queued tracks with the library's metadata, e.g. after a rescan
+func (engine *Engine) Refresh(lib *library.Library) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	engine.known = make(map[string]Track, len(lib.Songs))
+
+	for path, song := range lib.Songs {
+		engine.known[path] = NewTrack(song, lib.Album(song))
+	}
+
+	changed := false
+
+	for i, track := range engine.queue.tracks {
+		refreshed, ok := engine.known[track.Path]
+		if !ok {
+			continue
+		}
+
+		if refreshed != track {
+			engine.queue.tracks[i] = refreshed
+			changed = true
+		}
+	}
+
+	if changed {
+		// failing to persist here only loses metadata updates, the next change saves them anyway
+		_ = engine.queueChangedLocked()
+	}
+}
+
+// Resolve makes a queue entry for a file or stream url, using the library's metadata when it has the file
+func (engine *Engine) Resolve(path string) Track {
+	if playback.IsStream(path) {
+		return NewStreamTrack(path, "")
+	}
+
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	if track, ok := engine.known[path]; ok {
+		return track
+	}
+
+	return Track{Path: path, Title: filepath.Base(path)}
+}
+
+// Close stops playback and releases the audio output
+func (engine *Engine) Close() error {
+	engine.mutex.Lock()
+	engine.stopLocked()
+	engine.mutex.Unlock()
+
+	close(engine.done)
+
+	return engine.player.Close()
+}
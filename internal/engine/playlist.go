@@ -0,0 +1,73 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	ErrInvalidPlaylistName = errors.New("playlist names can't be empty or contain slashes")
+	ErrEmptyPlaylist       = errors.New("there's nothing in the queue to save")
+)
+
+// SavePlaylist writes the tracks as an m3u8 playlist that any other player can open,
+// an existing playlist with the same name is replaced. It returns where the playlist went
+func SavePlaylist(name string, tracks []Track) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", ErrInvalidPlaylistName
+	}
+
+	if len(tracks) == 0 {
+		return "", ErrEmptyPlaylist
+	}
+
+	dir, err := getPlaylistsPath()
+	if err != nil {
+		return "", err
+	}
+
+	var playlist strings.Builder
+	playlist.WriteString("#EXTM3U\n")
+
+	for _, track := range tracks {
+		title := track.Title
+		if track.Artist != "" {
+			title = track.Artist + " - " + title
+		}
+
+		seconds := -1
+		if track.Duration > 0 {
+			seconds = int(track.Duration.Seconds())
+		}
+
+		fmt.Fprintf(&playlist, "#EXTINF:%d,%s\n%s\n", seconds, title, track.Path)
+	}
+
+	if err := os.MkdirAll(dir, dirPerm); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, name+".m3u8")
+
+	return path, os.WriteFile(path, []byte(playlist.String()), filePerm)
+}
+
+// playlists are made by hand and can't be rebuilt, so they go to the data dir
+func getPlaylistsPath() (string, error) {
+	dir := os.Getenv("XDG_DATA_HOME")
+
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+
+		dir = filepath.Join(home, ".local", "share")
+	}
+
+	return filepath.Join(dir, "wired", "playlists"), nil
+}
@@ -0,0 +1,248 @@
+package engine
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	library "wired/internal/library"
+)
+
+// bump when queueFile changes, older files are then ignored
+const queueVersion = 1
+
+// Track is a queue entry, it carries a copy of the song's metadata so the queue
+// can be shown and restored before the library is loaded
+type Track struct {
+	Path        string
+	Title       string
+	Artist      string
+	Album       string
+	AlbumArtist string
+	TrackNumber int
+	Duration    time.Duration
+	Cover       string
+	// what a stream says it's playing right now, never saved
+	StreamTitle string
+}
+
+type trackFile struct {
+	Path        string `json:"path"`
+	Title       string `json:"title,omitempty"`
+	Artist      string `json:"artist,omitempty"`
+	Album       string `json:"album,omitempty"`
+	AlbumArtist string `json:"album_artist,omitempty"`
+	TrackNumber int    `json:"track_number,omitempty"`
+	DurationMs  int64  `json:"duration_ms,omitempty"`
+	Cover       string `json:"cover,omitempty"`
+}
+
+type queueFile struct {
+	Version int         `json:"version"`
+	Current int         `json:"current"`
+	Tracks  []trackFile `json:"tracks"`
+}
+
+// NewTrack snapshots a library song, album may be nil when it isn't known
+func NewTrack(song *library.Song, album *library.Album) Track {
+	track := Track{
+		Path:        song.Path,
+		Title:       song.Metadata.SongName,
+		Artist:      song.Metadata.ArtistName,
+		Album:       song.Metadata.AlbumName,
+		AlbumArtist: song.Metadata.AlbumArtistName,
+		TrackNumber: song.Metadata.TrackNumber,
+		Duration:    song.Metadata.Duration,
+	}
+
+	if track.Title == "" {
+		track.Title = song.FileName
+	}
+
+	if album != nil {
+		track.Cover = album.CoverImage
+	}
+
+	return track
+}
+
+// NewStreamTrack makes a queue entry for a remote stream, name may be empty
+func NewStreamTrack(url string, name string) Track {
+	if name == "" {
+		name = url
+	}
+
+	return Track{Path: url, Title: name}
+}
+
+// queue is the list of tracks to play, current is -1 until something is played
+type queue struct {
+	tracks  []Track
+	current int
+}
+
+func (queue *queue) valid(index int) bool {
+	return index >= 0 && index < len(queue.tracks)
+}
+
+// find looks for path at index first, where it was last seen, then anywhere in the queue
+func (queue *queue) find(index int, path string) int {
+	if queue.valid(index) && queue.tracks[index].Path == path {
+		return index
+	}
+
+	return slices.IndexFunc(queue.tracks, func(track Track) bool {
+		return track.Path == path
+	})
+}
+
+func (queue *queue) append(tracks ...Track) {
+	queue.tracks = append(queue.tracks, tracks...)
+}
+
+// insertNext puts the tracks right after the current one, or at the top when nothing played yet
+func (queue *queue) insertNext(tracks ...Track) int {
+	index := queue.current + 1
+	queue.tracks = slices.Insert(queue.tracks, index, tracks...)
+
+	return index
+}
+
+func (queue *queue) move(from int, to int) bool {
+	if !queue.valid(from) || !queue.valid(to) || from == to {
+		return false
+	}
+
+	track := queue.tracks[from]
+	queue.tracks = slices.Delete(queue.tracks, from, from+1)
+	queue.tracks = slices.Insert(queue.tracks, to, track)
+
+	switch {
+	case queue.current == from:
+		queue.current = to
+	case from < queue.current && to >= queue.current:
+		queue.current--
+	case from > queue.current && to <= queue.current:
+		queue.current++
+	}
+
+	return true
+}
+
+// remove drops a track, returning whether it was the current one
+func (queue *queue) remove(index int) bool {
+	if !queue.valid(index) {
+		return false
+	}
+
+	queue.tracks = slices.Delete(queue.tracks, index, index+1)
+
+	if index < queue.current {
+		queue.current--
+		return false
+	}
+
+	if index == queue.current {
+		// the next track slides into the current spot, step back so playing "next" picks it
+		queue.current--
+		return true
+	}
+
+	return false
+}
+
+func (queue *queue) clear() {
+	queue.tracks = nil
+	queue.current = -1
+}
+
+func (queue *queue) snapshot() ([]Track, int) {
+	return slices.Clone(queue.tracks), queue.current
+}
+
+func (queue *queue) save() error {
+	path, err := getQueuePath()
+	if err != nil {
+		return err
+	}
+
+	file := queueFile{
+		Version: queueVersion,
+		Current: queue.current,
+		Tracks:  make([]trackFile, len(queue.tracks)),
+	}
+
+	for i, track := range queue.tracks {
+		file.Tracks[i] = trackFile{
+			Path:        track.Path,
+			Title:       track.Title,
+			Artist:      track.Artist,
+			Album:       track.Album,
+			AlbumArtist: track.AlbumArtist,
+			TrackNumber: track.TrackNumber,
+			DurationMs:  track.Duration.Milliseconds(),
+			Cover:       track.Cover,
+		}
+	}
+
+	data, err := json.MarshalIndent(file, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), dirPerm); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, filePerm)
+}
+
+func loadQueue() queue {
+	loaded := queue{current: -1}
+
+	path, err := getQueuePath()
+	if err != nil {
+		return loaded
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return loaded
+	}
+
+	var file queueFile
+	if err := json.Unmarshal(data, &file); err != nil || file.Version != queueVersion {
+		return loaded
+	}
+
+	for _, track := range file.Tracks {
+		loaded.tracks = append(loaded.tracks, Track{
+			Path:        track.Path,
+			Title:       track.Title,
+			Artist:      track.Artist,
+			Album:       track.Album,
+			AlbumArtist: track.AlbumArtist,
+			TrackNumber: track.TrackNumber,
+			Duration:    time.Duration(track.DurationMs) * time.Millisecond,
+			Cover:       track.Cover,
+		})
+	}
+
+	if loaded.valid(file.Current) {
+		loaded.current = file.Current
+	}
+
+	return loaded
+}
+
+// the queue lives next to the library cache
+func getQueuePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "wired", "queue.json"), nil
+}
@@ -4,24 +4,38 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 const (
 	dirPerm      = 0o755
 	filePerm     = 0o644
-	cacheVersion = 7
+	cacheVersion = 10
 )
 
 type SongCache struct {
-	FileName   string `json:"file_name"`
-	SongName   string `json:"song_name"`
-	ArtistName string `json:"artist_name"`
-	AlbumName  string `json:"album_name"`
+	FileName        string `json:"file_name"`
+	ModTime         int64  `json:"mod_time"` // unix nanoseconds
+	Size            int64  `json:"size"`
+	SongName        string `json:"song_name"`
+	ArtistName      string `json:"artist_name"`
+	AlbumName       string `json:"album_name"`
+	AlbumArtistName string `json:"album_artist_name,omitempty"`
+	ComposerName    string `json:"composer_name,omitempty"`
+	Genre           string `json:"genre,omitempty"`
+	Year            int    `json:"year,omitempty"`
+	TrackNumber     int    `json:"track_number,omitempty"`
+	TrackTotal      int    `json:"track_total,omitempty"`
+	DiscNumber      int    `json:"disc_number,omitempty"`
+	DiscTotal       int    `json:"disc_total,omitempty"`
+	DurationMs      int64  `json:"duration_ms"`
+	EmbeddedCover   string `json:"embedded_cover,omitempty"`
 }
 
 type LibraryCache struct {
-	Version int                   `json:"version"`
-	Songs   map[string]*SongCache `json:"songs"` // file_path -> song metadata
+	Version int                          `json:"version"`
+	Songs   map[string]*SongCache        `json:"songs"`  // file_path -> song metadata
+	Covers  map[string]map[string]string `json:"covers"` // artist_name -> album_name -> cover path
 }
 
 func (library *Library) SaveCache() error {
@@ -33,14 +47,41 @@ func (library *Library) SaveCache() error {
 	cache := LibraryCache{
 		Version: cacheVersion,
 		Songs:   make(map[string]*SongCache, len(library.Songs)),
+		Covers:  make(map[string]map[string]string, len(library.Artists)),
+	}
+
+	for artistName, artist := range library.Artists {
+		for _, album := range artist.Albums {
+			if album.CoverImage == "" {
+				continue
+			}
+
+			if cache.Covers[artistName] == nil {
+				cache.Covers[artistName] = map[string]string{}
+			}
+
+			cache.Covers[artistName][album.AlbumName] = album.CoverImage
+		}
 	}
 
 	for filePath, song := range library.Songs {
 		cache.Songs[filePath] = &SongCache{
-			FileName:   song.FileName,
-			SongName:   song.Metadata.SongName,
-			ArtistName: song.Metadata.ArtistName,
-			AlbumName:  song.Metadata.AlbumName,
+			FileName:        song.FileName,
+			ModTime:         song.ModTime.UnixNano(),
+			Size:            song.Size,
+			SongName:        song.Metadata.SongName,
+			ArtistName:      song.Metadata.ArtistName,
+			AlbumName:       song.Metadata.AlbumName,
+			AlbumArtistName: song.Metadata.AlbumArtistName,
+			ComposerName:    song.Metadata.ComposerName,
+			Genre:           song.Metadata.Genre,
+			Year:            song.Metadata.Year,
+			TrackNumber:     song.Metadata.TrackNumber,
+			TrackTotal:      song.Metadata.TrackTotal,
+			DiscNumber:      song.Metadata.DiscNumber,
+			DiscTotal:       song.Metadata.DiscTotal,
+			DurationMs:      song.Metadata.Duration.Milliseconds(),
+			EmbeddedCover:   song.Metadata.EmbeddedCover,
 		}
 	}
 
@@ -85,16 +126,39 @@ func LoadCache() *Library {
 	for filePath, cached := range cache.Songs {
 		song := &Song{
 			FileName: cached.FileName,
+			ModTime:  time.Unix(0, cached.ModTime),
+			Size:     cached.Size,
 			Metadata: SongMetadata{
-				SongName:   cached.SongName,
-				ArtistName: cached.ArtistName,
-				AlbumName:  cached.AlbumName,
+				SongName:        cached.SongName,
+				ArtistName:      cached.ArtistName,
+				AlbumName:       cached.AlbumName,
+				AlbumArtistName: cached.AlbumArtistName,
+				ComposerName:    cached.ComposerName,
+				Genre:           cached.Genre,
+				Year:            cached.Year,
+				TrackNumber:     cached.TrackNumber,
+				TrackTotal:      cached.TrackTotal,
+				DiscNumber:      cached.DiscNumber,
+				DiscTotal:       cached.DiscTotal,
+				Duration:        time.Duration(cached.DurationMs) * time.Millisecond,
+				EmbeddedCover:   cached.EmbeddedCover,
 			},
 		}
 
 		library.AddSong(filePath, song)
 	}
 
+	for artistName, albums := range cache.Covers {
+		artist, ok := library.Artists[artistName]
+		if !ok {
+			continue
+		}
+
+		for _, album := range artist.Albums {
+			album.CoverImage = albums[album.AlbumName]
+		}
+	}
+
 	return library
 }
 
@@ -0,0 +1,198 @@
+package library
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/dhowden/tag"
+)
+
+var coverExtensions = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+}
+
+// sidecar file names in order of preference, matched without their extension
+var coverNames = []string{"cover", "folder", "front", "album", "albumart"}
+
+var pictureExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/jpg":  ".jpg",
+	"image/png":  ".png",
+}
+
+// extractEmbeddedCover writes an embedded picture into the covers cache, named after its content
+// so every song of an album carrying the same artwork shares one file
+func extractEmbeddedCover(picture *tag.Picture, minBytes int64) string {
+	if picture == nil || int64(len(picture.Data)) < minBytes || len(picture.Data) == 0 {
+		return ""
+	}
+
+	extension, ok := pictureExtensions[strings.ToLower(picture.MIMEType)]
+	if !ok {
+		extension = "." + strings.ToLower(picture.Ext)
+		if !coverExtensions[extension] {
+			return ""
+		}
+	}
+
+	dir, err := getCoversPath()
+	if err != nil {
+		return ""
+	}
+
+	sum := sha256.Sum256(picture.Data)
+	path := filepath.Join(dir, hex.EncodeToString(sum[:])+extension)
+
+	if _, err := os.Stat(path); err == nil {
+		return path
+	}
+
+	if err := os.MkdirAll(dir, dirPerm); err != nil {
+		return ""
+	}
+
+	// written through a temp file so a half-written cover is never picked up
+	tmp, err := os.CreateTemp(dir, "cover-*")
+	if err != nil {
+		return ""
+	}
+
+	_, writeErr := tmp.Write(picture.Data)
+	closeErr := tmp.Close()
+
+	if writeErr != nil || closeErr != nil || os.Rename(tmp.Name(), path) != nil {
+		os.Remove(tmp.Name())
+		return ""
+	}
+
+	return path
+}
+
+// resolveCovers picks the cover again for albums that gained or lost songs, a removed song
+// may have been the one with the artwork. albums that are gone have nothing to resolve
+func (library *Library) resolveCovers(touched map[albumKey]bool, options ScanOptions) {
+	for key := range touched {
+		if album := library.findAlbum(key); album != nil {
+			album.CoverImage = resolveCover(album, options.CoverMinBytes)
+		}
+	}
+}
+
+// carryCovers keeps the covers previous had for albums whose songs are all the same, reading
+// the directories of every album again would make a rescan as slow as the first scan. albums
+// that are gone aren't in library anymore, so their covers are left behind with them
+func (library *Library) carryCovers(previous *Library, touched map[albumKey]bool, options ScanOptions) {
+	for artistName, artist := range library.Artists {
+		for _, album := range artist.Albums {
+			key := albumKey{artistName, album.AlbumName}
+
+			var before *Album
+			if previous != nil && !touched[key] {
+				before = previous.findAlbum(key)
+			}
+
+			if before == nil {
+				album.CoverImage = resolveCover(album, options.CoverMinBytes)
+				continue
+			}
+
+			album.CoverImage = before.CoverImage
+		}
+	}
+}
+
+// resolveCover prefers embedded artwork, then well known sidecar files and lastly
+// any other big enough picture lying in the album directories
+func resolveCover(album *Album, minBytes int64) string {
+	for _, song := range album.Songs {
+		if song.Metadata.EmbeddedCover == "" {
+			continue
+		}
+
+		if _, err := os.Stat(song.Metadata.EmbeddedCover); err == nil {
+			return song.Metadata.EmbeddedCover
+		}
+	}
+
+	var dirs []string
+
+	for _, song := range album.Songs {
+		dir := filepath.Dir(song.Path)
+		if !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	// multi-disc albums usually keep the artwork next to the CD1/CD2 folders
+	for _, dir := range dirs {
+		name := strings.ToLower(filepath.Base(dir))
+		parent := filepath.Dir(dir)
+
+		if (strings.HasPrefix(name, "cd") || strings.HasPrefix(name, "disc")) && !slices.Contains(dirs, parent) {
+			dirs = append(dirs, parent)
+		}
+	}
+
+	var fallback string
+	var fallbackSize int64
+
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		sidecarRank := len(coverNames)
+		var sidecar string
+
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+
+			extension := strings.ToLower(filepath.Ext(entry.Name()))
+			if !coverExtensions[extension] {
+				continue
+			}
+
+			info, err := entry.Info()
+			if err != nil || info.Size() < minBytes {
+				continue
+			}
+
+			path := filepath.Join(dir, entry.Name())
+			base := strings.ToLower(strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name())))
+
+			if rank := slices.Index(coverNames, base); rank >= 0 && rank < sidecarRank {
+				sidecarRank = rank
+				sidecar = path
+			}
+
+			if info.Size() > fallbackSize {
+				fallbackSize = info.Size()
+				fallback = path
+			}
+		}
+
+		if sidecar != "" {
+			return sidecar
+		}
+	}
+
+	return fallback
+}
+
+func getCoversPath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "wired", "covers"), nil
+}
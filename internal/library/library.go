@@ -3,15 +3,20 @@
 package library
 
 import (
+	"cmp"
 	"context"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/dhowden/tag"
+
+	playback "wired/internal/playback"
 )
 
 var audioExtensions = map[string]bool{
@@ -22,24 +27,58 @@ var audioExtensions = map[string]bool{
 	".wav":  true,
 }
 
+type scanEntry struct {
+	path    string
+	modTime time.Time
+	size    int64
+}
+
 type songScanResult struct {
-	path string
-	song *Song
+	path    string
+	song    *Song
+	updated bool
+}
+
+type ScanOptions struct {
+	// CoverMinBytes skips pictures smaller than this, which are usually thumbnails
+	CoverMinBytes int64
+}
+
+// ScanProgress counts files processed so far and how they differ from the previous library
+type ScanProgress struct {
+	Current int
+	Added   int
+	Updated int
+	Removed int
 }
 
 type FileScanningResult struct {
-	Library *Library
-	Error   error
+	Library  *Library
+	Progress ScanProgress
+	Error    error
 }
 
 type SongMetadata struct {
-	SongName   string
-	ArtistName string
-	AlbumName  string
+	SongName        string
+	ArtistName      string
+	AlbumName       string
+	AlbumArtistName string
+	ComposerName    string
+	Genre           string
+	Year            int
+	TrackNumber     int
+	TrackTotal      int
+	DiscNumber      int
+	DiscTotal       int
+	Duration        time.Duration
+	EmbeddedCover   string // embedded picture extracted into the covers cache
 }
 
 type Song struct {
+	Path     string
 	FileName string
+	ModTime  time.Time
+	Size     int64
 	Metadata SongMetadata
 }
 
@@ -67,6 +106,7 @@ func New() *Library {
 }
 
 func (library *Library) AddSong(filePath string, song *Song) {
+	song.Path = filePath
 	library.Songs[filePath] = song
 
 	artistName := song.Metadata.ArtistName
@@ -95,7 +135,134 @@ func (library *Library) AddSong(filePath string, song *Song) {
 		artist.Albums = append(artist.Albums, album)
 	}
 
-	album.Songs = append(album.Songs, song)
+	// keep albums in play order, binary insertion is cheap enough even when scanning
+	index, _ := slices.BinarySearchFunc(album.Songs, song, compareSongOrder)
+	album.Songs = slices.Insert(album.Songs, index, song)
+}
+
+// Album returns the album the song was filed under, nil when it isn't part of the library
+func (library *Library) Album(song *Song) *Album {
+	return library.findAlbum(albumKey{song.Metadata.ArtistName, song.Metadata.AlbumName})
+}
+
+// albumKey is how albums are told apart, by name within their artist
+type albumKey struct {
+	artist string
+	album  string
+}
+
+func songAlbum(song *Song) albumKey {
+	return albumKey{song.Metadata.ArtistName, song.Metadata.AlbumName}
+}
+
+func (library *Library) findAlbum(key albumKey) *Album {
+	artist, ok := library.Artists[key.artist]
+	if !ok {
+		return nil
+	}
+
+	for _, album := range artist.Albums {
+		if album.AlbumName == key.album {
+			return album
+		}
+	}
+
+	return nil
+}
+
+// compareSongOrder sorts by disc then track, songs without numbers fall back to their file name
+func compareSongOrder(a *Song, b *Song) int {
+	if a.Metadata.DiscNumber != b.Metadata.DiscNumber {
+		return cmp.Compare(a.Metadata.DiscNumber, b.Metadata.DiscNumber)
+	}
+
+	if a.Metadata.TrackNumber != b.Metadata.TrackNumber {
+		return cmp.Compare(a.Metadata.TrackNumber, b.Metadata.TrackNumber)
+	}
+
+	return cmp.Compare(a.FileName, b.FileName)
+}
+
+func (library *Library) RemoveSong(filePath string) {
+	song, ok := library.Songs[filePath]
+	if !ok {
+		return
+	}
+
+	delete(library.Songs, filePath)
+
+	artistName := song.Metadata.ArtistName
+	artist, ok := library.Artists[artistName]
+	if !ok {
+		return
+	}
+
+	for i, album := range artist.Albums {
+		if album.AlbumName != song.Metadata.AlbumName {
+			continue
+		}
+
+		album.Songs = slices.DeleteFunc(album.Songs, func(s *Song) bool { return s == song })
+
+		if len(album.Songs) == 0 {
+			artist.Albums = slices.Delete(artist.Albums, i, i+1)
+		}
+
+		break
+	}
+
+	if len(artist.Albums) == 0 {
+		delete(library.Artists, artistName)
+	}
+}
+
+// ApplyChanges folds a batch of watcher changes into the library
+// a removal that doesn't match a song is treated as a directory and drops everything inside it
+func (library *Library) ApplyChanges(changes []Change, options ScanOptions) ScanProgress {
+	progress := ScanProgress{}
+	touched := map[albumKey]bool{}
+
+	defer library.resolveCovers(touched, options)
+
+	for _, change := range changes {
+		progress.Current++
+
+		if song, ok := library.Songs[change.Path]; ok {
+			touched[songAlbum(song)] = true
+		}
+
+		if change.Song == nil {
+			if _, ok := library.Songs[change.Path]; ok {
+				library.RemoveSong(change.Path)
+				progress.Removed++
+
+				continue
+			}
+
+			prefix := change.Path + string(filepath.Separator)
+			for path, song := range library.Songs {
+				if strings.HasPrefix(path, prefix) {
+					touched[songAlbum(song)] = true
+					library.RemoveSong(path)
+					progress.Removed++
+				}
+			}
+
+			continue
+		}
+
+		if _, ok := library.Songs[change.Path]; ok {
+			library.RemoveSong(change.Path)
+			progress.Updated++
+		} else {
+			progress.Added++
+		}
+
+		library.AddSong(change.Path, change.Song)
+		touched[songAlbum(change.Song)] = true
+	}
+
+	return progress
 }
 
 func LoadLibrary() *Library {
@@ -137,10 +304,18 @@ func CountFiles(ctx context.Context, libraryPath string) (int, error) {
 }
 
 // Scan goes through every file in the path, returning every song file scanned
+// files that kept the same modification time and size as in previous are reused as-is
+// and only new or changed files get their tags read again, previous may be nil
 // there's a minor case where CountFiles and Scan values differ if a file is modified
 // in the middle of the scan... I don't think I care about this since it won't matter much
-func Scan(ctx context.Context, libraryPath string, channel chan<- int) (*Library, error) {
-	var paths []string
+func Scan(
+	ctx context.Context,
+	libraryPath string,
+	previous *Library,
+	options ScanOptions,
+	channel chan<- ScanProgress,
+) (*Library, ScanProgress, error) {
+	var entries []scanEntry
 
 	// we technically already walk the files with CountFiles
 	// but honestly this doesn't matter that much, this is very fast
@@ -167,42 +342,104 @@ func Scan(ctx context.Context, libraryPath string, channel chan<- int) (*Library
 		default:
 		}
 
-		paths = append(paths, path)
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+
+		entries = append(entries, scanEntry{path: path, modTime: info.ModTime(), size: info.Size()})
 
 		return nil
 	})
 	if err != nil {
-		return nil, err
+		return nil, ScanProgress{}, err
 	}
 
-	workersTotal := min(runtime.NumCPU()*4, len(paths))
+	library := New()
+	progress := ScanProgress{}
+
+	var changed []scanEntry
+	seen := make(map[string]bool, len(entries))
+	// albums that gained, lost or changed songs, only their covers can be any different
+	touched := map[albumKey]bool{}
+
+	for _, entry := range entries {
+		seen[entry.path] = true
+
+		if previous != nil {
+			song, ok := previous.Songs[entry.path]
+			if ok && song.ModTime.Equal(entry.modTime) && song.Size == entry.size {
+				// copied so the previous library stays untouched while it's still being displayed
+				unchanged := *song
+				library.AddSong(entry.path, &unchanged)
+				progress.Current++
+
+				continue
+			}
+		}
+
+		if previous != nil {
+			if song, ok := previous.Songs[entry.path]; ok {
+				touched[songAlbum(song)] = true
+			}
+		}
+
+		changed = append(changed, entry)
+	}
+
+	if previous != nil {
+		for path, song := range previous.Songs {
+			if !seen[path] {
+				progress.Removed++
+				touched[songAlbum(song)] = true
+			}
+		}
+	}
+
+	select {
+	case channel <- progress:
+	default:
+	}
+
+	workersTotal := min(runtime.NumCPU()*4, len(changed))
 	if workersTotal == 0 {
-		return New(), nil
+		library.carryCovers(previous, touched, options)
+		channel <- progress
+
+		return library, progress, nil
 	}
 
-	pathsChannel := make(chan string, workersTotal)
+	entriesChannel := make(chan scanEntry, workersTotal)
 	resultsChannel := make(chan songScanResult, workersTotal)
 
 	var waitGroup sync.WaitGroup
 
 	for range workersTotal {
 		waitGroup.Go(func() {
-			for path := range pathsChannel {
+			for entry := range entriesChannel {
 				select {
 				case <-ctx.Done():
 					return
 				default:
 				}
 
-				metadata := readMetadata(path)
+				metadata := readMetadata(entry.path, options)
+
+				updated := false
+				if previous != nil {
+					_, updated = previous.Songs[entry.path]
+				}
 
 				select {
 				case resultsChannel <- songScanResult{
-					path: path,
+					path: entry.path,
 					song: &Song{
-						FileName: filepath.Base(path),
+						FileName: filepath.Base(entry.path),
+						ModTime:  entry.modTime,
+						Size:     entry.size,
 						Metadata: metadata,
 					},
+					updated: updated,
 				}:
 				case <-ctx.Done():
 					return
@@ -212,13 +449,13 @@ func Scan(ctx context.Context, libraryPath string, channel chan<- int) (*Library
 	}
 
 	go func() {
-		defer close(pathsChannel)
+		defer close(entriesChannel)
 
-		for _, p := range paths {
+		for _, entry := range changed {
 			select {
 			case <-ctx.Done():
 				return
-			case pathsChannel <- p:
+			case entriesChannel <- entry:
 			}
 		}
 	}()
@@ -228,35 +465,40 @@ func Scan(ctx context.Context, libraryPath string, channel chan<- int) (*Library
 		close(resultsChannel)
 	}()
 
-	library := New()
-	count := 0
-
 	for result := range resultsChannel {
 		select {
 		case <-ctx.Done():
-			return library, ctx.Err()
+			return library, progress, ctx.Err()
 		default:
 		}
 
 		library.AddSong(result.path, result.song)
+		touched[songAlbum(result.song)] = true
 
-		count++
+		progress.Current++
+		if result.updated {
+			progress.Updated++
+		} else {
+			progress.Added++
+		}
 
-		if count == len(paths) {
-			channel <- count
+		if progress.Current == len(entries) {
+			channel <- progress
 		} else {
 			select {
-			case channel <- count:
+			case channel <- progress:
 			default:
 			}
 		}
 	}
 
 	if ctx.Err() != nil {
-		return library, ctx.Err()
+		return library, progress, ctx.Err()
 	}
 
-	return library, nil
+	library.carryCovers(previous, touched, options)
+
+	return library, progress, nil
 }
 
 func isAudioFile(path string) bool {
@@ -264,7 +506,7 @@ func isAudioFile(path string) bool {
 	return audioExtensions[extension]
 }
 
-func readMetadata(path string) SongMetadata {
+func readMetadata(path string, options ScanOptions) SongMetadata {
 	resultMetadata := SongMetadata{}
 
 	// TODO: do we want to save/show/do anything to errors?
@@ -273,18 +515,34 @@ func readMetadata(path string) SongMetadata {
 	if err == nil {
 		defer f.Close()
 
-		// TODO: tag doesn't support .wav
-		// not sure how to proceed - maybe implementing the reader myself?
 		// tag itself is quite old and might not be the ideal solution here
-		metadata, err := tag.ReadFrom(f)
+		// it doesn't support .wav at all, so RIFF files go through our own reader
+		var metadata tag.Metadata
+		if strings.ToLower(filepath.Ext(path)) == ".wav" {
+			metadata, err = readRIFFTags(f)
+		} else {
+			metadata, err = tag.ReadFrom(f)
+		}
 
 		if err == nil {
 			resultMetadata.SongName = metadata.Title()
 			resultMetadata.ArtistName = metadata.Artist()
 			resultMetadata.AlbumName = metadata.Album()
+			resultMetadata.AlbumArtistName = metadata.AlbumArtist()
+			resultMetadata.ComposerName = metadata.Composer()
+			resultMetadata.Genre = metadata.Genre()
+			resultMetadata.Year = metadata.Year()
+			resultMetadata.TrackNumber, resultMetadata.TrackTotal = metadata.Track()
+			resultMetadata.DiscNumber, resultMetadata.DiscTotal = metadata.Disc()
+			resultMetadata.EmbeddedCover = extractEmbeddedCover(metadata.Picture(), options.CoverMinBytes)
 		}
 	}
 
+	// tags can't be trusted with the length, so it comes from the stream itself
+	if duration, err := playback.Probe(path); err == nil {
+		resultMetadata.Duration = duration
+	}
+
 	if resultMetadata.SongName == "" {
 		name := filepath.Base(path)
 		resultMetadata.SongName = strings.TrimSuffix(name, filepath.Ext(name))
@@ -0,0 +1,266 @@
+package library
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dhowden/tag"
+)
+
+// metadata chunks are tiny unless they carry artwork, this only guards against corrupted sizes
+const maxMetadataChunkSize = 64 << 20
+
+var errInvalidRIFF = errors.New("invalid riff file")
+
+// riffMetadata exposes the LIST/INFO chunk and the embedded id3 chunk of a RIFF/WAVE file
+// through tag.Metadata, id3 values take precedence since they're usually more complete
+type riffMetadata struct {
+	info map[string]string // INFO sub-chunk id -> value, e.g. INAM -> title
+	id3  tag.Metadata
+}
+
+// readRIFFTags is the .wav counterpart of tag.ReadFrom, which only supports other formats
+func readRIFFTags(r io.ReadSeeker) (tag.Metadata, error) {
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return nil, errInvalidRIFF
+	}
+
+	metadata := &riffMetadata{info: map[string]string{}}
+	chunkHeader := make([]byte, 8)
+
+	for {
+		if _, err := io.ReadFull(r, chunkHeader); err != nil {
+			break
+		}
+
+		id := string(chunkHeader[0:4])
+		size := int64(binary.LittleEndian.Uint32(chunkHeader[4:8]))
+		padded := size + size%2
+
+		switch id {
+		case "LIST":
+			if size > maxMetadataChunkSize {
+				return metadata.orError()
+			}
+
+			data := make([]byte, padded)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return metadata.orError()
+			}
+
+			if size >= 4 && string(data[0:4]) == "INFO" {
+				metadata.parseInfo(data[4:size])
+			}
+
+		case "id3 ", "ID3 ":
+			if size > maxMetadataChunkSize {
+				return metadata.orError()
+			}
+
+			data := make([]byte, padded)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return metadata.orError()
+			}
+
+			if id3, err := tag.ReadID3v2Tags(bytes.NewReader(data[:size])); err == nil {
+				metadata.id3 = id3
+			}
+
+		default:
+			if _, err := r.Seek(padded, io.SeekCurrent); err != nil {
+				return metadata.orError()
+			}
+		}
+	}
+
+	return metadata.orError()
+}
+
+// orError keeps whatever was parsed before a truncated chunk, failing only when nothing was found
+func (metadata *riffMetadata) orError() (tag.Metadata, error) {
+	if len(metadata.info) == 0 && metadata.id3 == nil {
+		return nil, tag.ErrNoTagsFound
+	}
+
+	return metadata, nil
+}
+
+func (metadata *riffMetadata) parseInfo(data []byte) {
+	for len(data) >= 8 {
+		id := string(data[0:4])
+		size := int(binary.LittleEndian.Uint32(data[4:8]))
+		data = data[8:]
+
+		if size > len(data) {
+			return
+		}
+
+		value := strings.TrimSpace(strings.TrimRight(decodeInfoText(data[:size]), "\x00"))
+		if value != "" {
+			metadata.info[id] = value
+		}
+
+		data = data[min(size+size%2, len(data)):]
+	}
+}
+
+// decodeInfoText handles the spec's latin-1 while still accepting the utf-8 most tools write nowadays
+func decodeInfoText(data []byte) string {
+	if utf8.Valid(data) {
+		return string(data)
+	}
+
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+
+	return string(runes)
+}
+
+func (metadata *riffMetadata) text(id3Value func(tag.Metadata) string, infoIDs ...string) string {
+	if metadata.id3 != nil {
+		if value := id3Value(metadata.id3); value != "" {
+			return value
+		}
+	}
+
+	for _, id := range infoIDs {
+		if value := metadata.info[id]; value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
+
+func (metadata *riffMetadata) Format() tag.Format {
+	if metadata.id3 != nil {
+		return metadata.id3.Format()
+	}
+
+	return tag.UnknownFormat
+}
+
+func (metadata *riffMetadata) FileType() tag.FileType {
+	return tag.UnknownFileType
+}
+
+func (metadata *riffMetadata) Title() string {
+	return metadata.text(tag.Metadata.Title, "INAM")
+}
+
+func (metadata *riffMetadata) Album() string {
+	return metadata.text(tag.Metadata.Album, "IPRD")
+}
+
+func (metadata *riffMetadata) Artist() string {
+	return metadata.text(tag.Metadata.Artist, "IART")
+}
+
+func (metadata *riffMetadata) AlbumArtist() string {
+	return metadata.text(tag.Metadata.AlbumArtist)
+}
+
+func (metadata *riffMetadata) Composer() string {
+	return metadata.text(tag.Metadata.Composer, "IMUS", "IWRI")
+}
+
+func (metadata *riffMetadata) Year() int {
+	if metadata.id3 != nil && metadata.id3.Year() > 0 {
+		return metadata.id3.Year()
+	}
+
+	// ICRD is usually YYYY-MM-DD but plain years are common too
+	date := metadata.info["ICRD"]
+	if len(date) < 4 {
+		return 0
+	}
+
+	year, err := strconv.Atoi(date[:4])
+	if err != nil {
+		return 0
+	}
+
+	return year
+}
+
+func (metadata *riffMetadata) Genre() string {
+	return metadata.text(tag.Metadata.Genre, "IGNR")
+}
+
+func (metadata *riffMetadata) Track() (int, int) {
+	if metadata.id3 != nil {
+		if track, total := metadata.id3.Track(); track > 0 {
+			return track, total
+		}
+	}
+
+	for _, id := range []string{"ITRK", "IPRT"} {
+		if value := metadata.info[id]; value != "" {
+			return parseNumberPair(value)
+		}
+	}
+
+	return 0, 0
+}
+
+func (metadata *riffMetadata) Disc() (int, int) {
+	if metadata.id3 != nil {
+		return metadata.id3.Disc()
+	}
+
+	return 0, 0
+}
+
+func (metadata *riffMetadata) Picture() *tag.Picture {
+	if metadata.id3 != nil {
+		return metadata.id3.Picture()
+	}
+
+	return nil
+}
+
+func (metadata *riffMetadata) Lyrics() string {
+	return metadata.text(tag.Metadata.Lyrics)
+}
+
+func (metadata *riffMetadata) Comment() string {
+	return metadata.text(tag.Metadata.Comment, "ICMT")
+}
+
+func (metadata *riffMetadata) Raw() map[string]interface{} {
+	raw := map[string]interface{}{}
+
+	if metadata.id3 != nil {
+		for key, value := range metadata.id3.Raw() {
+			raw[key] = value
+		}
+	}
+
+	for key, value := range metadata.info {
+		raw[key] = value
+	}
+
+	return raw
+}
+
+// parseNumberPair reads values such as "3" or "3/12"
+func parseNumberPair(value string) (int, int) {
+	first, second, _ := strings.Cut(value, "/")
+
+	number, _ := strconv.Atoi(strings.TrimSpace(first))
+	total, _ := strconv.Atoi(strings.TrimSpace(second))
+
+	return number, total
+}
@@ -0,0 +1,164 @@
+package library
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/dhowden/tag"
+)
+
+// chunk is a RIFF chunk with its pad byte, size is what it claims to hold
+func chunk(id string, size int, data []byte) []byte {
+	header := append([]byte(id), binary.LittleEndian.AppendUint32(nil, uint32(size))...)
+	data = append(header, data...)
+
+	if len(data)%2 == 1 {
+		data = append(data, 0)
+	}
+
+	return data
+}
+
+// info is a LIST/INFO chunk of id, value pairs, the values are written as is
+func info(pairs ...string) []byte {
+	data := []byte("INFO")
+	for i := 0; i < len(pairs); i += 2 {
+		data = append(data, chunk(pairs[i], len(pairs[i+1]), []byte(pairs[i+1]))...)
+	}
+
+	return chunk("LIST", len(data), data)
+}
+
+// id3 is an id3v2.3 tag with a single text frame
+func id3(frame string, value string) []byte {
+	text := append([]byte{0}, value...)
+	frames := append([]byte(frame), binary.BigEndian.AppendUint32(nil, uint32(len(text)))...)
+	frames = append(append(frames, 0, 0), text...)
+
+	// the tag's size is syncsafe, 7 bits a byte
+	size := len(frames)
+	header := []byte{'I', 'D', '3', 3, 0, 0, byte(size >> 21 & 0x7f), byte(size >> 14 & 0x7f), byte(size >> 7 & 0x7f), byte(size & 0x7f)}
+
+	return chunk("id3 ", size+len(header), append(header, frames...))
+}
+
+func wav(chunks ...[]byte) []byte {
+	data := []byte("WAVE")
+	data = append(data, chunk("fmt ", 16, make([]byte, 16))...)
+
+	for _, c := range chunks {
+		data = append(data, c...)
+	}
+
+	return append(append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(data)))...), data...)
+}
+
+func TestReadRIFFTags(t *testing.T) {
+	tests := []struct {
+		name   string
+		file   []byte
+		err    error
+		title  string
+		artist string
+		year   int
+		track  int
+		total  int
+	}{
+		{
+			name:   "info",
+			file:   wav(info("INAM", "Title", "IART", "Artist", "ICRD", "2003-05-01", "ITRK", "3/12"), chunk("data", 4, make([]byte, 4))),
+			title:  "Title",
+			artist: "Artist",
+			year:   2003,
+			track:  3,
+			total:  12,
+		},
+		{
+			// null terminated and padded to an even size, as most tools write them
+			name:  "terminated",
+			file:  wav(info("INAM", "Odd\x00", "ICRD", "1999", "IPRT", "7")),
+			title: "Odd",
+			year:  1999,
+			track: 7,
+		},
+		{
+			name:  "not a date",
+			file:  wav(info("INAM", "Title", "ICRD", "May")),
+			title: "Title",
+		},
+		{
+			name:   "latin-1",
+			file:   wav(info("INAM", "Caf\xe9", "IART", "Café")),
+			title:  "Café",
+			artist: "Café",
+		},
+		{
+			name:   "id3 first",
+			file:   wav(info("INAM", "Info", "IART", "Artist"), id3("TIT2", "Id3")),
+			title:  "Id3",
+			artist: "Artist",
+		},
+		{
+			// the data chunk claims more than there is, what came before it is kept
+			name:  "truncated data",
+			file:  wav(info("INAM", "Title"), chunk("data", 1000, make([]byte, 10))),
+			title: "Title",
+		},
+		{
+			name:  "truncated id3",
+			file:  wav(info("INAM", "Title"), id3("TIT2", "Id3")[:20]),
+			title: "Title",
+		},
+		{
+			name: "truncated info",
+			file: wav(info("INAM", "Title")[:16]),
+			err:  tag.ErrNoTagsFound,
+		},
+		{
+			name: "too big",
+			file: wav(chunk("LIST", maxMetadataChunkSize+1, nil)),
+			err:  tag.ErrNoTagsFound,
+		},
+		{
+			name: "no tags",
+			file: wav(chunk("data", 4, make([]byte, 4))),
+			err:  tag.ErrNoTagsFound,
+		},
+		{
+			name: "not wave",
+			file: append([]byte("RIFF\x04\x00\x00\x00AVI "), make([]byte, 8)...),
+			err:  errInvalidRIFF,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			metadata, err := readRIFFTags(bytes.NewReader(test.file))
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Fatalf("got %v, want %v", err, test.err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if metadata.Title() != test.title || metadata.Artist() != test.artist {
+				t.Fatalf("title is %q and artist %q, want %q and %q", metadata.Title(), metadata.Artist(), test.title, test.artist)
+			}
+
+			if metadata.Year() != test.year {
+				t.Fatalf("year is %d, want %d", metadata.Year(), test.year)
+			}
+
+			if track, total := metadata.Track(); track != test.track || total != test.total {
+				t.Fatalf("track is %d/%d, want %d/%d", track, total, test.track, test.total)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package library
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// how much a match counts depending on where it was found
+const (
+	titleWeight  = 3
+	artistWeight = 2
+	albumWeight  = 1
+)
+
+// letters that don't decompose into a base letter and an accent
+var foldedLetters = map[rune]string{
+	'ß': "ss",
+	'æ': "ae",
+	'œ': "oe",
+	'ø': "o",
+	'ł': "l",
+	'đ': "d",
+	'ð': "d",
+	'þ': "th",
+	'ı': "i",
+}
+
+type SearchResult struct {
+	Song  *Song
+	Score int
+}
+
+type searchField struct {
+	tokens []string
+	weight int
+}
+
+type searchEntry struct {
+	song   *Song
+	fields []searchField
+}
+
+// SearchIndex is a snapshot of the library's songs, tokenized once so every keystroke stays cheap
+type SearchIndex struct {
+	entries []searchEntry
+}
+
+func NewSearchIndex(lib *Library) *SearchIndex {
+	index := &SearchIndex{}
+	if lib == nil {
+		return index
+	}
+
+	index.entries = make([]searchEntry, 0, len(lib.Songs))
+
+	for _, song := range lib.Songs {
+		title := song.Metadata.SongName
+		if title == "" {
+			title = song.FileName
+		}
+
+		artist := song.Metadata.ArtistName
+		if song.Metadata.AlbumArtistName != "" && song.Metadata.AlbumArtistName != artist {
+			artist += " " + song.Metadata.AlbumArtistName
+		}
+
+		index.entries = append(index.entries, searchEntry{
+			song: song,
+			fields: []searchField{
+				{tokens: tokenize(title), weight: titleWeight},
+				{tokens: tokenize(artist), weight: artistWeight},
+				{tokens: tokenize(song.Metadata.AlbumName), weight: albumWeight},
+			},
+		})
+	}
+
+	// ties keep the order the browser shows songs in
+	slices.SortFunc(index.entries, func(a searchEntry, b searchEntry) int {
+		return cmp.Or(
+			strings.Compare(strings.ToLower(a.song.Metadata.ArtistName), strings.ToLower(b.song.Metadata.ArtistName)),
+			strings.Compare(strings.ToLower(a.song.Metadata.AlbumName), strings.ToLower(b.song.Metadata.AlbumName)),
+			compareSongOrder(a.song, b.song),
+		)
+	})
+
+	return index
+}
+
+// Search ranks the songs matching every word of the query, best first and at most limit of them
+func (index *SearchIndex) Search(query string, limit int) []SearchResult {
+	words := tokenize(query)
+	if len(words) == 0 {
+		return nil
+	}
+
+	var results []SearchResult
+
+	for _, entry := range index.entries {
+		if score := entry.score(words); score > 0 {
+			results = append(results, SearchResult{Song: entry.song, Score: score})
+		}
+	}
+
+	slices.SortStableFunc(results, func(a SearchResult, b SearchResult) int {
+		return cmp.Compare(b.Score, a.Score)
+	})
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results
+}
+
+// score adds up the best match of each word, a word that matches nothing rules the song out
+func (entry searchEntry) score(words []string) int {
+	total := 0
+
+	for _, word := range words {
+		best := 0
+
+		for _, field := range entry.fields {
+			for _, token := range field.tokens {
+				best = max(best, matchToken(word, token)*field.weight)
+			}
+		}
+
+		if best == 0 {
+			return 0
+		}
+
+		total += best
+	}
+
+	return total
+}
+
+// matchToken prefers whole words, then prefixes, then substrings, and last the letters of the word
+// showing up in order, which is what catches typing "tkyo" for "tokyo"
+func matchToken(word string, token string) int {
+	switch {
+	case word == token:
+		return 100
+	case strings.HasPrefix(token, word):
+		return 80 - min(len(token)-len(word), 20)
+	case strings.Contains(token, word):
+		return 50
+	}
+
+	// a letter or two in order would match nearly everything
+	if len(word) < 3 {
+		return 0
+	}
+
+	gaps, ok := subsequence(word, token)
+	if !ok {
+		return 0
+	}
+
+	return max(30-gaps*5, 1)
+}
+
+// subsequence tells whether the bytes of word appear in token in order, and how many were skipped
+// between the first and the last of them
+func subsequence(word string, token string) (int, bool) {
+	position, start := 0, -1
+
+	for i := 0; i < len(token) && position < len(word); i++ {
+		if token[i] != word[position] {
+			continue
+		}
+
+		if start < 0 {
+			start = i
+		}
+
+		position++
+
+		if position == len(word) {
+			return i - start + 1 - len(word), true
+		}
+	}
+
+	return 0, false
+}
+
+// tokenize splits text into lowercase words with accents dropped, so "Björk" is found typing "bjork"
+func tokenize(text string) []string {
+	var folded strings.Builder
+
+	for _, char := range norm.NFD.String(text) {
+		if unicode.Is(unicode.Mn, char) {
+			continue
+		}
+
+		char = unicode.ToLower(char)
+
+		if replacement, ok := foldedLetters[char]; ok {
+			folded.WriteString(replacement)
+		} else if unicode.IsLetter(char) || unicode.IsNumber(char) {
+			folded.WriteRune(char)
+		} else if char != '\'' {
+			// apostrophes stay out so "don't" matches "dont"
+			folded.WriteByte(' ')
+		}
+	}
+
+	return strings.Fields(folded.String())
+}
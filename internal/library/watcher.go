@@ -0,0 +1,271 @@
+package library
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	// a batch is flushed once nothing happened for watchQuietPeriod
+	// or when events kept coming for watchMaxDelay, e.g. copying a huge discography.
+	// files still being copied at that point wait for the next flush
+	watchQuietPeriod = time.Second
+	watchMaxDelay    = 10 * time.Second
+)
+
+// Change is a single file that was created, modified or removed, Song is nil for removals
+type Change struct {
+	Path string
+	Song *Song
+}
+
+type WatchEvent struct {
+	Changes []Change
+	Error   error
+}
+
+// Watcher follows the library directory recursively and batches filesystem events into changes
+type Watcher struct {
+	notify    *fsnotify.Watcher
+	events    chan WatchEvent
+	done      chan struct{}
+	pending   map[string]int64 // the size a file had at its last event, -1 when unknown
+	watchDirs map[string]bool
+	options   ScanOptions
+}
+
+func Watch(libraryPath string, options ScanOptions) (*Watcher, error) {
+	notify, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	watcher := &Watcher{
+		notify:    notify,
+		events:    make(chan WatchEvent),
+		done:      make(chan struct{}),
+		pending:   map[string]int64{},
+		watchDirs: map[string]bool{},
+		options:   options,
+	}
+
+	// inotify isn't recursive, so every directory needs its own watch
+	if err := watcher.addRecursive(libraryPath, false); err != nil {
+		notify.Close()
+		return nil, err
+	}
+
+	go watcher.run()
+
+	return watcher, nil
+}
+
+func (watcher *Watcher) Events() <-chan WatchEvent {
+	return watcher.events
+}
+
+func (watcher *Watcher) Close() error {
+	select {
+	case <-watcher.done:
+		return nil
+	default:
+		close(watcher.done)
+	}
+
+	return watcher.notify.Close()
+}
+
+// addRecursive watches dir and its subdirectories, queueing the audio files inside when
+// queueFiles is set since they might have been created before the watch was in place
+func (watcher *Watcher) addRecursive(dir string, queueFiles bool) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+
+			return nil
+		}
+
+		if d.IsDir() {
+			if err := watcher.notify.Add(path); err != nil {
+				return err
+			}
+
+			watcher.watchDirs[path] = true
+
+			return nil
+		}
+
+		if queueFiles && isAudioFile(path) {
+			watcher.pending[path] = -1
+			if info, err := d.Info(); err == nil {
+				watcher.pending[path] = info.Size()
+			}
+		}
+
+		return nil
+	})
+}
+
+func (watcher *Watcher) run() {
+	var (
+		quiet    <-chan time.Time
+		deadline <-chan time.Time
+	)
+
+	for {
+		select {
+		case <-watcher.done:
+			return
+
+		case event, ok := <-watcher.notify.Events:
+			if !ok {
+				return
+			}
+
+			if !watcher.handle(event) {
+				continue
+			}
+
+			quiet = time.After(watchQuietPeriod)
+			if deadline == nil {
+				deadline = time.After(watchMaxDelay)
+			}
+
+		case err, ok := <-watcher.notify.Errors:
+			if !ok {
+				return
+			}
+
+			if !watcher.send(WatchEvent{Error: err}) {
+				return
+			}
+
+		case <-quiet:
+			quiet, deadline = nil, nil
+
+			if !watcher.flush(false) {
+				return
+			}
+
+		case <-deadline:
+			// quiet keeps running for whatever is held back
+			deadline = nil
+
+			if !watcher.flush(true) {
+				return
+			}
+		}
+	}
+}
+
+// handle records the event, returning whether it is relevant to the library
+func (watcher *Watcher) handle(event fsnotify.Event) bool {
+	path := event.Name
+
+	if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+		if watcher.watchDirs[path] {
+			watcher.forgetDir(path)
+			watcher.pending[path] = -1
+
+			return true
+		}
+
+		if isAudioFile(path) {
+			watcher.pending[path] = -1
+			return true
+		}
+
+		return false
+	}
+
+	if event.Has(fsnotify.Create) {
+		info, err := os.Stat(path)
+		if err == nil && info.IsDir() {
+			// errors here surface through the next flush as missing files, nothing to do now
+			_ = watcher.addRecursive(path, true)
+			return true
+		}
+	}
+
+	if (event.Has(fsnotify.Create) || event.Has(fsnotify.Write)) && isAudioFile(path) {
+		watcher.pending[path] = -1
+		if info, err := os.Stat(path); err == nil {
+			watcher.pending[path] = info.Size()
+		}
+
+		return true
+	}
+
+	return false
+}
+
+func (watcher *Watcher) forgetDir(dir string) {
+	prefix := dir + string(filepath.Separator)
+
+	for path := range watcher.watchDirs {
+		if path == dir || strings.HasPrefix(path, prefix) {
+			delete(watcher.watchDirs, path)
+			// inotify drops the watch of a deleted directory by itself, a renamed one keeps it
+			_ = watcher.notify.Remove(path)
+		}
+	}
+}
+
+// flush sends the pending changes, with hold set files that grew since their last event
+// are kept back since reading tags off a half copied file gets them wrong
+func (watcher *Watcher) flush(hold bool) bool {
+	if len(watcher.pending) == 0 {
+		return true
+	}
+
+	changes := make([]Change, 0, len(watcher.pending))
+	held := map[string]int64{}
+
+	for path, size := range watcher.pending {
+		info, err := os.Stat(path)
+
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			changes = append(changes, Change{Path: path})
+		case err != nil || info.IsDir():
+			continue
+		case hold && info.Size() != size:
+			held[path] = info.Size()
+		default:
+			changes = append(changes, Change{
+				Path: path,
+				Song: &Song{
+					FileName: filepath.Base(path),
+					ModTime:  info.ModTime(),
+					Size:     info.Size(),
+					Metadata: readMetadata(path, watcher.options),
+				},
+			})
+		}
+	}
+
+	watcher.pending = held
+
+	if len(changes) == 0 {
+		return true
+	}
+
+	return watcher.send(WatchEvent{Changes: changes})
+}
+
+func (watcher *Watcher) send(event WatchEvent) bool {
+	select {
+	case watcher.events <- event:
+		return true
+	case <-watcher.done:
+		return false
+	}
+}
@@ -0,0 +1,760 @@
+package mpd
+
+import (
+	"cmp"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	engine "wired/internal/engine"
+	playback "wired/internal/playback"
+)
+
+type command func(server *Server, args []string, out *response) error
+
+// queue entries have no ids of their own, so a song's id is its position in the queue
+var commands = map[string]command{
+	"ping":               func(*Server, []string, *response) error { return nil },
+	"binarylimit":        func(*Server, []string, *response) error { return nil },
+	"status":             (*Server).status,
+	"currentsong":        (*Server).currentSong,
+	"stats":              (*Server).stats,
+	"play":               (*Server).play,
+	"playid":             (*Server).play,
+	"pause":              (*Server).pause,
+	"stop":               (*Server).stopPlayback,
+	"next":               (*Server).next,
+	"previous":           (*Server).previous,
+	"seek":               (*Server).seek,
+	"seekid":             (*Server).seek,
+	"seekcur":            (*Server).seekCurrent,
+	"setvol":             (*Server).setVolume,
+	"volume":             (*Server).changeVolume,
+	"getvol":             (*Server).getVolume,
+	"repeat":             unsupportedOption,
+	"random":             unsupportedOption,
+	"single":             unsupportedOption,
+	"consume":            unsupportedOption,
+	"playlistinfo":       (*Server).playlistInfo,
+	"playlistid":         (*Server).playlistInfo,
+	"plchanges":          (*Server).playlistChanges,
+	"plchangesposid":     (*Server).playlistChangesPositions,
+	"add":                (*Server).add,
+	"addid":              (*Server).addID,
+	"clear":              (*Server).clear,
+	"delete":             (*Server).delete,
+	"deleteid":           (*Server).delete,
+	"list":               (*Server).list,
+	"find":               (*Server).find,
+	"search":             (*Server).searchSongs,
+	"findadd":            (*Server).findAdd,
+	"searchadd":          (*Server).searchAdd,
+	"lsinfo":             (*Server).listInfo,
+	"tagtypes":           tagTypes,
+	"outputs":            outputs,
+	"urlhandlers":        urlHandlers,
+	"decoders":           func(*Server, []string, *response) error { return nil },
+	"notcommands":        func(*Server, []string, *response) error { return nil },
+	"replay_gain_status": replayGainStatus,
+}
+
+func (server *Server) run(name string, args []string, out *response) error {
+	// commands can't be in its own table without an initialization loop
+	if name == "commands" {
+		names := []string{"close", "commands", "command_list_begin", "command_list_ok_begin", "command_list_end", "idle", "noidle"}
+		for name := range commands {
+			names = append(names, name)
+		}
+
+		slices.Sort(names)
+
+		for _, name := range names {
+			out.field("command", name)
+		}
+
+		return nil
+	}
+
+	command, ok := commands[name]
+	if !ok {
+		return &ackError{code: ackUnknown, message: "unknown command \"" + name + "\""}
+	}
+
+	return command(server, args, out)
+}
+
+func (server *Server) snapshot() (*database, int) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	return server.database, server.version
+}
+
+func (server *Server) status(args []string, out *response) error {
+	status := server.engine.Status()
+	queue, current := server.engine.Queue()
+	_, version := server.snapshot()
+
+	out.field("volume", status.Volume)
+	out.field("repeat", 0)
+	out.field("random", 0)
+	out.field("single", 0)
+	out.field("consume", 0)
+	out.field("playlist", version)
+	out.field("playlistlength", len(queue))
+
+	switch status.State {
+	case playback.Playing:
+		out.field("state", "play")
+	case playback.Paused:
+		out.field("state", "pause")
+	default:
+		out.field("state", "stop")
+	}
+
+	if current >= 0 && current < len(queue) {
+		out.field("song", current)
+		out.field("songid", current)
+	}
+
+	if current+1 < len(queue) {
+		out.field("nextsong", current+1)
+		out.field("nextsongid", current+1)
+	}
+
+	if status.State != playback.Stopped {
+		elapsed := int(status.Position.Seconds())
+		total := int(status.Duration.Round(time.Second).Seconds())
+
+		out.field("time", strconv.Itoa(elapsed)+":"+strconv.Itoa(total))
+		out.field("elapsed", formatSeconds(status.Position))
+
+		if status.Duration > 0 {
+			out.field("duration", formatSeconds(status.Duration))
+		}
+	}
+
+	return nil
+}
+
+func (server *Server) currentSong(args []string, out *response) error {
+	status := server.engine.Status()
+	if status.State == playback.Stopped {
+		return nil
+	}
+
+	database, _ := server.snapshot()
+
+	song := database.queued(status.Track)
+	song.write(out)
+
+	// the engine knows the stream's current title better than the queue entry
+	if status.Track.StreamTitle != "" && song.metadata.SongName == "" {
+		out.field("Title", status.Track.StreamTitle)
+	}
+
+	out.field("Pos", status.Index)
+	out.field("Id", status.Index)
+
+	return nil
+}
+
+func (server *Server) stats(args []string, out *response) error {
+	database, _ := server.snapshot()
+
+	artists := map[string]bool{}
+	albums := map[string]bool{}
+
+	var playtime time.Duration
+
+	for _, song := range database.songs {
+		artists[song.metadata.ArtistName] = true
+		albums[song.metadata.ArtistName+"\x00"+song.metadata.AlbumName] = true
+		playtime += song.metadata.Duration
+	}
+
+	out.field("artists", len(artists))
+	out.field("albums", len(albums))
+	out.field("songs", len(database.songs))
+	out.field("db_playtime", int(playtime.Seconds()))
+
+	if !database.updated.IsZero() {
+		out.field("db_update", database.updated.Unix())
+	}
+
+	return nil
+}
+
+func (server *Server) play(args []string, out *response) error {
+	if len(args) == 0 {
+		return server.engine.Play()
+	}
+
+	index, err := parseInt(args[0])
+	if err != nil {
+		return err
+	}
+
+	// -1 is how clients ask to resume
+	if index < 0 {
+		return server.engine.Play()
+	}
+
+	if queue, _ := server.engine.Queue(); index >= len(queue) {
+		return argumentError("Bad song index")
+	}
+
+	return server.engine.PlayIndex(index)
+}
+
+func (server *Server) pause(args []string, out *response) error {
+	if len(args) == 0 {
+		return server.engine.TogglePause()
+	}
+
+	if args[0] == "1" {
+		server.engine.Pause()
+		return nil
+	}
+
+	if server.engine.Status().State == playback.Paused {
+		return server.engine.Play()
+	}
+
+	return nil
+}
+
+func (server *Server) stopPlayback(args []string, out *response) error {
+	server.engine.Stop()
+	return nil
+}
+
+func (server *Server) next(args []string, out *response) error {
+	return server.engine.Next()
+}
+
+func (server *Server) previous(args []string, out *response) error {
+	return server.engine.Previous()
+}
+
+// seek takes a queue position (or id, which is the same) and a time, switching tracks when needed
+func (server *Server) seek(args []string, out *response) error {
+	if len(args) != 2 {
+		return argumentError("wrong number of arguments")
+	}
+
+	index, err := parseInt(args[0])
+	if err != nil {
+		return err
+	}
+
+	position, err := parseSeconds(args[1])
+	if err != nil {
+		return err
+	}
+
+	if status := server.engine.Status(); status.State == playback.Stopped || status.Index != index {
+		if err := server.engine.PlayIndex(index); err != nil {
+			return err
+		}
+	}
+
+	return server.engine.Seek(max(position, 0))
+}
+
+func (server *Server) seekCurrent(args []string, out *response) error {
+	if len(args) != 1 {
+		return argumentError("wrong number of arguments")
+	}
+
+	position, err := parseSeconds(args[0])
+	if err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(args[0], "+") || strings.HasPrefix(args[0], "-") {
+		position += server.engine.Status().Position
+	}
+
+	return server.engine.Seek(max(position, 0))
+}
+
+func (server *Server) setVolume(args []string, out *response) error {
+	if len(args) != 1 {
+		return argumentError("wrong number of arguments")
+	}
+
+	volume, err := parseInt(args[0])
+	if err != nil {
+		return err
+	}
+
+	if volume < 0 || volume > 100 {
+		return argumentError("Invalid volume value")
+	}
+
+	server.engine.SetVolume(volume)
+
+	return nil
+}
+
+func (server *Server) changeVolume(args []string, out *response) error {
+	if len(args) != 1 {
+		return argumentError("wrong number of arguments")
+	}
+
+	change, err := parseInt(args[0])
+	if err != nil {
+		return err
+	}
+
+	server.engine.SetVolume(server.engine.Status().Volume + change)
+
+	return nil
+}
+
+func (server *Server) getVolume(args []string, out *response) error {
+	out.field("volume", server.engine.Status().Volume)
+	return nil
+}
+
+// the engine always plays the queue in order, once
+func unsupportedOption(server *Server, args []string, out *response) error {
+	if len(args) == 1 && args[0] == "0" {
+		return nil
+	}
+
+	return argumentError("not supported by wired")
+}
+
+func (server *Server) writeQueue(out *response, start int, end int) error {
+	queue, _ := server.engine.Queue()
+	database, _ := server.snapshot()
+
+	if start > len(queue) {
+		return argumentError("Bad song index")
+	}
+
+	for index := start; index < min(end, len(queue)); index++ {
+		database.queued(queue[index]).write(out)
+		out.field("Pos", index)
+		out.field("Id", index)
+	}
+
+	return nil
+}
+
+func (server *Server) playlistInfo(args []string, out *response) error {
+	queue, _ := server.engine.Queue()
+
+	if len(args) == 0 {
+		return server.writeQueue(out, 0, len(queue))
+	}
+
+	start, end, err := parseRange(args[0], len(queue))
+	if err != nil {
+		return err
+	}
+
+	if start >= len(queue) {
+		return noExistError("No such song")
+	}
+
+	return server.writeQueue(out, start, end)
+}
+
+// playlistChanges can't tell which entries changed, so every change sends the whole queue
+func (server *Server) playlistChanges(args []string, out *response) error {
+	if len(args) == 0 {
+		return argumentError("wrong number of arguments")
+	}
+
+	since, err := parseInt(args[0])
+	if err != nil {
+		return err
+	}
+
+	if _, version := server.snapshot(); since == version {
+		return nil
+	}
+
+	queue, _ := server.engine.Queue()
+
+	return server.writeQueue(out, 0, len(queue))
+}
+
+func (server *Server) playlistChangesPositions(args []string, out *response) error {
+	if len(args) == 0 {
+		return argumentError("wrong number of arguments")
+	}
+
+	since, err := parseInt(args[0])
+	if err != nil {
+		return err
+	}
+
+	if _, version := server.snapshot(); since == version {
+		return nil
+	}
+
+	queue, _ := server.engine.Queue()
+
+	for index := range queue {
+		out.field("cpos", index)
+		out.field("Id", index)
+	}
+
+	return nil
+}
+
+// resolve finds what a uri stands for: a song or directory of the library, a stream, or a local file
+func (server *Server) resolve(uri string) ([]engine.Track, error) {
+	database, _ := server.snapshot()
+
+	if playback.IsStream(uri) {
+		return []engine.Track{server.engine.Resolve(uri)}, nil
+	}
+
+	if songs := database.under(uri); len(songs) > 0 {
+		return server.tracks(songs), nil
+	}
+
+	path := database.absolute(uri)
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		return []engine.Track{server.engine.Resolve(path)}, nil
+	}
+
+	return nil, noExistError("No such directory")
+}
+
+func (server *Server) tracks(songs []*song) []engine.Track {
+	tracks := make([]engine.Track, len(songs))
+
+	for i, song := range songs {
+		tracks[i] = server.engine.Resolve(song.path)
+	}
+
+	return tracks
+}
+
+func (server *Server) add(args []string, out *response) error {
+	if len(args) != 1 {
+		return argumentError("wired only adds to the end of the queue")
+	}
+
+	tracks, err := server.resolve(args[0])
+	if err != nil {
+		return err
+	}
+
+	return server.engine.Append(tracks...)
+}
+
+func (server *Server) addID(args []string, out *response) error {
+	if len(args) != 1 {
+		return argumentError("wired only adds to the end of the queue")
+	}
+
+	tracks, err := server.resolve(args[0])
+	if err != nil {
+		return err
+	}
+
+	if len(tracks) != 1 {
+		return noExistError("No such song")
+	}
+
+	queue, _ := server.engine.Queue()
+
+	if err := server.engine.Append(tracks...); err != nil {
+		return err
+	}
+
+	out.field("Id", len(queue))
+
+	return nil
+}
+
+func (server *Server) clear(args []string, out *response) error {
+	return server.engine.Clear()
+}
+
+func (server *Server) delete(args []string, out *response) error {
+	if len(args) != 1 {
+		return argumentError("wrong number of arguments")
+	}
+
+	queue, _ := server.engine.Queue()
+
+	start, end, err := parseRange(args[0], len(queue))
+	if err != nil {
+		return err
+	}
+
+	if start >= len(queue) || end > len(queue) {
+		return argumentError("Bad song index")
+	}
+
+	// from the back so the positions that are left stay put
+	for index := end - 1; index >= start; index-- {
+		if err := server.engine.Remove(index); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// list prints every distinct value of a tag, optionally filtered and grouped by other tags
+func (server *Server) list(args []string, out *response) error {
+	if len(args) == 0 {
+		return argumentError("too few arguments for \"list\"")
+	}
+
+	tag := strings.ToLower(args[0])
+	if !knownTag(tag) || tag == "any" {
+		return argumentError("Unknown tag type: %s", args[0])
+	}
+
+	args = args[1:]
+
+	var groups []string
+	for len(args) >= 2 && strings.EqualFold(args[len(args)-2], "group") {
+		groups = append([]string{strings.ToLower(args[len(args)-1])}, groups...)
+		args = args[:len(args)-2]
+	}
+
+	// the old form of "list album ARTIST"
+	if tag == "album" && len(args) == 1 && !strings.HasPrefix(args[0], "(") {
+		args = []string{"artist", args[0]}
+	}
+
+	database, _ := server.snapshot()
+	songs := database.songs
+
+	if len(args) > 0 {
+		filter, err := parseFilter(args, false)
+		if err != nil {
+			return err
+		}
+
+		songs = database.find(filter)
+	}
+
+	keys := append(groups, tag)
+	seen := map[string]bool{}
+
+	var rows [][]string
+
+	for _, song := range songs {
+		row := make([]string, len(keys))
+		for i, key := range keys {
+			row[i] = song.tag(key)
+		}
+
+		id := strings.Join(row, "\x00")
+		if !seen[id] {
+			seen[id] = true
+			rows = append(rows, row)
+		}
+	}
+
+	slices.SortFunc(rows, func(a []string, b []string) int {
+		return slices.Compare(a, b)
+	})
+
+	var previous []string
+
+	for _, row := range rows {
+		for i, value := range row {
+			// groups are only repeated when they change
+			if i < len(row)-1 && previous != nil && slices.Equal(previous[:i+1], row[:i+1]) {
+				continue
+			}
+
+			out.field(tagName(keys[i]), value)
+		}
+
+		previous = row
+	}
+
+	return nil
+}
+
+// search splits off the sort and window arguments that may follow a filter, fold makes it
+// ignore case and match parts of values like mpd's search
+func (server *Server) search(args []string, fold bool) ([]*song, error) {
+	window := ""
+	sortBy := ""
+
+	for len(args) >= 2 {
+		option := strings.ToLower(args[len(args)-2])
+		if option != "window" && option != "sort" {
+			break
+		}
+
+		if option == "window" {
+			window = args[len(args)-1]
+		} else {
+			sortBy = strings.ToLower(strings.TrimPrefix(args[len(args)-1], "-"))
+		}
+
+		args = args[:len(args)-2]
+	}
+
+	filter, err := parseFilter(args, fold)
+	if err != nil {
+		return nil, err
+	}
+
+	database, _ := server.snapshot()
+	songs := database.find(filter)
+
+	if sortBy != "" {
+		slices.SortStableFunc(songs, func(a *song, b *song) int {
+			return cmp.Compare(a.tag(sortBy), b.tag(sortBy))
+		})
+	}
+
+	if window != "" {
+		start, end, err := parseRange(window, len(songs))
+		if err != nil {
+			return nil, err
+		}
+
+		songs = songs[min(start, len(songs)):min(end, len(songs))]
+	}
+
+	return songs, nil
+}
+
+func (server *Server) writeSongs(args []string, out *response, fold bool) error {
+	songs, err := server.search(args, fold)
+	if err != nil {
+		return err
+	}
+
+	for _, song := range songs {
+		song.write(out)
+	}
+
+	return nil
+}
+
+func (server *Server) find(args []string, out *response) error {
+	return server.writeSongs(args, out, false)
+}
+
+func (server *Server) searchSongs(args []string, out *response) error {
+	return server.writeSongs(args, out, true)
+}
+
+func (server *Server) addSongs(args []string, fold bool) error {
+	songs, err := server.search(args, fold)
+	if err != nil {
+		return err
+	}
+
+	if len(songs) == 0 {
+		return nil
+	}
+
+	return server.engine.Append(server.tracks(songs)...)
+}
+
+func (server *Server) findAdd(args []string, out *response) error {
+	return server.addSongs(args, false)
+}
+
+func (server *Server) searchAdd(args []string, out *response) error {
+	return server.addSongs(args, true)
+}
+
+// listInfo shows one level of the library's directories
+func (server *Server) listInfo(args []string, out *response) error {
+	database, _ := server.snapshot()
+
+	uri := ""
+	if len(args) > 0 {
+		uri = strings.Trim(args[0], "/")
+	}
+
+	songs := database.under(uri)
+	if len(songs) == 0 {
+		if uri == "" {
+			return nil
+		}
+
+		return noExistError("No such directory")
+	}
+
+	if len(songs) == 1 && songs[0].file == uri {
+		songs[0].write(out)
+		return nil
+	}
+
+	prefix := ""
+	if uri != "" {
+		prefix = uri + "/"
+	}
+
+	var files []*song
+	listed := map[string]bool{}
+
+	for _, song := range songs {
+		rest := strings.TrimPrefix(song.file, prefix)
+
+		if directory, _, nested := strings.Cut(rest, "/"); nested {
+			if !listed[directory] {
+				listed[directory] = true
+				out.field("directory", prefix+directory)
+			}
+
+			continue
+		}
+
+		files = append(files, song)
+	}
+
+	for _, song := range files {
+		song.write(out)
+	}
+
+	return nil
+}
+
+// tagTypes lists the tags wired knows, the ways of narrowing them down are accepted and ignored
+func tagTypes(server *Server, args []string, out *response) error {
+	if len(args) > 0 {
+		return nil
+	}
+
+	for _, tag := range tagNames {
+		out.field("tagtype", tag.name)
+	}
+
+	return nil
+}
+
+func outputs(server *Server, args []string, out *response) error {
+	out.field("outputid", 0)
+	out.field("outputname", "wired")
+	out.field("plugin", "wired")
+	out.field("outputenabled", 1)
+
+	return nil
+}
+
+func urlHandlers(server *Server, args []string, out *response) error {
+	out.field("handler", "http://")
+	out.field("handler", "https://")
+
+	return nil
+}
+
+func replayGainStatus(server *Server, args []string, out *response) error {
+	out.field("replay_gain_mode", "off")
+	return nil
+}
@@ -0,0 +1,501 @@
+package mpd
+
+import (
+	"cmp"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	engine "wired/internal/engine"
+	library "wired/internal/library"
+	playback "wired/internal/playback"
+)
+
+// song is what clients get to see of a file, in the library or only in the queue
+type song struct {
+	path     string // absolute, how the engine knows it
+	file     string // relative to the music library, how clients know it
+	metadata library.SongMetadata
+	modTime  time.Time
+	// set on streams, which clients show by name
+	name string
+}
+
+// tag returns the value clients filter and list by, names are the lowercase mpd tag names
+func (song *song) tag(name string) string {
+	metadata := song.metadata
+
+	switch name {
+	case "file":
+		return song.file
+	case "artist":
+		return metadata.ArtistName
+	case "albumartist":
+		if metadata.AlbumArtistName == "" {
+			return metadata.ArtistName
+		}
+
+		return metadata.AlbumArtistName
+	case "album":
+		return metadata.AlbumName
+	case "title":
+		return metadata.SongName
+	case "genre":
+		return metadata.Genre
+	case "composer":
+		return metadata.ComposerName
+	case "name":
+		return song.name
+	case "date":
+		if metadata.Year > 0 {
+			return strconv.Itoa(metadata.Year)
+		}
+	case "track":
+		if metadata.TrackNumber > 0 {
+			return strconv.Itoa(metadata.TrackNumber)
+		}
+	case "disc":
+		if metadata.DiscNumber > 0 {
+			return strconv.Itoa(metadata.DiscNumber)
+		}
+	}
+
+	return ""
+}
+
+// tagNames maps the lowercase names to how mpd spells them in responses
+var tagNames = []struct {
+	key  string
+	name string
+}{
+	{"artist", "Artist"},
+	{"albumartist", "AlbumArtist"},
+	{"album", "Album"},
+	{"title", "Title"},
+	{"track", "Track"},
+	{"name", "Name"},
+	{"genre", "Genre"},
+	{"date", "Date"},
+	{"composer", "Composer"},
+	{"disc", "Disc"},
+}
+
+func tagName(key string) string {
+	for _, tag := range tagNames {
+		if tag.key == key {
+			return tag.name
+		}
+	}
+
+	return key
+}
+
+func (song *song) write(out *response) {
+	out.field("file", song.file)
+
+	if !song.modTime.IsZero() {
+		out.field("Last-Modified", song.modTime.UTC().Format(time.RFC3339))
+	}
+
+	for _, tag := range tagNames {
+		// albumartist falls back to the artist for filtering, but shouldn't be made up here
+		if tag.key == "albumartist" && song.metadata.AlbumArtistName == "" {
+			continue
+		}
+
+		if value := song.tag(tag.key); value != "" {
+			out.field(tag.name, value)
+		}
+	}
+
+	if song.metadata.Duration > 0 {
+		out.field("Time", int(song.metadata.Duration.Round(time.Second).Seconds()))
+		out.field("duration", formatSeconds(song.metadata.Duration))
+	}
+}
+
+// database is a copy of the library taken on the ui's side, the connections never touch the library itself
+type database struct {
+	root    string
+	songs   []*song // by file
+	byPath  map[string]*song
+	updated time.Time
+}
+
+func newDatabase(lib *library.Library, root string) *database {
+	database := &database{
+		root:    root,
+		songs:   make([]*song, 0, len(lib.Songs)),
+		byPath:  make(map[string]*song, len(lib.Songs)),
+		updated: time.Now(),
+	}
+
+	for path, entry := range lib.Songs {
+		song := &song{
+			path:     path,
+			file:     database.relative(path),
+			metadata: entry.Metadata,
+			modTime:  entry.ModTime,
+		}
+
+		if song.metadata.SongName == "" {
+			song.metadata.SongName = entry.FileName
+		}
+
+		database.songs = append(database.songs, song)
+		database.byPath[path] = song
+	}
+
+	slices.SortFunc(database.songs, func(a *song, b *song) int {
+		return cmp.Compare(a.file, b.file)
+	})
+
+	return database
+}
+
+// relative turns a path into a uri clients can send back, files outside the library stay absolute
+func (database *database) relative(path string) string {
+	if database.root == "" || playback.IsStream(path) {
+		return path
+	}
+
+	relative, err := filepath.Rel(database.root, path)
+	if err != nil || strings.HasPrefix(relative, "..") {
+		return path
+	}
+
+	return filepath.ToSlash(relative)
+}
+
+func (database *database) absolute(uri string) string {
+	if playback.IsStream(uri) || filepath.IsAbs(uri) {
+		return uri
+	}
+
+	return filepath.Join(database.root, filepath.FromSlash(uri))
+}
+
+// queued describes a queue entry, with everything the library knows about it
+func (database *database) queued(track engine.Track) *song {
+	if song, ok := database.byPath[track.Path]; ok {
+		return song
+	}
+
+	song := &song{
+		path: track.Path,
+		file: database.relative(track.Path),
+		metadata: library.SongMetadata{
+			SongName:        track.Title,
+			ArtistName:      track.Artist,
+			AlbumName:       track.Album,
+			AlbumArtistName: track.AlbumArtist,
+			TrackNumber:     track.TrackNumber,
+			Duration:        track.Duration,
+		},
+	}
+
+	if playback.IsStream(track.Path) {
+		song.name = track.Title
+		song.metadata.SongName = track.StreamTitle
+	}
+
+	return song
+}
+
+// under lists the songs of a directory and everything below it, an empty uri is the whole library
+func (database *database) under(uri string) []*song {
+	uri = strings.Trim(uri, "/")
+	if uri == "" {
+		return database.songs
+	}
+
+	var songs []*song
+
+	for _, song := range database.songs {
+		if song.file == uri || strings.HasPrefix(song.file, uri+"/") {
+			songs = append(songs, song)
+		}
+	}
+
+	return songs
+}
+
+func (database *database) find(filter filter) []*song {
+	var songs []*song
+
+	for _, song := range database.songs {
+		if filter.match(song) {
+			songs = append(songs, song)
+		}
+	}
+
+	return songs
+}
+
+// filter is a condition on a song's tags, from either the old "TAG VALUE..." form or an expression
+type filter interface {
+	match(song *song) bool
+}
+
+type tagFilter struct {
+	tag      string
+	value    string
+	operator string // ==, !=, contains or starts_with
+	fold     bool   // search ignores case, find doesn't
+}
+
+func (filter tagFilter) match(song *song) bool {
+	values := []string{song.tag(filter.tag)}
+
+	if filter.tag == "any" {
+		values = values[:0]
+		for _, tag := range tagNames {
+			values = append(values, song.tag(tag.key))
+		}
+
+		values = append(values, song.file)
+	}
+
+	matched := slices.ContainsFunc(values, func(value string) bool {
+		expected := filter.value
+
+		if filter.fold {
+			value = strings.ToLower(value)
+			expected = strings.ToLower(expected)
+		}
+
+		switch filter.operator {
+		case "contains":
+			return strings.Contains(value, expected)
+		case "starts_with":
+			return strings.HasPrefix(value, expected)
+		default:
+			return value == expected
+		}
+	})
+
+	if filter.operator == "!=" {
+		return !matched
+	}
+
+	return matched
+}
+
+type andFilter []filter
+
+func (filters andFilter) match(song *song) bool {
+	for _, filter := range filters {
+		if !filter.match(song) {
+			return false
+		}
+	}
+
+	return true
+}
+
+type notFilter struct {
+	filter filter
+}
+
+func (filter notFilter) match(song *song) bool {
+	return !filter.filter.match(song)
+}
+
+// parseFilter reads the arguments of find, search and list. search passes fold to match loosely,
+// the way mpd does it
+func parseFilter(args []string, fold bool) (filter, error) {
+	if len(args) == 1 && strings.HasPrefix(args[0], "(") {
+		parser := &expressionParser{input: args[0], fold: fold}
+
+		filter, err := parser.parse()
+		if err != nil {
+			return nil, err
+		}
+
+		if parser.skipSpaces(); parser.position != len(parser.input) {
+			return nil, argumentError("Unparsed garbage after expression")
+		}
+
+		return filter, nil
+	}
+
+	if len(args)%2 != 0 {
+		return nil, argumentError("Incorrect number of filter arguments")
+	}
+
+	operator := "=="
+	if fold {
+		operator = "contains"
+	}
+
+	filters := andFilter{}
+
+	for i := 0; i < len(args); i += 2 {
+		tag := strings.ToLower(args[i])
+		if !knownTag(tag) {
+			return nil, argumentError("Unknown filter type: %s", args[i])
+		}
+
+		filters = append(filters, tagFilter{tag: tag, value: args[i+1], operator: operator, fold: fold})
+	}
+
+	return filters, nil
+}
+
+func knownTag(tag string) bool {
+	if tag == "any" || tag == "file" {
+		return true
+	}
+
+	for _, name := range tagNames {
+		if name.key == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// expressionParser understands the subset of mpd's filter syntax clients actually send:
+// (TAG OP 'VALUE'), (!EXPRESSION) and (EXPRESSION AND EXPRESSION ...)
+type expressionParser struct {
+	input    string
+	position int
+	fold     bool
+}
+
+func (parser *expressionParser) skipSpaces() {
+	for parser.position < len(parser.input) && parser.input[parser.position] == ' ' {
+		parser.position++
+	}
+}
+
+func (parser *expressionParser) consume(prefix string) bool {
+	parser.skipSpaces()
+
+	if strings.HasPrefix(parser.input[parser.position:], prefix) {
+		parser.position += len(prefix)
+		return true
+	}
+
+	return false
+}
+
+func (parser *expressionParser) parse() (filter, error) {
+	if !parser.consume("(") {
+		return nil, argumentError("Expected '('")
+	}
+
+	if parser.consume("!") {
+		inner, err := parser.parse()
+		if err != nil {
+			return nil, err
+		}
+
+		if !parser.consume(")") {
+			return nil, argumentError("Expected ')'")
+		}
+
+		return notFilter{filter: inner}, nil
+	}
+
+	parser.skipSpaces()
+
+	// a nested expression means a list of them joined by AND
+	if strings.HasPrefix(parser.input[parser.position:], "(") {
+		filters := andFilter{}
+
+		for {
+			inner, err := parser.parse()
+			if err != nil {
+				return nil, err
+			}
+
+			filters = append(filters, inner)
+
+			if parser.consume(")") {
+				return filters, nil
+			}
+
+			if !parser.consume("AND") {
+				return nil, argumentError("Expected 'AND' or ')'")
+			}
+		}
+	}
+
+	tag := strings.ToLower(parser.word())
+	if !knownTag(tag) && tag != "base" {
+		return nil, argumentError("Unknown filter type: %s", tag)
+	}
+
+	operator := parser.word()
+	switch operator {
+	case "==", "!=", "contains", "starts_with":
+	default:
+		return nil, argumentError("Unknown filter operator: %s", operator)
+	}
+
+	value, err := parser.quoted()
+	if err != nil {
+		return nil, err
+	}
+
+	if !parser.consume(")") {
+		return nil, argumentError("Expected ')'")
+	}
+
+	// base limits the search to a directory
+	if tag == "base" {
+		return tagFilter{tag: "file", value: strings.Trim(value, "/") + "/", operator: "starts_with"}, nil
+	}
+
+	return tagFilter{tag: tag, value: value, operator: operator, fold: parser.fold}, nil
+}
+
+func (parser *expressionParser) word() string {
+	parser.skipSpaces()
+
+	start := parser.position
+	for parser.position < len(parser.input) && !strings.ContainsRune(" ()'\"", rune(parser.input[parser.position])) {
+		parser.position++
+	}
+
+	return parser.input[start:parser.position]
+}
+
+func (parser *expressionParser) quoted() (string, error) {
+	parser.skipSpaces()
+
+	if parser.position == len(parser.input) {
+		return "", argumentError("Expected quoted value")
+	}
+
+	quote := parser.input[parser.position]
+	if quote != '\'' && quote != '"' {
+		return "", argumentError("Expected quoted value")
+	}
+
+	var value strings.Builder
+
+	for parser.position++; parser.position < len(parser.input); parser.position++ {
+		char := parser.input[parser.position]
+
+		if char == '\\' && parser.position+1 < len(parser.input) {
+			parser.position++
+			value.WriteByte(parser.input[parser.position])
+
+			continue
+		}
+
+		if char == quote {
+			parser.position++
+			return value.String(), nil
+		}
+
+		value.WriteByte(char)
+	}
+
+	return "", argumentError("Closing quote not found")
+}
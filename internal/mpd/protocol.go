@@ -0,0 +1,152 @@
+package mpd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the protocol version wired claims, old enough that clients don't expect features it lacks
+const protocolVersion = "0.23.0"
+
+// ack codes from mpd's protocol.h
+const (
+	ackNotList    = 1
+	ackArgument   = 2
+	ackPermission = 4
+	ackUnknown    = 5
+	ackNoExist    = 50
+	ackSystem     = 52
+)
+
+var errUnbalancedQuotes = errors.New("unbalanced quotes")
+
+// ackError becomes an ACK line, command and index are filled in by whoever ran the command
+type ackError struct {
+	code    int
+	message string
+}
+
+func (err *ackError) Error() string {
+	return err.message
+}
+
+func argumentError(format string, args ...any) error {
+	return &ackError{code: ackArgument, message: fmt.Sprintf(format, args...)}
+}
+
+func noExistError(format string, args ...any) error {
+	return &ackError{code: ackNoExist, message: fmt.Sprintf(format, args...)}
+}
+
+func ackLine(err error, index int, command string) string {
+	code := ackSystem
+
+	var ack *ackError
+	if errors.As(err, &ack) {
+		code = ack.code
+	}
+
+	return fmt.Sprintf("ACK [%d@%d] {%s} %s\n", code, index, command, err.Error())
+}
+
+// tokenize splits a command line into its words, quoted words may contain spaces and escaped quotes
+func tokenize(line string) ([]string, error) {
+	var words []string
+
+	for i := 0; i < len(line); {
+		switch {
+		case line[i] == ' ' || line[i] == '\t':
+			i++
+
+		case line[i] == '"':
+			var word strings.Builder
+
+			i++
+			for ; i < len(line) && line[i] != '"'; i++ {
+				if line[i] == '\\' && i+1 < len(line) {
+					i++
+				}
+
+				word.WriteByte(line[i])
+			}
+
+			if i == len(line) {
+				return nil, errUnbalancedQuotes
+			}
+
+			words = append(words, word.String())
+			i++
+
+		default:
+			start := i
+			for i < len(line) && line[i] != ' ' && line[i] != '\t' {
+				i++
+			}
+
+			words = append(words, line[start:i])
+		}
+	}
+
+	return words, nil
+}
+
+// response collects "key: value" lines for one command
+type response struct {
+	strings.Builder
+}
+
+func (response *response) field(key string, value any) {
+	fmt.Fprintf(response, "%s: %v\n", key, value)
+}
+
+func parseInt(value string) (int, error) {
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, argumentError("Integer expected: %s", value)
+	}
+
+	return number, nil
+}
+
+// parseRange reads "START:END" or a single position, END may be left out to mean the end
+func parseRange(value string, length int) (int, int, error) {
+	first, last, isRange := strings.Cut(value, ":")
+
+	start, err := parseInt(first)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	end := start + 1
+	if isRange {
+		end = length
+
+		if last != "" {
+			if end, err = parseInt(last); err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+
+	if start < 0 || end < start {
+		return 0, 0, argumentError("Bad song index")
+	}
+
+	return start, end, nil
+}
+
+func parseSeconds(value string) (time.Duration, error) {
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, argumentError("Float expected: %s", value)
+	}
+
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+func formatSeconds(duration time.Duration) string {
+	return strconv.FormatFloat(duration.Seconds(), 'f', 3, 64)
+}
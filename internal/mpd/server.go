@@ -0,0 +1,326 @@
+// Package mpd speaks enough of the Music Player Daemon protocol for mpd clients to drive wired
+package mpd
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	config "wired/internal/config"
+	engine "wired/internal/engine"
+	library "wired/internal/library"
+)
+
+// subsystems reported by idle
+const (
+	subsystemDatabase = "database"
+	subsystemPlaylist = "playlist"
+	subsystemPlayer   = "player"
+	subsystemMixer    = "mixer"
+)
+
+type Server struct {
+	engine   *engine.Engine
+	listener net.Listener
+	database *database
+	// bumped whenever the queue changes, clients use it to tell whether to refetch it
+	version int
+	volume  int
+	clients map[*client]struct{}
+	closed  bool
+	stop    func()
+	mutex   sync.Mutex
+}
+
+// client is one connection, changes pile up in pending until it asks with idle
+type client struct {
+	conn    net.Conn
+	pending map[string]bool
+	wake    chan struct{}
+}
+
+func Listen(cfg config.MPD, player *engine.Engine) (*Server, error) {
+	listener, err := net.Listen("tcp", net.JoinHostPort(cfg.BindAddress, strconv.Itoa(cfg.Port)))
+	if err != nil {
+		return nil, err
+	}
+
+	events, stop := player.Subscribe()
+
+	server := &Server{
+		engine:   player,
+		listener: listener,
+		database: &database{byPath: map[string]*song{}},
+		version:  1,
+		volume:   player.Status().Volume,
+		clients:  map[*client]struct{}{},
+		stop:     stop,
+	}
+
+	go server.accept()
+	go server.follow(events)
+
+	return server, nil
+}
+
+// SetLibrary takes a copy of the library for clients to browse, root is the music library path
+// that song uris are relative to
+func (server *Server) SetLibrary(lib *library.Library, root string) {
+	database := newDatabase(lib, root)
+
+	server.mutex.Lock()
+	server.database = database
+	server.changed(subsystemDatabase)
+	server.mutex.Unlock()
+}
+
+func (server *Server) Close() error {
+	server.mutex.Lock()
+	server.closed = true
+
+	for client := range server.clients {
+		client.conn.Close()
+	}
+
+	server.mutex.Unlock()
+
+	server.stop()
+
+	return server.listener.Close()
+}
+
+func (server *Server) accept() {
+	for {
+		conn, err := server.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		client := &client{conn: conn, pending: map[string]bool{}, wake: make(chan struct{}, 1)}
+
+		server.mutex.Lock()
+		if server.closed {
+			server.mutex.Unlock()
+			conn.Close()
+
+			return
+		}
+
+		server.clients[client] = struct{}{}
+		server.mutex.Unlock()
+
+		go server.serve(client)
+	}
+}
+
+// follow turns engine events into the subsystems idle clients wait for
+func (server *Server) follow(events <-chan engine.Event) {
+	for event := range events {
+		volume := server.engine.Status().Volume
+
+		server.mutex.Lock()
+
+		switch event.Type {
+		case engine.QueueChanged:
+			server.version++
+			server.changed(subsystemPlaylist)
+		case engine.StateChanged:
+			// the engine reports volume changes as state changes too
+			if volume != server.volume {
+				server.volume = volume
+				server.changed(subsystemMixer)
+			} else {
+				server.changed(subsystemPlayer)
+			}
+		default:
+			server.changed(subsystemPlayer)
+		}
+
+		server.mutex.Unlock()
+	}
+}
+
+// changed has to be called with the mutex held
+func (server *Server) changed(subsystem string) {
+	for client := range server.clients {
+		client.pending[subsystem] = true
+
+		select {
+		case client.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (server *Server) serve(client *client) {
+	defer func() {
+		client.conn.Close()
+
+		server.mutex.Lock()
+		delete(server.clients, client)
+		server.mutex.Unlock()
+	}()
+
+	writer := bufio.NewWriter(client.conn)
+	writer.WriteString("OK MPD " + protocolVersion + "\n")
+	writer.Flush()
+
+	lines := make(chan string)
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		defer close(lines)
+
+		scanner := bufio.NewScanner(client.conn)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	// commands between command_list_begin and command_list_end run as one
+	var list []string
+	listing, listOK := false, false
+
+	for line := range lines {
+		if listing {
+			if line != "command_list_end" {
+				list = append(list, line)
+				continue
+			}
+
+			writer.WriteString(server.runList(list, listOK))
+			listing, list = false, nil
+		} else {
+			switch line {
+			case "command_list_begin", "command_list_ok_begin":
+				listing, listOK = true, line == "command_list_ok_begin"
+				continue
+			case "close":
+				return
+			case "noidle":
+				// too late, the idle it was meant for already returned
+				continue
+			}
+
+			words, err := tokenize(line)
+			if err == nil && len(words) > 0 && words[0] == "idle" {
+				if !server.idle(client, words[1:], writer, lines) {
+					return
+				}
+
+				continue
+			}
+
+			writer.WriteString(server.runList([]string{line}, false))
+		}
+
+		if writer.Flush() != nil {
+			return
+		}
+	}
+}
+
+// runList runs commands until one fails, answering with the output of all of them
+func (server *Server) runList(lines []string, listOK bool) string {
+	var out response
+
+	for index, line := range lines {
+		words, err := tokenize(line)
+		if err != nil {
+			return out.String() + ackLine(argumentError("%s", err.Error()), index, "")
+		}
+
+		if len(words) == 0 {
+			return out.String() + ackLine(&ackError{code: ackUnknown, message: "No command given"}, index, "")
+		}
+
+		name := strings.ToLower(words[0])
+
+		if err := server.run(name, words[1:], &out); err != nil {
+			// like mpd, unknown commands aren't named in the braces
+			if _, ok := commands[name]; !ok {
+				name = ""
+			}
+
+			return out.String() + ackLine(err, index, name)
+		}
+
+		if listOK {
+			out.WriteString("list_OK\n")
+		}
+	}
+
+	return out.String() + "OK\n"
+}
+
+// idle waits for one of the subsystems to change, or for noidle. it returns false when the
+// connection went away or the client sent something else, which mpd answers by hanging up
+func (server *Server) idle(client *client, subsystems []string, writer *bufio.Writer, lines <-chan string) bool {
+	for {
+		if changes := server.takeChanges(client, subsystems); len(changes) > 0 {
+			for _, subsystem := range changes {
+				writer.WriteString("changed: " + subsystem + "\n")
+			}
+
+			writer.WriteString("OK\n")
+
+			return writer.Flush() == nil
+		}
+
+		select {
+		case <-client.wake:
+		case line, ok := <-lines:
+			if !ok || line != "noidle" {
+				return false
+			}
+
+			for _, subsystem := range server.takeChanges(client, subsystems) {
+				writer.WriteString("changed: " + subsystem + "\n")
+			}
+
+			writer.WriteString("OK\n")
+
+			return writer.Flush() == nil
+		}
+	}
+}
+
+// takeChanges hands out the pending changes a client is interested in, all of them when it didn't say
+func (server *Server) takeChanges(client *client, subsystems []string) []string {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	var changes []string
+
+	for _, subsystem := range []string{subsystemDatabase, subsystemPlaylist, subsystemPlayer, subsystemMixer} {
+		if !client.pending[subsystem] {
+			continue
+		}
+
+		if len(subsystems) > 0 && !containsFold(subsystems, subsystem) {
+			continue
+		}
+
+		delete(client.pending, subsystem)
+		changes = append(changes, subsystem)
+	}
+
+	return changes
+}
+
+func containsFold(values []string, value string) bool {
+	for _, candidate := range values {
+		if strings.EqualFold(candidate, value) {
+			return true
+		}
+	}
+
+	return false
+}
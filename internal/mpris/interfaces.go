@@ -0,0 +1,228 @@
+package mpris
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	playback "wired/internal/playback"
+)
+
+// every exported method of these types is callable over the bus, so they hold nothing else
+
+// root is org.mpris.MediaPlayer2, wired lives in a terminal so there is nothing to raise or quit
+type root struct{}
+
+func (root *root) Raise() *dbus.Error {
+	return nil
+}
+
+func (root *root) Quit() *dbus.Error {
+	return nil
+}
+
+// control is org.mpris.MediaPlayer2.Player
+type control struct {
+	server *Server
+}
+
+func (control *control) Next() *dbus.Error {
+	return failed(control.server.engine.Next())
+}
+
+func (control *control) Previous() *dbus.Error {
+	return failed(control.server.engine.Previous())
+}
+
+func (control *control) Pause() *dbus.Error {
+	control.server.engine.Pause()
+	return nil
+}
+
+func (control *control) PlayPause() *dbus.Error {
+	return failed(control.server.engine.TogglePause())
+}
+
+func (control *control) Stop() *dbus.Error {
+	control.server.engine.Stop()
+	return nil
+}
+
+func (control *control) Play() *dbus.Error {
+	return failed(control.server.engine.Play())
+}
+
+// SeekBy is exported as Seek, it moves by offset microseconds and going past the end skips
+// to the next track as the spec says
+func (control *control) SeekBy(offset int64) *dbus.Error {
+	player := control.server.engine
+	status := player.Status()
+
+	if status.State == playback.Stopped || status.Duration == 0 {
+		return nil
+	}
+
+	position := status.Position + time.Duration(offset)*time.Microsecond
+	if position >= status.Duration {
+		return failed(player.Next())
+	}
+
+	return failed(player.Seek(max(position, 0)))
+}
+
+// SetPosition is ignored when the track changed in the meantime, as the spec says
+func (control *control) SetPosition(track dbus.ObjectPath, position int64) *dbus.Error {
+	player := control.server.engine
+	status := player.Status()
+
+	if track != trackID(status.Index) || position < 0 {
+		return nil
+	}
+
+	target := time.Duration(position) * time.Microsecond
+	if target > status.Duration {
+		return nil
+	}
+
+	return failed(player.Seek(target))
+}
+
+func (control *control) OpenUri(uri string) *dbus.Error {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return failed(err)
+	}
+
+	path := uri
+	if parsed.Scheme == "file" {
+		path = parsed.Path
+	}
+
+	player := control.server.engine
+
+	return failed(player.PlayNow(player.Resolve(path)))
+}
+
+// properties is org.freedesktop.DBus.Properties, written by hand since position has to be read live
+type properties struct {
+	server *Server
+}
+
+func (properties *properties) values(iface string) (map[string]dbus.Variant, *dbus.Error) {
+	switch iface {
+	case rootInterface:
+		return rootProperties(), nil
+	case playerInterface:
+		return properties.server.playerProperties(), nil
+	default:
+		return nil, dbus.MakeFailedError(dbus.ErrMsgUnknownInterface)
+	}
+}
+
+func (properties *properties) Get(iface string, name string) (dbus.Variant, *dbus.Error) {
+	values, err := properties.values(iface)
+	if err != nil {
+		return dbus.Variant{}, err
+	}
+
+	value, ok := values[name]
+	if !ok {
+		return dbus.Variant{}, &dbus.Error{Name: "org.freedesktop.DBus.Error.UnknownProperty", Body: []any{"unknown property " + name}}
+	}
+
+	return value, nil
+}
+
+func (properties *properties) GetAll(iface string) (map[string]dbus.Variant, *dbus.Error) {
+	return properties.values(iface)
+}
+
+// Set only knows volume, everything else is read only
+func (properties *properties) Set(iface string, name string, value dbus.Variant) *dbus.Error {
+	if iface != playerInterface || name != "Volume" {
+		return &dbus.Error{Name: "org.freedesktop.DBus.Error.PropertyReadOnly", Body: []any{name + " is read only"}}
+	}
+
+	volume, ok := value.Value().(float64)
+	if !ok {
+		return &dbus.Error{Name: "org.freedesktop.DBus.Error.InvalidArgs", Body: []any{"volume must be a double"}}
+	}
+
+	properties.server.engine.SetVolume(int(volume*100 + 0.5))
+
+	return nil
+}
+
+const introspection = `<node>
+  <interface name="org.mpris.MediaPlayer2">
+    <method name="Raise"/>
+    <method name="Quit"/>
+    <property name="CanQuit" type="b" access="read"/>
+    <property name="CanRaise" type="b" access="read"/>
+    <property name="HasTrackList" type="b" access="read"/>
+    <property name="Identity" type="s" access="read"/>
+    <property name="SupportedUriSchemes" type="as" access="read"/>
+    <property name="SupportedMimeTypes" type="as" access="read"/>
+  </interface>
+  <interface name="org.mpris.MediaPlayer2.Player">
+    <method name="Next"/>
+    <method name="Previous"/>
+    <method name="Pause"/>
+    <method name="PlayPause"/>
+    <method name="Stop"/>
+    <method name="Play"/>
+    <method name="Seek">
+      <arg name="Offset" type="x" direction="in"/>
+    </method>
+    <method name="SetPosition">
+      <arg name="TrackId" type="o" direction="in"/>
+      <arg name="Position" type="x" direction="in"/>
+    </method>
+    <method name="OpenUri">
+      <arg name="Uri" type="s" direction="in"/>
+    </method>
+    <signal name="Seeked">
+      <arg name="Position" type="x"/>
+    </signal>
+    <property name="PlaybackStatus" type="s" access="read"/>
+    <property name="Rate" type="d" access="read"/>
+    <property name="Metadata" type="a{sv}" access="read"/>
+    <property name="Volume" type="d" access="readwrite"/>
+    <property name="Position" type="x" access="read"/>
+    <property name="MinimumRate" type="d" access="read"/>
+    <property name="MaximumRate" type="d" access="read"/>
+    <property name="CanGoNext" type="b" access="read"/>
+    <property name="CanGoPrevious" type="b" access="read"/>
+    <property name="CanPlay" type="b" access="read"/>
+    <property name="CanPause" type="b" access="read"/>
+    <property name="CanSeek" type="b" access="read"/>
+    <property name="CanControl" type="b" access="read"/>
+  </interface>
+  <interface name="org.freedesktop.DBus.Properties">
+    <method name="Get">
+      <arg name="interface_name" type="s" direction="in"/>
+      <arg name="property_name" type="s" direction="in"/>
+      <arg name="value" type="v" direction="out"/>
+    </method>
+    <method name="GetAll">
+      <arg name="interface_name" type="s" direction="in"/>
+      <arg name="properties" type="a{sv}" direction="out"/>
+    </method>
+    <method name="Set">
+      <arg name="interface_name" type="s" direction="in"/>
+      <arg name="property_name" type="s" direction="in"/>
+      <arg name="value" type="v" direction="in"/>
+    </method>
+    <signal name="PropertiesChanged">
+      <arg name="interface_name" type="s"/>
+      <arg name="changed_properties" type="a{sv}"/>
+      <arg name="invalidated_properties" type="as"/>
+    </signal>
+  </interface>
+  <interface name="org.freedesktop.DBus.Introspectable">
+    <method name="Introspect">
+      <arg name="data" type="s" direction="out"/>
+    </method>
+  </interface>
+</node>`
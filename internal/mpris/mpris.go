@@ -0,0 +1,274 @@
+// Package mpris publishes the engine on the session bus as an MPRIS2 media player,
+// so media keys, playerctl and desktop widgets can see and drive it
+package mpris
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+
+	engine "wired/internal/engine"
+	playback "wired/internal/playback"
+)
+
+const (
+	busName    = "org.mpris.MediaPlayer2.wired"
+	objectPath = dbus.ObjectPath("/org/mpris/MediaPlayer2")
+
+	rootInterface       = "org.mpris.MediaPlayer2"
+	playerInterface     = "org.mpris.MediaPlayer2.Player"
+	propertiesInterface = "org.freedesktop.DBus.Properties"
+
+	// the spec's way of saying nothing is loaded
+	noTrack = dbus.ObjectPath("/org/mpris/MediaPlayer2/TrackList/NoTrack")
+)
+
+var ErrNameTaken = errors.New("couldn't own an mpris name on the session bus")
+
+// Server answers the bus on behalf of the engine and tells it about every change
+type Server struct {
+	conn   *dbus.Conn
+	engine *engine.Engine
+	stop   func()
+	// what was last announced, so only real changes are signaled
+	announced map[string]dbus.Variant
+	mutex     sync.Mutex
+}
+
+// Serve connects to the session bus, failing when there is none, e.g. over ssh
+func Serve(player *engine.Engine) (*Server, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, err
+	}
+
+	server, err := serve(conn, player)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return server, nil
+}
+
+func serve(conn *dbus.Conn, player *engine.Engine) (*Server, error) {
+	server := &Server{conn: conn, engine: player}
+
+	exports := []struct {
+		value   any
+		mapping map[string]string
+		name    string
+	}{
+		{&root{}, nil, rootInterface},
+		// go vet wants Seek to look like io.Seeker's
+		{&control{server: server}, map[string]string{"SeekBy": "Seek"}, playerInterface},
+		{&properties{server: server}, nil, propertiesInterface},
+		{introspect.Introspectable(introspection), nil, "org.freedesktop.DBus.Introspectable"},
+	}
+
+	for _, export := range exports {
+		if err := conn.ExportWithMap(export.value, export.mapping, objectPath, export.name); err != nil {
+			return nil, err
+		}
+	}
+
+	// a second wired takes a name of its own, as the spec suggests
+	for _, name := range []string{busName, fmt.Sprintf("%s.instance%d", busName, os.Getpid())} {
+		reply, err := conn.RequestName(name, dbus.NameFlagDoNotQueue)
+		if err != nil {
+			return nil, err
+		}
+
+		if reply == dbus.RequestNameReplyPrimaryOwner {
+			events, stop := player.Subscribe()
+			server.stop = stop
+			server.announced = server.changing()
+
+			go server.follow(events)
+
+			return server, nil
+		}
+	}
+
+	return nil, ErrNameTaken
+}
+
+func (server *Server) Close() error {
+	if server.stop != nil {
+		server.stop()
+	}
+
+	return server.conn.Close()
+}
+
+// follow signals whatever the engine changed to the bus
+func (server *Server) follow(events <-chan engine.Event) {
+	for event := range events {
+		if event.Type == engine.Seeked {
+			server.seeked()
+			continue
+		}
+
+		server.announce()
+	}
+}
+
+func (server *Server) announce() {
+	current := server.changing()
+	changed := map[string]dbus.Variant{}
+
+	server.mutex.Lock()
+
+	for name, value := range current {
+		if previous, ok := server.announced[name]; !ok || previous.String() != value.String() {
+			changed[name] = value
+		}
+	}
+
+	server.announced = current
+
+	server.mutex.Unlock()
+
+	if len(changed) == 0 {
+		return
+	}
+
+	_ = server.conn.Emit(objectPath, propertiesInterface+".PropertiesChanged", playerInterface, changed, []string{})
+}
+
+func (server *Server) seeked() {
+	_ = server.conn.Emit(objectPath, playerInterface+".Seeked", microseconds(server.engine.Status().Position))
+}
+
+// changing are the player properties that get signaled, position moves all the time so it's only ever read
+func (server *Server) changing() map[string]dbus.Variant {
+	status := server.engine.Status()
+	queue, current := server.engine.Queue()
+
+	return map[string]dbus.Variant{
+		"PlaybackStatus": dbus.MakeVariant(playbackStatus(status.State)),
+		"Metadata":       dbus.MakeVariant(metadata(status)),
+		"Volume":         dbus.MakeVariant(float64(status.Volume) / 100),
+		"CanGoNext":      dbus.MakeVariant(current+1 < len(queue)),
+		"CanGoPrevious":  dbus.MakeVariant(status.State != playback.Stopped || current > 0),
+		"CanPlay":        dbus.MakeVariant(len(queue) > 0),
+		"CanPause":       dbus.MakeVariant(status.State != playback.Stopped),
+		"CanSeek":        dbus.MakeVariant(status.State != playback.Stopped && status.Duration > 0),
+	}
+}
+
+func (server *Server) playerProperties() map[string]dbus.Variant {
+	values := server.changing()
+
+	values["Position"] = dbus.MakeVariant(microseconds(server.engine.Status().Position))
+	values["Rate"] = dbus.MakeVariant(1.0)
+	values["MinimumRate"] = dbus.MakeVariant(1.0)
+	values["MaximumRate"] = dbus.MakeVariant(1.0)
+	values["CanControl"] = dbus.MakeVariant(true)
+
+	return values
+}
+
+func rootProperties() map[string]dbus.Variant {
+	return map[string]dbus.Variant{
+		"CanQuit":             dbus.MakeVariant(false),
+		"CanRaise":            dbus.MakeVariant(false),
+		"HasTrackList":        dbus.MakeVariant(false),
+		"Identity":            dbus.MakeVariant("wired"),
+		"SupportedUriSchemes": dbus.MakeVariant([]string{"file", "http", "https"}),
+		"SupportedMimeTypes": dbus.MakeVariant([]string{
+			"audio/mpeg", "audio/flac", "audio/x-flac", "audio/wav", "audio/x-wav", "audio/ogg", "audio/mp4",
+		}),
+	}
+}
+
+func playbackStatus(state playback.State) string {
+	switch state {
+	case playback.Playing:
+		return "Playing"
+	case playback.Paused:
+		return "Paused"
+	default:
+		return "Stopped"
+	}
+}
+
+// trackID names the queue entry, the spec only wants it unique within the queue
+func trackID(index int) dbus.ObjectPath {
+	if index < 0 {
+		return noTrack
+	}
+
+	return dbus.ObjectPath(fmt.Sprintf("/org/wired/track/%d", index))
+}
+
+func metadata(status engine.Status) map[string]dbus.Variant {
+	values := map[string]dbus.Variant{"mpris:trackid": dbus.MakeVariant(trackID(status.Index))}
+
+	if status.State == playback.Stopped {
+		return values
+	}
+
+	track := status.Track
+
+	values["xesam:url"] = dbus.MakeVariant(fileURL(track.Path))
+	values["xesam:title"] = dbus.MakeVariant(track.Title)
+
+	if track.StreamTitle != "" {
+		// radios put "artist - title" in there, which is what players show as the title anyway
+		values["xesam:title"] = dbus.MakeVariant(track.StreamTitle)
+		values["xesam:album"] = dbus.MakeVariant(track.Title)
+	}
+
+	if status.Duration > 0 {
+		values["mpris:length"] = dbus.MakeVariant(microseconds(status.Duration))
+	}
+
+	if track.Artist != "" {
+		values["xesam:artist"] = dbus.MakeVariant([]string{track.Artist})
+	}
+
+	if track.Album != "" {
+		values["xesam:album"] = dbus.MakeVariant(track.Album)
+	}
+
+	if track.AlbumArtist != "" {
+		values["xesam:albumArtist"] = dbus.MakeVariant([]string{track.AlbumArtist})
+	}
+
+	if track.TrackNumber > 0 {
+		values["xesam:trackNumber"] = dbus.MakeVariant(int32(track.TrackNumber))
+	}
+
+	if track.Cover != "" {
+		values["mpris:artUrl"] = dbus.MakeVariant(fileURL(track.Cover))
+	}
+
+	return values
+}
+
+func fileURL(path string) string {
+	if playback.IsStream(path) {
+		return path
+	}
+
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+func microseconds(duration time.Duration) int64 {
+	return duration.Microseconds()
+}
+
+func failed(err error) *dbus.Error {
+	if err == nil {
+		return nil
+	}
+
+	return dbus.MakeFailedError(err)
+}
@@ -0,0 +1,316 @@
+package mpris
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	engine "wired/internal/engine"
+	playback "wired/internal/playback"
+)
+
+var testFormat = playback.Format{SampleRate: 8000, Channels: 2}
+
+// pacedOutput takes about as long as a sound card would, so tracks last long enough to be looked at
+type pacedOutput struct {
+	*playback.NullOutput
+}
+
+func (output pacedOutput) Write(samples []float32) error {
+	time.Sleep(time.Duration(len(samples)/testFormat.Channels) * time.Second / time.Duration(testFormat.SampleRate))
+	return output.NullOutput.Write(samples)
+}
+
+// sessionBus starts a bus of the test's own, so nothing on the machine's session bus is touched
+func sessionBus(t *testing.T) string {
+	t.Helper()
+
+	binary, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon isn't installed")
+	}
+
+	daemon := exec.Command(binary, "--session", "--print-address", "--nofork")
+
+	stdout, err := daemon.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := daemon.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		_ = daemon.Process.Kill()
+		_ = daemon.Wait()
+	})
+
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return strings.TrimSpace(address)
+}
+
+func connect(t *testing.T, address string) *dbus.Conn {
+	t.Helper()
+
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+func writeTrack(t *testing.T, name string, length time.Duration) engine.Track {
+	t.Helper()
+
+	samples := make([]float32, int(length.Seconds()*float64(testFormat.SampleRate))*testFormat.Channels)
+	data := playback.EncodeInt16(nil, samples)
+	path := filepath.Join(t.TempDir(), name+".wav")
+
+	if err := os.WriteFile(path, append(playback.WAVHeader(testFormat, uint32(len(data))), data...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return engine.Track{Path: path, Title: name, Artist: "artist", Album: "album", TrackNumber: 1, Duration: length}
+}
+
+// testServer publishes an engine with two tracks queued on a bus of its own, and returns a client for it
+func testServer(t *testing.T) (*engine.Engine, dbus.BusObject, chan *dbus.Signal) {
+	t.Helper()
+
+	address := sessionBus(t)
+
+	// the queue is saved as it changes
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	player := engine.New(pacedOutput{playback.NewNullOutput()})
+	t.Cleanup(func() { player.Close() })
+
+	if err := player.Append(writeTrack(t, "one", time.Minute), writeTrack(t, "two", time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	server, err := serve(connect(t, address), player)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { server.Close() })
+
+	client := connect(t, address)
+
+	if err := client.AddMatchSignal(dbus.WithMatchObjectPath(objectPath)); err != nil {
+		t.Fatal(err)
+	}
+
+	signals := make(chan *dbus.Signal, 100)
+	client.Signal(signals)
+
+	return player, client.Object(busName, objectPath), signals
+}
+
+func get(t *testing.T, object dbus.BusObject, name string) any {
+	t.Helper()
+
+	value, err := object.GetProperty(playerInterface + "." + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return value.Value()
+}
+
+func call(t *testing.T, object dbus.BusObject, method string, args ...any) {
+	t.Helper()
+
+	if err := object.Call(playerInterface+"."+method, 0, args...).Err; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// changed waits for a PropertiesChanged that has name in it, skipping any others
+func changed(t *testing.T, signals chan *dbus.Signal, name string) any {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+
+	for {
+		select {
+		case signal := <-signals:
+			if signal.Name != propertiesInterface+".PropertiesChanged" {
+				continue
+			}
+
+			if signal.Body[0] != playerInterface {
+				t.Fatalf("changed properties of %v", signal.Body[0])
+			}
+
+			if value, ok := signal.Body[1].(map[string]dbus.Variant)[name]; ok {
+				return value.Value()
+			}
+		case <-timeout:
+			t.Fatalf("%s never changed", name)
+			return nil
+		}
+	}
+}
+
+func seeked(t *testing.T, signals chan *dbus.Signal) time.Duration {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+
+	for {
+		select {
+		case signal := <-signals:
+			if signal.Name == playerInterface+".Seeked" {
+				return time.Duration(signal.Body[0].(int64)) * time.Microsecond
+			}
+		case <-timeout:
+			t.Fatal("never seeked")
+			return 0
+		}
+	}
+}
+
+func TestServeStopped(t *testing.T) {
+	_, object, _ := testServer(t)
+
+	if status := get(t, object, "PlaybackStatus"); status != "Stopped" {
+		t.Fatalf("status is %v", status)
+	}
+
+	metadata := get(t, object, "Metadata").(map[string]dbus.Variant)
+	if len(metadata) != 1 || metadata["mpris:trackid"].Value() != noTrack {
+		t.Fatalf("metadata is %v with nothing playing", metadata)
+	}
+
+	if position := get(t, object, "Position"); position != int64(0) {
+		t.Fatalf("position is %v", position)
+	}
+
+	if get(t, object, "CanPlay") != true || get(t, object, "CanGoNext") != true || get(t, object, "CanSeek") != false {
+		t.Fatal("wrong capabilities with two tracks queued")
+	}
+
+	identity, err := object.GetProperty(rootInterface + ".Identity")
+	if err != nil || identity.Value() != "wired" {
+		t.Fatalf("identity is %v, %v", identity, err)
+	}
+
+	if _, err := object.GetProperty(playerInterface + ".Shuffle"); err == nil {
+		t.Fatal("an unknown property was answered")
+	}
+}
+
+func TestServeControls(t *testing.T) {
+	player, object, signals := testServer(t)
+
+	call(t, object, "PlayPause")
+
+	if status := changed(t, signals, "PlaybackStatus"); status != "Playing" {
+		t.Fatalf("status changed to %v", status)
+	}
+
+	metadata := get(t, object, "Metadata").(map[string]dbus.Variant)
+
+	want := map[string]any{
+		"mpris:trackid":     trackID(0),
+		"xesam:title":       "one",
+		"xesam:artist":      []string{"artist"},
+		"xesam:album":       "album",
+		"xesam:trackNumber": int32(1),
+		"mpris:length":      time.Minute.Microseconds(),
+	}
+
+	for name, value := range want {
+		if dbus.MakeVariant(value).String() != metadata[name].String() {
+			t.Errorf("%s is %v, want %v", name, metadata[name], value)
+		}
+	}
+
+	if url := metadata["xesam:url"].Value().(string); !strings.HasPrefix(url, "file:///") || !strings.HasSuffix(url, "one.wav") {
+		t.Errorf("url is %s", url)
+	}
+
+	// position is read live rather than signaled, it moves a block at a time
+	deadline := time.Now().Add(5 * time.Second)
+	for get(t, object, "Position").(int64) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("position didn't move while playing")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	call(t, object, "Seek", (30 * time.Second).Microseconds())
+
+	if position := seeked(t, signals); position < 30*time.Second || position > 31*time.Second {
+		t.Fatalf("seeked to %s", position)
+	}
+
+	if position := time.Duration(get(t, object, "Position").(int64)) * time.Microsecond; position < 30*time.Second {
+		t.Fatalf("position is %s after seeking 30s in", position)
+	}
+
+	call(t, object, "PlayPause")
+
+	if status := changed(t, signals, "PlaybackStatus"); status != "Paused" {
+		t.Fatalf("status changed to %v", status)
+	}
+
+	if player.Status().State != playback.Paused {
+		t.Fatal("the engine didn't pause")
+	}
+
+	call(t, object, "Next")
+
+	metadata = changed(t, signals, "Metadata").(map[string]dbus.Variant)
+	if metadata["mpris:trackid"].Value() != trackID(1) || metadata["xesam:title"].Value() != "two" {
+		t.Fatalf("metadata changed to %v", metadata)
+	}
+
+	if get(t, object, "CanGoNext") != false {
+		t.Fatal("can go next from the last track")
+	}
+
+	// seeking past the end skips to the next track, there is none so it stops
+	call(t, object, "Seek", (2 * time.Minute).Microseconds())
+
+	if status := changed(t, signals, "PlaybackStatus"); status != "Stopped" {
+		t.Fatalf("status changed to %v", status)
+	}
+}
+
+func TestServeVolume(t *testing.T) {
+	player, object, signals := testServer(t)
+
+	if err := object.SetProperty(playerInterface+".Volume", dbus.MakeVariant(0.25)); err != nil {
+		t.Fatal(err)
+	}
+
+	if volume := changed(t, signals, "Volume"); volume != 0.25 {
+		t.Fatalf("volume changed to %v", volume)
+	}
+
+	if player.Status().Volume != 25 {
+		t.Fatalf("the engine's volume is %d", player.Status().Volume)
+	}
+
+	if err := object.SetProperty(playerInterface+".PlaybackStatus", dbus.MakeVariant("Playing")); err == nil {
+		t.Fatal("a read only property was set")
+	}
+}
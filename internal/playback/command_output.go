@@ -0,0 +1,160 @@
+package playback
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+)
+
+var ErrNoAudioDevice = errors.New("no supported audio player found in PATH (pw-play, paplay, aplay, play or ffplay)")
+
+type commandBuilder func(format Format) []string
+
+// candidates are tried in order, every one of them reads raw signed 16-bit samples from stdin
+var commandCandidates = []struct {
+	name  string
+	build commandBuilder
+}{
+	{"pw-play", func(format Format) []string {
+		return []string{
+			"--format", "s16",
+			"--rate", fmt.Sprint(format.SampleRate),
+			"--channels", fmt.Sprint(format.Channels),
+			"-",
+		}
+	}},
+	{"paplay", func(format Format) []string {
+		return []string{
+			"--raw",
+			"--format=s16le",
+			fmt.Sprintf("--rate=%d", format.SampleRate),
+			fmt.Sprintf("--channels=%d", format.Channels),
+		}
+	}},
+	{"aplay", func(format Format) []string {
+		return []string{
+			"-q",
+			"-t", "raw",
+			"-f", "S16_LE",
+			"-r", fmt.Sprint(format.SampleRate),
+			"-c", fmt.Sprint(format.Channels),
+			"-",
+		}
+	}},
+	{"play", func(format Format) []string {
+		return []string{
+			"-q",
+			"-t", "raw",
+			"-e", "signed-integer",
+			"-b", "16",
+			"-r", fmt.Sprint(format.SampleRate),
+			"-c", fmt.Sprint(format.Channels),
+			"-",
+		}
+	}},
+	{"ffplay", func(format Format) []string {
+		return []string{
+			"-nodisp",
+			"-autoexit",
+			"-loglevel", "quiet",
+			"-f", "s16le",
+			"-ar", fmt.Sprint(format.SampleRate),
+			"-ac", fmt.Sprint(format.Channels),
+			"-i", "-",
+		}
+	}},
+}
+
+// CommandOutput pipes samples into an external audio player, which keeps wired free of cgo
+type CommandOutput struct {
+	name    string
+	build   commandBuilder
+	format  Format
+	command *exec.Cmd
+	stdin   io.WriteCloser
+	mutex   sync.Mutex
+}
+
+// NewDeviceOutput picks the first audio player available on the system
+func NewDeviceOutput() (*CommandOutput, error) {
+	for _, candidate := range commandCandidates {
+		if _, err := exec.LookPath(candidate.name); err == nil {
+			return &CommandOutput{name: candidate.name, build: candidate.build}, nil
+		}
+	}
+
+	return nil, ErrNoAudioDevice
+}
+
+func (output *CommandOutput) Name() string {
+	return output.name
+}
+
+func (output *CommandOutput) Open(format Format) error {
+	output.mutex.Lock()
+	defer output.mutex.Unlock()
+
+	if output.command != nil {
+		if format == output.format {
+			return nil
+		}
+
+		output.stop()
+	}
+
+	command := exec.Command(output.name, output.build(format)...)
+
+	stdin, err := command.StdinPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := command.Start(); err != nil {
+		return err
+	}
+
+	output.command = command
+	output.stdin = stdin
+	output.format = format
+
+	return nil
+}
+
+func (output *CommandOutput) Write(samples []float32) error {
+	output.mutex.Lock()
+	stdin := output.stdin
+	output.mutex.Unlock()
+
+	if stdin == nil {
+		return io.ErrClosedPipe
+	}
+
+	// written outside the lock since the pipe blocks until the player catches up,
+	// so every write gets its own buffer rather than one another write could be filling
+	_, err := stdin.Write(EncodeInt16(nil, samples))
+	return err
+}
+
+func (output *CommandOutput) Close() error {
+	output.mutex.Lock()
+	defer output.mutex.Unlock()
+
+	output.stop()
+
+	return nil
+}
+
+func (output *CommandOutput) stop() {
+	if output.command == nil {
+		return
+	}
+
+	output.stdin.Close()
+	_ = output.command.Process.Kill()
+	_ = output.command.Wait()
+
+	output.command = nil
+	output.stdin = nil
+}
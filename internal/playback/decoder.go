@@ -0,0 +1,70 @@
+package playback
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type Decoder interface {
+	Format() Format
+	// Read fills samples with interleaved PCM, returning the amount of samples written
+	Read(samples []float32) (int, error)
+	Seek(position time.Duration) error
+	// Length returns zero when the length of the stream is unknown
+	Length() time.Duration
+	Close() error
+}
+
+type openFunc func(path string) (Decoder, error)
+
+var decoders = map[string]openFunc{
+	".mp3":  openMP3,
+	".flac": openFLAC,
+	".wav":  openWAV,
+	".ogg":  openFFmpeg,
+	".m4a":  openFFmpeg,
+}
+
+// formats decoded through ffmpeg have their length read straight from the container
+var containerLengths = map[string]func(path string) (time.Duration, error){
+	".ogg": oggLength,
+	".m4a": mp4Length,
+}
+
+// Open picks a decoder based on the file extension, urls are played as streams
+func Open(path string) (Decoder, error) {
+	if IsStream(path) {
+		return openStream(path)
+	}
+
+	extension := strings.ToLower(filepath.Ext(path))
+
+	open, ok := decoders[extension]
+	if !ok {
+		return nil, ErrUnsupportedFormat
+	}
+
+	return open(path)
+}
+
+// Probe returns the length of a file while decoding as little of it as the format allows
+func Probe(path string) (time.Duration, error) {
+	extension := strings.ToLower(filepath.Ext(path))
+
+	if length, ok := containerLengths[extension]; ok {
+		return length(path)
+	}
+
+	decoder, err := Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer decoder.Close()
+
+	return decoder.Length(), nil
+}
+
+func clampSample(sample float32) float32 {
+	return max(-1, min(1, sample))
+}
@@ -0,0 +1,355 @@
+package playback
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	flac "github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/frame"
+	"github.com/mewkiz/flac/meta"
+)
+
+var testFormat = Format{SampleRate: 8000, Channels: 2}
+
+// ramp is a signal that's easy to check after a round trip, 16-bit values as floats
+func ramp(frames int, channels int) []float32 {
+	samples := make([]float32, frames*channels)
+	for i := range samples {
+		samples[i] = float32(i%200-100) * 100 / 32768
+	}
+
+	return samples
+}
+
+func writeWAV(t *testing.T, format Format, samples []float32) string {
+	t.Helper()
+
+	data := EncodeInt16(nil, samples)
+	path := filepath.Join(t.TempDir(), "track.wav")
+
+	if err := os.WriteFile(path, append(WAVHeader(format, uint32(len(data))), data...), filePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func writeFLAC(t *testing.T, format Format, samples []float32) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "track.flac")
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := &meta.StreamInfo{
+		BlockSizeMin:  bufferFrames,
+		BlockSizeMax:  bufferFrames,
+		SampleRate:    uint32(format.SampleRate),
+		NChannels:     uint8(format.Channels),
+		BitsPerSample: 16,
+	}
+
+	encoder, err := flac.NewEncoder(f, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frames := len(samples) / format.Channels
+
+	for start := 0; start < frames; start += bufferFrames {
+		count := min(bufferFrames, frames-start)
+
+		block := &frame.Frame{
+			Header: frame.Header{
+				HasFixedBlockSize: true,
+				BlockSize:         uint16(count),
+				SampleRate:        uint32(format.SampleRate),
+				Channels:          frame.ChannelsLR,
+				BitsPerSample:     16,
+			},
+		}
+
+		for channel := range format.Channels {
+			subframe := &frame.Subframe{
+				SubHeader: frame.SubHeader{Pred: frame.PredVerbatim},
+				NSamples:  count,
+			}
+
+			for i := range count {
+				sample := samples[(start+i)*format.Channels+channel]
+				subframe.Samples = append(subframe.Samples, int32(math.Round(float64(sample)*32768)))
+			}
+
+			block.Subframes = append(block.Subframes, subframe)
+		}
+
+		if err := encoder.WriteFrame(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := encoder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// writeMP3 writes frames of silence, 128kbps stereo at 44.1kHz, whose side info being
+// all zeros is a valid frame without any audio in it
+func writeMP3(t *testing.T, frames int) string {
+	t.Helper()
+
+	const frameSize = 144 * 128000 / 44100
+
+	var data []byte
+	for range frames {
+		block := make([]byte, frameSize)
+		copy(block, []byte{0xff, 0xfb, 0x90, 0x00})
+		data = append(data, block...)
+	}
+
+	path := filepath.Join(t.TempDir(), "track.mp3")
+	if err := os.WriteFile(path, data, filePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func readAll(t *testing.T, decoder Decoder) []float32 {
+	t.Helper()
+
+	var samples []float32
+	buffer := make([]float32, 1000*decoder.Format().Channels)
+
+	for {
+		n, err := decoder.Read(buffer)
+		samples = append(samples, buffer[:n]...)
+
+		if err == io.EOF {
+			return samples
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func equalSamples(t *testing.T, got []float32, want []float32) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d samples, want %d", len(got), len(want))
+	}
+
+	for i := range want {
+		if math.Abs(float64(got[i]-want[i])) > 1.0/32767 {
+			t.Fatalf("sample %d is %f, want %f", i, got[i], want[i])
+		}
+	}
+}
+
+func TestDecoders(t *testing.T) {
+	samples := ramp(3*bufferFrames+100, testFormat.Channels)
+	length := testFormat.framesToDuration(int64(len(samples) / testFormat.Channels))
+
+	tests := []struct {
+		name  string
+		write func(t *testing.T, format Format, samples []float32) string
+	}{
+		{"wav", writeWAV},
+		{"flac", writeFLAC},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			decoder, err := Open(test.write(t, testFormat, samples))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer decoder.Close()
+
+			if decoder.Format() != testFormat {
+				t.Fatalf("format is %+v, want %+v", decoder.Format(), testFormat)
+			}
+
+			if decoder.Length() != length {
+				t.Fatalf("length is %s, want %s", decoder.Length(), length)
+			}
+
+			equalSamples(t, readAll(t, decoder), samples)
+
+			// back to the middle of the second block, whatever's after it should come out again
+			position := testFormat.framesToDuration(bufferFrames + 500)
+			if err := decoder.Seek(position); err != nil {
+				t.Fatal(err)
+			}
+
+			equalSamples(t, readAll(t, decoder), samples[(bufferFrames+500)*testFormat.Channels:])
+		})
+	}
+}
+
+func TestMP3Decoder(t *testing.T) {
+	const frames = 40
+
+	decoder, err := Open(writeMP3(t, frames))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer decoder.Close()
+
+	if format := (Format{SampleRate: 44100, Channels: 2}); decoder.Format() != format {
+		t.Fatalf("format is %+v, want %+v", decoder.Format(), format)
+	}
+
+	// 1152 samples to a frame, for each channel
+	samples := readAll(t, decoder)
+	if len(samples) != frames*1152*2 {
+		t.Fatalf("got %d samples, want %d", len(samples), frames*1152*2)
+	}
+
+	for i, sample := range samples {
+		if sample != 0 {
+			t.Fatalf("sample %d of silence is %f", i, sample)
+		}
+	}
+
+	length := decoder.Format().framesToDuration(int64(len(samples) / 2))
+	if decoder.Length() != length {
+		t.Fatalf("length is %s, decoded %s", decoder.Length(), length)
+	}
+
+	if err := decoder.Seek(length / 2); err != nil {
+		t.Fatal(err)
+	}
+
+	want := len(samples) - int(decoder.Format().durationToFrames(length/2))*2
+	if rest := readAll(t, decoder); len(rest) != want {
+		t.Fatalf("got %d samples after seeking halfway, want %d", len(rest), want)
+	}
+}
+
+func TestOpenUnsupported(t *testing.T) {
+	if _, err := Open("track.xyz"); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Fatalf("got %v, want %v", err, ErrUnsupportedFormat)
+	}
+}
+
+func TestFileOutput(t *testing.T) {
+	samples := ramp(5000, testFormat.Channels)
+	path := filepath.Join(t.TempDir(), "out.wav")
+
+	output := NewFileOutput(path)
+	if err := output.Open(testFormat); err != nil {
+		t.Fatal(err)
+	}
+
+	for start := 0; start < len(samples); start += 1000 {
+		if err := output.Write(samples[start:min(start+1000, len(samples))]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := output.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	decoder, err := openWAV(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer decoder.Close()
+
+	if decoder.Format() != testFormat {
+		t.Fatalf("format is %+v, want %+v", decoder.Format(), testFormat)
+	}
+
+	if length := testFormat.framesToDuration(5000); decoder.Length() != length {
+		t.Fatalf("length is %s, want %s", decoder.Length(), length)
+	}
+
+	equalSamples(t, readAll(t, decoder), samples)
+
+	// the sizes in the header were patched on close
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if size := binary.LittleEndian.Uint32(data[40:44]); int(size) != len(samples)*2 {
+		t.Fatalf("data size is %d, want %d", size, len(samples)*2)
+	}
+}
+
+func TestFileOutputMixedFormats(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.wav")
+	first := ramp(1000, testFormat.Channels)
+	// twice the rate in mono, easy to recognize once it's stereo at the file's rate
+	second := slices.Repeat([]float32{0.5}, 2000)
+
+	output := NewFileOutput(path)
+
+	tracks := []struct {
+		format  Format
+		samples []float32
+	}{
+		{testFormat, first},
+		{Format{SampleRate: 2 * testFormat.SampleRate, Channels: 1}, second},
+		{testFormat, first},
+	}
+
+	for _, track := range tracks {
+		if err := output.Open(track.format); err != nil {
+			t.Fatalf("opening %+v gave %v", track.format, err)
+		}
+
+		// in a few writes, the resampler carries over what's between them
+		for start := 0; start < len(track.samples); start += 300 {
+			if err := output.Write(track.samples[start:min(start+300, len(track.samples))]); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if err := output.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	decoder, err := openWAV(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer decoder.Close()
+
+	if decoder.Format() != testFormat {
+		t.Fatalf("format is %+v, want the first track's %+v", decoder.Format(), testFormat)
+	}
+
+	samples := readAll(t, decoder)
+
+	want := slices.Concat(first, slices.Repeat([]float32{0.5}, len(second)), first)
+	equalSamples(t, samples, want)
+}
+
+func TestFramesToDuration(t *testing.T) {
+	if duration := testFormat.framesToDuration(8000); duration != time.Second {
+		t.Fatalf("8000 frames at 8kHz are %s", duration)
+	}
+
+	if frames := testFormat.durationToFrames(1500 * time.Millisecond); frames != 12000 {
+		t.Fatalf("1.5s at 8kHz is %d frames", frames)
+	}
+}
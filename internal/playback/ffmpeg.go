@@ -0,0 +1,185 @@
+package playback
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// There's no usable pure Go decoder for vorbis/opus or aac/alac at the moment
+// so those go through ffmpeg, which is asked to always resample to this format
+var ffmpegFormat = Format{SampleRate: 44100, Channels: 2}
+
+var ErrFFmpegMissing = errors.New("ffmpeg is required to decode this file but was not found in PATH")
+
+type ffmpegDecoder struct {
+	path    string
+	input   io.Reader // fed through stdin instead of path for streams
+	length  time.Duration
+	command *exec.Cmd
+	stdout  io.ReadCloser
+	reader  *bufio.Reader
+	buffer  []byte
+}
+
+func openFFmpeg(path string) (Decoder, error) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return nil, ErrFFmpegMissing
+	}
+
+	decoder := &ffmpegDecoder{path: path}
+
+	// an unknown length only disables seeking bounds, so probing errors aren't fatal
+	if length, ok := containerLengths[strings.ToLower(filepath.Ext(path))]; ok {
+		decoder.length, _ = length(path)
+	}
+
+	if err := decoder.start(0); err != nil {
+		return nil, err
+	}
+
+	return decoder, nil
+}
+
+// openFFmpegStream decodes whatever ffmpeg makes of input, which can't be seeked
+func openFFmpegStream(input io.Reader) (Decoder, error) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return nil, ErrFFmpegMissing
+	}
+
+	decoder := &ffmpegDecoder{path: "pipe:0", input: input}
+
+	if err := decoder.start(0); err != nil {
+		return nil, err
+	}
+
+	return decoder, nil
+}
+
+func (decoder *ffmpegDecoder) start(position time.Duration) error {
+	args := []string{"-v", "error"}
+
+	if decoder.input == nil {
+		args = append(args, "-nostdin")
+	}
+
+	if position > 0 {
+		args = append(args, "-ss", fmt.Sprintf("%.3f", position.Seconds()))
+	}
+
+	args = append(args,
+		"-i", decoder.path,
+		"-vn",
+		"-f", "f32le",
+		"-ac", fmt.Sprint(ffmpegFormat.Channels),
+		"-ar", fmt.Sprint(ffmpegFormat.SampleRate),
+		"pipe:1",
+	)
+
+	command := exec.Command("ffmpeg", args...)
+	command.Stdin = decoder.input
+
+	stdout, err := command.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := command.Start(); err != nil {
+		return err
+	}
+
+	decoder.command = command
+	decoder.stdout = stdout
+	decoder.reader = bufio.NewReaderSize(stdout, 64*1024)
+
+	return nil
+}
+
+func (decoder *ffmpegDecoder) stop() error {
+	if decoder.command == nil {
+		return nil
+	}
+
+	decoder.stdout.Close()
+	_ = decoder.command.Process.Kill()
+	_ = decoder.command.Wait()
+
+	decoder.command = nil
+
+	return nil
+}
+
+func (decoder *ffmpegDecoder) Format() Format {
+	return ffmpegFormat
+}
+
+func (decoder *ffmpegDecoder) Read(samples []float32) (int, error) {
+	if decoder.command == nil {
+		return 0, io.EOF
+	}
+
+	frames := len(samples) / ffmpegFormat.Channels
+	size := frames * ffmpegFormat.Channels * 4
+
+	if cap(decoder.buffer) < size {
+		decoder.buffer = make([]byte, size)
+	}
+
+	buffer := decoder.buffer[:size]
+
+	// block for at least one whole frame, but don't wait for the whole buffer
+	n, err := io.ReadAtLeast(decoder.reader, buffer, ffmpegFormat.Channels*4)
+	if n < size && err == nil {
+		more := min(decoder.reader.Buffered(), size-n)
+		more -= (n + more) % (ffmpegFormat.Channels * 4)
+
+		if more > 0 {
+			read, _ := io.ReadFull(decoder.reader, buffer[n:n+more])
+			n += read
+		}
+	}
+
+	n -= n % (ffmpegFormat.Channels * 4)
+
+	count := n / 4
+	for i := range count {
+		samples[i] = clampSample(math.Float32frombits(binary.LittleEndian.Uint32(buffer[i*4:])))
+	}
+
+	if count > 0 {
+		return count, nil
+	}
+
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+
+	return 0, err
+}
+
+func (decoder *ffmpegDecoder) Seek(position time.Duration) error {
+	if decoder.input != nil {
+		return ErrNotSeekable
+	}
+
+	if err := decoder.stop(); err != nil {
+		return err
+	}
+
+	return decoder.start(position)
+}
+
+func (decoder *ffmpegDecoder) Length() time.Duration {
+	return decoder.length
+}
+
+func (decoder *ffmpegDecoder) Close() error {
+	return decoder.stop()
+}
@@ -0,0 +1,130 @@
+package playback
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"sync"
+)
+
+const (
+	filePerm         = 0o644
+	wavHeaderSize    = 44
+	wavBitsPerSample = 16
+)
+
+// FileOutput writes everything played into a 16-bit PCM wav file, in the format of the
+// first track. a wav has a single format, so tracks after it in another one are resampled
+type FileOutput struct {
+	path      string
+	file      *os.File
+	format    Format
+	resampler *Resampler // nil while the track matches the file
+	size      int64
+	buffer    []byte
+	mutex     sync.Mutex
+}
+
+func NewFileOutput(path string) *FileOutput {
+	return &FileOutput{path: path}
+}
+
+func (output *FileOutput) Open(format Format) error {
+	output.mutex.Lock()
+	defer output.mutex.Unlock()
+
+	if output.file != nil {
+		output.resampler = nil
+
+		if format != output.format {
+			output.resampler = NewResampler(output.format)
+			output.resampler.Reset(format)
+		}
+
+		return nil
+	}
+
+	f, err := os.OpenFile(output.path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, filePerm)
+	if err != nil {
+		return err
+	}
+
+	output.file = f
+	output.format = format
+	output.resampler = nil
+	output.size = 0
+
+	// sizes are patched once the output gets closed
+	return output.writeHeader()
+}
+
+func (output *FileOutput) Write(samples []float32) error {
+	output.mutex.Lock()
+	defer output.mutex.Unlock()
+
+	if output.file == nil {
+		return os.ErrClosed
+	}
+
+	if output.resampler != nil {
+		samples = output.resampler.Process(samples)
+	}
+
+	output.buffer = EncodeInt16(output.buffer, samples)
+
+	n, err := output.file.Write(output.buffer)
+	output.size += int64(n)
+
+	return err
+}
+
+func (output *FileOutput) Close() error {
+	output.mutex.Lock()
+	defer output.mutex.Unlock()
+
+	if output.file == nil {
+		return nil
+	}
+
+	err := output.writeHeader()
+	if closeErr := output.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	output.file = nil
+
+	return err
+}
+
+func (output *FileOutput) writeHeader() error {
+	header := WAVHeader(output.format, uint32(output.size))
+
+	if _, err := output.file.WriteAt(header, 0); err != nil {
+		return err
+	}
+
+	_, err := output.file.Seek(0, io.SeekEnd)
+	return err
+}
+
+// WAVHeader builds a canonical 16-bit PCM header for dataSize bytes of samples
+func WAVHeader(format Format, dataSize uint32) []byte {
+	header := make([]byte, wavHeaderSize)
+	blockAlign := format.Channels * wavBitsPerSample / 8
+
+	copy(header[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:8], 36+dataSize)
+	copy(header[8:12], "WAVE")
+	copy(header[12:16], "fmt ")
+	binary.LittleEndian.PutUint32(header[16:20], 16)
+	binary.LittleEndian.PutUint16(header[20:22], wavFormatPCM)
+	binary.LittleEndian.PutUint16(header[22:24], uint16(format.Channels))
+	binary.LittleEndian.PutUint32(header[24:28], uint32(format.SampleRate))
+	binary.LittleEndian.PutUint32(header[28:32], uint32(format.SampleRate*blockAlign))
+	binary.LittleEndian.PutUint16(header[32:34], uint16(blockAlign))
+	binary.LittleEndian.PutUint16(header[34:36], wavBitsPerSample)
+	copy(header[36:40], "data")
+	binary.LittleEndian.PutUint32(header[40:44], dataSize)
+
+	return header
+}
@@ -0,0 +1,112 @@
+package playback
+
+import (
+	"os"
+	"time"
+
+	flac "github.com/mewkiz/flac"
+)
+
+type flacDecoder struct {
+	file    *os.File
+	stream  *flac.Stream
+	format  Format
+	scale   float32
+	pending []float32 // decoded samples from the current frame not yet read
+	skip    int       // frames to drop after a seek lands on a frame boundary
+}
+
+func openFLAC(path string) (Decoder, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := flac.NewSeek(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &flacDecoder{
+		file:   f,
+		stream: stream,
+		format: Format{
+			SampleRate: int(stream.Info.SampleRate),
+			Channels:   int(stream.Info.NChannels),
+		},
+		scale: float32(int64(1) << (stream.Info.BitsPerSample - 1)),
+	}, nil
+}
+
+func (decoder *flacDecoder) Format() Format {
+	return decoder.format
+}
+
+func (decoder *flacDecoder) Read(samples []float32) (int, error) {
+	written := 0
+
+	for written < len(samples) {
+		if len(decoder.pending) == 0 {
+			if err := decoder.decodeFrame(); err != nil {
+				if written > 0 {
+					return written, nil
+				}
+
+				return 0, err
+			}
+
+			continue
+		}
+
+		n := copy(samples[written:], decoder.pending)
+		decoder.pending = decoder.pending[n:]
+		written += n
+	}
+
+	return written, nil
+}
+
+func (decoder *flacDecoder) decodeFrame() error {
+	frame, err := decoder.stream.ParseNext()
+	if err != nil {
+		return err
+	}
+
+	channels := decoder.format.Channels
+	count := len(frame.Subframes[0].Samples)
+
+	buffer := make([]float32, 0, count*channels)
+	for i := decoder.skip; i < count; i++ {
+		for _, subframe := range frame.Subframes {
+			buffer = append(buffer, clampSample(float32(subframe.Samples[i])/decoder.scale))
+		}
+	}
+
+	decoder.skip = max(decoder.skip-count, 0)
+	decoder.pending = buffer
+
+	return nil
+}
+
+func (decoder *flacDecoder) Seek(position time.Duration) error {
+	target := uint64(decoder.format.durationToFrames(position))
+
+	start, err := decoder.stream.Seek(target)
+	if err != nil {
+		return err
+	}
+
+	decoder.pending = nil
+	decoder.skip = int(target - start)
+
+	return nil
+}
+
+func (decoder *flacDecoder) Length() time.Duration {
+	return decoder.format.framesToDuration(int64(decoder.stream.Info.NSamples))
+}
+
+func (decoder *flacDecoder) Close() error {
+	return decoder.file.Close()
+}
@@ -0,0 +1,106 @@
+package playback
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"time"
+
+	mp3 "github.com/hajimehoshi/go-mp3"
+)
+
+// go-mp3 always decodes to signed 16-bit little endian stereo
+const mp3BytesPerFrame = 4
+
+type mp3Decoder struct {
+	file    io.Closer
+	decoder *mp3.Decoder
+	format  Format
+	buffer  []byte
+}
+
+func openMP3(path string) (Decoder, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder, err := newMP3Decoder(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return decoder, nil
+}
+
+// newMP3Decoder decodes from any reader, length and seeking only work when it's also a seeker
+func newMP3Decoder(r io.ReadCloser) (*mp3Decoder, error) {
+	decoder, err := mp3.NewDecoder(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mp3Decoder{
+		file:    r,
+		decoder: decoder,
+		format:  Format{SampleRate: decoder.SampleRate(), Channels: 2},
+	}, nil
+}
+
+func (decoder *mp3Decoder) Format() Format {
+	return decoder.format
+}
+
+func (decoder *mp3Decoder) Read(samples []float32) (int, error) {
+	// keep reads aligned to whole frames so channels never swap
+	size := (len(samples) / decoder.format.Channels) * mp3BytesPerFrame
+	if size == 0 {
+		return 0, nil
+	}
+
+	if cap(decoder.buffer) < size {
+		decoder.buffer = make([]byte, size)
+	}
+
+	buffer := decoder.buffer[:size]
+
+	n, err := io.ReadFull(decoder.decoder, buffer)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+
+	n -= n % mp3BytesPerFrame
+
+	count := n / 2
+	for i := range count {
+		sample := int16(binary.LittleEndian.Uint16(buffer[i*2:]))
+		samples[i] = float32(sample) / 32768
+	}
+
+	if count > 0 && err == io.EOF {
+		return count, nil
+	}
+
+	return count, err
+}
+
+func (decoder *mp3Decoder) Seek(position time.Duration) error {
+	frames := decoder.format.durationToFrames(position)
+
+	_, err := decoder.decoder.Seek(frames*mp3BytesPerFrame, io.SeekStart)
+	return err
+}
+
+func (decoder *mp3Decoder) Length() time.Duration {
+	length := decoder.decoder.Length()
+	if length <= 0 {
+		return 0
+	}
+
+	return decoder.format.framesToDuration(length / mp3BytesPerFrame)
+}
+
+func (decoder *mp3Decoder) Close() error {
+	return decoder.file.Close()
+}
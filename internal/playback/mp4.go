@@ -0,0 +1,97 @@
+package playback
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+var errInvalidMP4 = errors.New("invalid mp4 file")
+
+// mp4Length reads the movie header (moov/mvhd) which stores the duration and its timescale
+func mp4Length(path string) (time.Duration, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	moovStart, moovSize, err := findAtom(f, 0, info.Size(), "moov")
+	if err != nil {
+		return 0, err
+	}
+
+	mvhdStart, mvhdSize, err := findAtom(f, moovStart, moovStart+moovSize, "mvhd")
+	if err != nil {
+		return 0, err
+	}
+
+	data := make([]byte, min(mvhdSize, 32))
+	if _, err := f.ReadAt(data, mvhdStart); err != nil {
+		return 0, err
+	}
+
+	var timescale, duration uint64
+
+	switch {
+	case len(data) >= 20 && data[0] == 0:
+		timescale = uint64(binary.BigEndian.Uint32(data[12:16]))
+		duration = uint64(binary.BigEndian.Uint32(data[16:20]))
+	case len(data) >= 32 && data[0] == 1:
+		timescale = uint64(binary.BigEndian.Uint32(data[20:24]))
+		duration = binary.BigEndian.Uint64(data[24:32])
+	default:
+		return 0, errInvalidMP4
+	}
+
+	if timescale == 0 {
+		return 0, errInvalidMP4
+	}
+
+	return time.Duration(duration) * time.Second / time.Duration(timescale), nil
+}
+
+// findAtom returns the payload offset and size of the first atom with the given name in [start, end)
+func findAtom(r io.ReaderAt, start int64, end int64, name string) (int64, int64, error) {
+	header := make([]byte, 16)
+
+	for offset := start; offset+8 <= end; {
+		if _, err := r.ReadAt(header[:8], offset); err != nil {
+			return 0, 0, err
+		}
+
+		size := int64(binary.BigEndian.Uint32(header[0:4]))
+		headerSize := int64(8)
+
+		switch size {
+		case 0:
+			size = end - offset
+		case 1:
+			if _, err := r.ReadAt(header[8:16], offset+8); err != nil {
+				return 0, 0, err
+			}
+
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		}
+
+		if size < headerSize {
+			return 0, 0, errInvalidMP4
+		}
+
+		if string(header[4:8]) == name {
+			return offset + headerSize, size - headerSize, nil
+		}
+
+		offset += size
+	}
+
+	return 0, 0, errInvalidMP4
+}
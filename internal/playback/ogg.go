@@ -0,0 +1,87 @@
+package playback
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	oggPageHeaderSize = 27
+	oggTailSize       = 64 * 1024
+	opusGranuleRate   = 48000
+)
+
+var errInvalidOgg = errors.New("invalid ogg file")
+
+// oggLength reads the identification header for the sample rate and the granule
+// position of the last page, which holds the total amount of samples
+func oggLength(path string) (time.Duration, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return 0, err
+	}
+
+	head = head[:n]
+	if len(head) < oggPageHeaderSize || string(head[0:4]) != "OggS" {
+		return 0, errInvalidOgg
+	}
+
+	packetStart := oggPageHeaderSize + int(head[26])
+	if packetStart >= len(head) {
+		return 0, errInvalidOgg
+	}
+
+	packet := head[packetStart:]
+
+	var (
+		rate    int64
+		preSkip int64
+	)
+
+	switch {
+	case len(packet) >= 16 && string(packet[0:7]) == "\x01vorbis":
+		rate = int64(binary.LittleEndian.Uint32(packet[12:16]))
+	case len(packet) >= 12 && string(packet[0:8]) == "OpusHead":
+		rate = opusGranuleRate
+		preSkip = int64(binary.LittleEndian.Uint16(packet[10:12]))
+	default:
+		return 0, ErrUnsupportedFormat
+	}
+
+	if rate <= 0 {
+		return 0, errInvalidOgg
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	tailStart := max(info.Size()-oggTailSize, 0)
+	tail := make([]byte, info.Size()-tailStart)
+
+	if _, err := f.ReadAt(tail, tailStart); err != nil && err != io.EOF {
+		return 0, err
+	}
+
+	last := bytes.LastIndex(tail, []byte("OggS"))
+	if last < 0 || last+14 > len(tail) {
+		return 0, errInvalidOgg
+	}
+
+	granule := int64(binary.LittleEndian.Uint64(tail[last+6 : last+14]))
+	samples := max(granule-preSkip, 0)
+
+	return time.Duration(samples) * time.Second / time.Duration(rate), nil
+}
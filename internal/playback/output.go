@@ -0,0 +1,59 @@
+package playback
+
+import (
+	"encoding/binary"
+	"math"
+	"sync/atomic"
+)
+
+// Output is where decoded samples end up, Open is called again whenever the format changes
+type Output interface {
+	Open(format Format) error
+	// Write is expected to block while the sink is busy, which paces the player
+	Write(samples []float32) error
+	Close() error
+}
+
+// NullOutput discards everything as fast as it's written, useful for machines without a sound card
+type NullOutput struct {
+	written atomic.Int64
+}
+
+func NewNullOutput() *NullOutput {
+	return &NullOutput{}
+}
+
+func (output *NullOutput) Open(format Format) error {
+	return nil
+}
+
+func (output *NullOutput) Write(samples []float32) error {
+	output.written.Add(int64(len(samples)))
+	return nil
+}
+
+func (output *NullOutput) Close() error {
+	return nil
+}
+
+// Written returns the total amount of samples that were discarded
+func (output *NullOutput) Written() int64 {
+	return output.written.Load()
+}
+
+// EncodeInt16 converts samples to signed 16-bit little endian, reusing dst when it is big enough
+func EncodeInt16(dst []byte, samples []float32) []byte {
+	size := len(samples) * 2
+	if cap(dst) < size {
+		dst = make([]byte, size)
+	}
+
+	dst = dst[:size]
+
+	for i, sample := range samples {
+		value := int16(math.Round(float64(clampSample(sample)) * 32767))
+		binary.LittleEndian.PutUint16(dst[i*2:], uint16(value))
+	}
+
+	return dst
+}
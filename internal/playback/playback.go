@@ -0,0 +1,31 @@
+// Package playback decodes audio files and pushes their samples to a pluggable output
+package playback
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported audio format")
+	ErrNotSeekable       = errors.New("stream is not seekable")
+	ErrNothingLoaded     = errors.New("nothing is loaded")
+)
+
+// Format describes interleaved float32 PCM samples in the [-1, 1] range
+type Format struct {
+	SampleRate int
+	Channels   int
+}
+
+func (format Format) framesToDuration(frames int64) time.Duration {
+	if format.SampleRate <= 0 {
+		return 0
+	}
+
+	return time.Duration(frames) * time.Second / time.Duration(format.SampleRate)
+}
+
+func (format Format) durationToFrames(duration time.Duration) int64 {
+	return int64(duration) * int64(format.SampleRate) / int64(time.Second)
+}
@@ -0,0 +1,382 @@
+package playback
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// ~46ms at 44.1kHz, small enough for pausing and seeking to feel instant
+const bufferFrames = 2048
+
+type State int
+
+const (
+	Stopped State = iota
+	Playing
+	Paused
+)
+
+type EventType int
+
+const (
+	TrackFinished EventType = iota
+	TrackFailed
+	// streams learn what they're playing as they go
+	TitleChanged
+)
+
+type Event struct {
+	Type  EventType
+	Path  string
+	Title string
+	Error error
+}
+
+type Player struct {
+	output     Output
+	decoder    Decoder
+	path       string
+	length     time.Duration
+	state      State
+	frames     int64 // frames handed to the output since the last seek, plus the seek target
+	generation int   // bumped whenever the current track is replaced or stopped
+	seeks      int
+	volume     float32
+	events     chan Event
+	// closed by the goroutine playing the current track once it's gone
+	done  chan struct{}
+	mutex sync.Mutex
+	cond  *sync.Cond
+	// held through Play and Stop, so there's only ever one goroutine playing
+	switching sync.Mutex
+	// held around every call into the decoder, which the playing goroutine reads outside of mutex
+	decoding sync.Mutex
+}
+
+func NewPlayer(output Output) *Player {
+	player := &Player{
+		output: output,
+		volume: 1,
+		events: make(chan Event, 16),
+	}
+
+	player.cond = sync.NewCond(&player.mutex)
+
+	return player
+}
+
+// Events reports tracks reaching their end or failing mid-playback
+// if nobody drains the channel, new events are dropped
+func (player *Player) Events() <-chan Event {
+	return player.events
+}
+
+func (player *Player) Play(path string) error {
+	decoder, err := Open(path)
+	if err != nil {
+		return err
+	}
+
+	return player.PlayDecoder(path, decoder)
+}
+
+// PlayDecoder plays a decoder opened with Open, for callers who'd rather not wait on a stream
+// connecting while holding locks of their own. The player owns the decoder from then on
+func (player *Player) PlayDecoder(path string, decoder Decoder) error {
+	player.switching.Lock()
+	defer player.switching.Unlock()
+
+	// the output can't be reopened while the previous track is still writing to it
+	player.stop()
+
+	if err := player.output.Open(decoder.Format()); err != nil {
+		decoder.Close()
+		return err
+	}
+
+	player.mutex.Lock()
+	defer player.mutex.Unlock()
+
+	player.decoder = decoder
+	player.path = path
+	player.length = decoder.Length()
+	player.state = Playing
+	player.frames = 0
+
+	if titled, ok := decoder.(titledDecoder); ok {
+		generation := player.generation
+		titled.OnTitle(func(title string) {
+			player.emitTitle(generation, path, title)
+		})
+	}
+
+	player.done = make(chan struct{})
+	go player.run(player.generation, decoder, player.done)
+
+	return nil
+}
+
+func (player *Player) Pause() {
+	player.mutex.Lock()
+	defer player.mutex.Unlock()
+
+	if player.state == Playing {
+		player.state = Paused
+	}
+}
+
+func (player *Player) Resume() {
+	player.mutex.Lock()
+	defer player.mutex.Unlock()
+
+	if player.state == Paused {
+		player.state = Playing
+		player.cond.Broadcast()
+	}
+}
+
+func (player *Player) TogglePause() {
+	player.mutex.Lock()
+	defer player.mutex.Unlock()
+
+	switch player.state {
+	case Playing:
+		player.state = Paused
+	case Paused:
+		player.state = Playing
+		player.cond.Broadcast()
+	}
+}
+
+func (player *Player) Stop() {
+	player.switching.Lock()
+	defer player.switching.Unlock()
+
+	player.stop()
+}
+
+func (player *Player) Seek(position time.Duration) error {
+	player.mutex.Lock()
+	decoder := player.decoder
+	generation := player.generation
+	length := player.length
+	player.mutex.Unlock()
+
+	if decoder == nil {
+		return ErrNothingLoaded
+	}
+
+	position = max(position, 0)
+	if length > 0 {
+		position = min(position, length)
+	}
+
+	player.decoding.Lock()
+	defer player.decoding.Unlock()
+
+	// the track may have ended while waiting, and its decoder with it
+	player.mutex.Lock()
+	current := player.generation == generation
+	player.mutex.Unlock()
+
+	if !current {
+		return ErrNothingLoaded
+	}
+
+	if err := decoder.Seek(position); err != nil {
+		return err
+	}
+
+	player.mutex.Lock()
+	player.frames = decoder.Format().durationToFrames(position)
+	player.seeks++
+	player.mutex.Unlock()
+
+	return nil
+}
+
+// SetVolume scales every sample from silent at 0 to untouched at 1
+func (player *Player) SetVolume(volume float32) {
+	player.mutex.Lock()
+	defer player.mutex.Unlock()
+
+	player.volume = max(0, min(1, volume))
+}
+
+func (player *Player) Volume() float32 {
+	player.mutex.Lock()
+	defer player.mutex.Unlock()
+
+	return player.volume
+}
+
+func (player *Player) State() State {
+	player.mutex.Lock()
+	defer player.mutex.Unlock()
+
+	return player.state
+}
+
+// Path returns the file currently loaded, empty when stopped
+func (player *Player) Path() string {
+	player.mutex.Lock()
+	defer player.mutex.Unlock()
+
+	return player.path
+}
+
+func (player *Player) Position() time.Duration {
+	player.mutex.Lock()
+	defer player.mutex.Unlock()
+
+	if player.decoder == nil {
+		return 0
+	}
+
+	return player.decoder.Format().framesToDuration(player.frames)
+}
+
+// Duration returns zero when the length of the track is unknown
+func (player *Player) Duration() time.Duration {
+	player.mutex.Lock()
+	defer player.mutex.Unlock()
+
+	return player.length
+}
+
+// Close stops playback and releases the output
+func (player *Player) Close() error {
+	player.Stop()
+	return player.output.Close()
+}
+
+// stop ends the current track and waits for its goroutine to be gone, callers hold switching
+func (player *Player) stop() {
+	player.mutex.Lock()
+	player.stopLocked()
+	done := player.done
+	player.mutex.Unlock()
+
+	if done != nil {
+		<-done
+	}
+}
+
+// stopLocked tells the playing goroutine to go, which closes the decoder on its way out
+func (player *Player) stopLocked() {
+	player.generation++
+	player.cond.Broadcast()
+
+	player.decoder = nil
+	player.path = ""
+	player.length = 0
+	player.state = Stopped
+	player.frames = 0
+}
+
+func (player *Player) run(generation int, decoder Decoder, done chan struct{}) {
+	defer close(done)
+
+	defer func() {
+		player.decoding.Lock()
+		decoder.Close()
+		player.decoding.Unlock()
+	}()
+
+	channels := decoder.Format().Channels
+	buffer := make([]float32, bufferFrames*channels)
+
+	for {
+		player.mutex.Lock()
+
+		for player.state == Paused && player.generation == generation {
+			player.cond.Wait()
+		}
+
+		if player.generation != generation {
+			player.mutex.Unlock()
+			return
+		}
+
+		volume := player.volume
+		player.mutex.Unlock()
+
+		// read outside of mutex so nobody asking about the state waits on the disk or network
+		player.decoding.Lock()
+		n, err := decoder.Read(buffer)
+
+		player.mutex.Lock()
+		seeks := player.seeks
+		player.mutex.Unlock()
+
+		player.decoding.Unlock()
+
+		if volume != 1 {
+			for i := range buffer[:n] {
+				buffer[i] *= volume
+			}
+		}
+
+		if n > 0 {
+			if writeErr := player.output.Write(buffer[:n]); writeErr != nil {
+				player.finish(generation, writeErr)
+				return
+			}
+
+			player.mutex.Lock()
+			if player.generation == generation && player.seeks == seeks {
+				player.frames += int64(n / channels)
+			}
+			player.mutex.Unlock()
+		}
+
+		if err == io.EOF {
+			player.finish(generation, nil)
+			return
+		}
+
+		if err != nil {
+			player.finish(generation, err)
+			return
+		}
+	}
+}
+
+func (player *Player) emitTitle(generation int, path string, title string) {
+	player.mutex.Lock()
+	current := player.generation == generation
+	player.mutex.Unlock()
+
+	if !current {
+		return
+	}
+
+	select {
+	case player.events <- Event{Type: TitleChanged, Path: path, Title: title}:
+	default:
+	}
+}
+
+func (player *Player) finish(generation int, err error) {
+	player.mutex.Lock()
+
+	// the track was replaced or stopped while we were busy, nothing to report
+	if player.generation != generation {
+		player.mutex.Unlock()
+		return
+	}
+
+	event := Event{Type: TrackFinished, Path: player.path}
+	if err != nil {
+		event = Event{Type: TrackFailed, Path: player.path, Error: err}
+	}
+
+	player.stopLocked()
+	player.mutex.Unlock()
+
+	select {
+	case player.events <- event:
+	default:
+	}
+}
@@ -0,0 +1,234 @@
+package playback
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// pacedOutput discards samples like NullOutput but takes its time, the way a sound card would
+type pacedOutput struct {
+	*NullOutput
+	delay time.Duration
+}
+
+func (output pacedOutput) Write(samples []float32) error {
+	time.Sleep(output.delay)
+	return output.NullOutput.Write(samples)
+}
+
+func waitForEvent(t *testing.T, player *Player) Event {
+	t.Helper()
+
+	select {
+	case event := <-player.Events():
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event from the player")
+		return Event{}
+	}
+}
+
+// eventually polls condition, the player is driven by a goroutine of its own
+func eventually(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPlayerPlaysToTheEnd(t *testing.T) {
+	samples := ramp(10000, testFormat.Channels)
+	path := writeWAV(t, testFormat, samples)
+
+	output := NewNullOutput()
+	player := NewPlayer(output)
+	defer player.Close()
+
+	if err := player.Play(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if event := waitForEvent(t, player); event.Type != TrackFinished || event.Path != path {
+		t.Fatalf("got %+v, want the track to finish", event)
+	}
+
+	if output.Written() != int64(len(samples)) {
+		t.Fatalf("%d samples were written, want %d", output.Written(), len(samples))
+	}
+
+	if player.State() != Stopped || player.Path() != "" || player.Position() != 0 {
+		t.Fatalf("player is still on %q in state %d", player.Path(), player.State())
+	}
+}
+
+func TestPlayerPauseAndResume(t *testing.T) {
+	path := writeWAV(t, testFormat, ramp(testFormat.SampleRate*60, testFormat.Channels))
+
+	output := pacedOutput{NewNullOutput(), time.Millisecond}
+	player := NewPlayer(output)
+	defer player.Close()
+
+	if err := player.Play(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if player.Duration() != time.Minute {
+		t.Fatalf("duration is %s, want a minute", player.Duration())
+	}
+
+	eventually(t, func() bool { return output.Written() > 0 })
+
+	player.Pause()
+
+	if player.State() != Paused {
+		t.Fatalf("state is %d after pausing", player.State())
+	}
+
+	// a write already under way when pausing still finishes
+	time.Sleep(20 * time.Millisecond)
+	written, position := output.Written(), player.Position()
+	time.Sleep(20 * time.Millisecond)
+
+	if output.Written() != written || player.Position() != position {
+		t.Fatal("kept playing while paused")
+	}
+
+	player.TogglePause()
+
+	if player.State() != Playing {
+		t.Fatalf("state is %d after resuming", player.State())
+	}
+
+	eventually(t, func() bool { return output.Written() > written && player.Position() > position })
+}
+
+func TestPlayerSeek(t *testing.T) {
+	path := writeWAV(t, testFormat, ramp(testFormat.SampleRate*60, testFormat.Channels))
+
+	player := NewPlayer(pacedOutput{NewNullOutput(), time.Millisecond})
+	defer player.Close()
+
+	if err := player.Seek(time.Second); !errors.Is(err, ErrNothingLoaded) {
+		t.Fatalf("seeking with nothing loaded gave %v", err)
+	}
+
+	if err := player.Play(path); err != nil {
+		t.Fatal(err)
+	}
+
+	player.Pause()
+
+	if err := player.Seek(30 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	// the block read before the seek may still land after it, but it isn't counted
+	time.Sleep(20 * time.Millisecond)
+	if position := player.Position(); position != 30*time.Second {
+		t.Fatalf("position is %s after seeking to 30s", position)
+	}
+
+	player.Resume()
+	eventually(t, func() bool { return player.Position() > 30*time.Second })
+
+	if err := player.Seek(-time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if position := player.Position(); position >= 30*time.Second {
+		t.Fatalf("position is %s after seeking back to the start", position)
+	}
+
+	// past the end is the end, and the track finishes from there
+	if err := player.Seek(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if event := waitForEvent(t, player); event.Type != TrackFinished {
+		t.Fatalf("got %+v after seeking past the end", event)
+	}
+}
+
+func TestPlayerStop(t *testing.T) {
+	path := writeWAV(t, testFormat, ramp(testFormat.SampleRate*60, testFormat.Channels))
+
+	output := pacedOutput{NewNullOutput(), time.Millisecond}
+	player := NewPlayer(output)
+	defer player.Close()
+
+	if err := player.Play(path); err != nil {
+		t.Fatal(err)
+	}
+
+	eventually(t, func() bool { return output.Written() > 0 })
+
+	player.Stop()
+
+	// the goroutine is gone by the time Stop returns
+	written := output.Written()
+	time.Sleep(20 * time.Millisecond)
+
+	if output.Written() != written {
+		t.Fatal("kept playing after stopping")
+	}
+
+	if player.State() != Stopped || player.Path() != "" || player.Position() != 0 || player.Duration() != 0 {
+		t.Fatalf("player is still on %q in state %d", player.Path(), player.State())
+	}
+
+	select {
+	case event := <-player.Events():
+		t.Fatalf("stopping reported %+v", event)
+	default:
+	}
+}
+
+func TestPlayerReplacesTrack(t *testing.T) {
+	first := writeWAV(t, testFormat, ramp(testFormat.SampleRate*60, testFormat.Channels))
+	second := writeWAV(t, testFormat, ramp(testFormat.SampleRate*30, testFormat.Channels))
+
+	player := NewPlayer(pacedOutput{NewNullOutput(), time.Millisecond})
+	defer player.Close()
+
+	for range 10 {
+		if err := player.Play(first); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := player.Play(second); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if player.Path() != second || player.Duration() != 30*time.Second {
+		t.Fatalf("playing %q for %s", player.Path(), player.Duration())
+	}
+
+	// replaced tracks don't report finishing
+	select {
+	case event := <-player.Events():
+		t.Fatalf("replacing a track reported %+v", event)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestPlayerVolume(t *testing.T) {
+	player := NewPlayer(NewNullOutput())
+
+	player.SetVolume(2)
+	if player.Volume() != 1 {
+		t.Fatalf("volume is %f, want it capped at 1", player.Volume())
+	}
+
+	player.SetVolume(-1)
+	if player.Volume() != 0 {
+		t.Fatalf("volume is %f, want it at least 0", player.Volume())
+	}
+}
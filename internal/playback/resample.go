@@ -0,0 +1,82 @@
+package playback
+
+import "math"
+
+// Resampler linearly interpolates samples of any format into another, channels missing from
+// the input repeat its last one and extra ones are dropped. it's plenty for a radio or a recording
+type Resampler struct {
+	from Format
+	to   Format
+	// read position into the samples being processed, -1 being the last frame of the previous batch
+	position float64
+	previous []float32
+	// the two frames being interpolated between
+	from1  []float32
+	from2  []float32
+	buffer []float32
+}
+
+func NewResampler(to Format) *Resampler {
+	return &Resampler{
+		to:       to,
+		previous: make([]float32, to.Channels),
+		from1:    make([]float32, to.Channels),
+		from2:    make([]float32, to.Channels),
+	}
+}
+
+// Reset starts over with samples in the from format, e.g. when the next track begins
+func (resampler *Resampler) Reset(from Format) {
+	resampler.from = from
+	resampler.position = 0
+	clear(resampler.previous)
+}
+
+// Process returns the samples converted to the target format, the slice is reused by the next call
+func (resampler *Resampler) Process(samples []float32) []float32 {
+	from, to := resampler.from, resampler.to
+	if from.SampleRate <= 0 || from.Channels <= 0 {
+		return nil
+	}
+
+	frames := len(samples) / from.Channels
+	if frames == 0 {
+		return nil
+	}
+
+	frame := func(dst []float32, index int) []float32 {
+		if index < 0 {
+			copy(dst, resampler.previous)
+			return dst
+		}
+
+		for channel := range dst {
+			dst[channel] = samples[index*from.Channels+min(channel, from.Channels-1)]
+		}
+
+		return dst
+	}
+
+	step := float64(from.SampleRate) / float64(to.SampleRate)
+	output := resampler.buffer[:0]
+
+	for resampler.position < float64(frames-1) {
+		index := int(math.Floor(resampler.position))
+		weight := float32(resampler.position - float64(index))
+
+		before := frame(resampler.from1, index)
+		after := frame(resampler.from2, index+1)
+
+		for channel := range before {
+			output = append(output, before[channel]+(after[channel]-before[channel])*weight)
+		}
+
+		resampler.position += step
+	}
+
+	frame(resampler.previous, frames-1)
+	resampler.position -= float64(frames)
+	resampler.buffer = output
+
+	return output
+}
@@ -0,0 +1,416 @@
+package playback
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	streamConnectTimeout = 10 * time.Second
+	// how much decoded audio is kept ahead of the player
+	streamBufferDuration = 2 * time.Second
+	// how long a read waits for the network before the player is handed silence
+	streamReadWait = 50 * time.Millisecond
+	// reconnecting backs off from the first delay, doubling up to the last one, then gives up
+	streamRetryDelay    = time.Second
+	streamMaxRetryDelay = 30 * time.Second
+	streamRetries       = 8
+)
+
+var (
+	ErrStreamFormatChanged = errors.New("the stream came back in a different format")
+	ErrStreamGaveUp        = errors.New("the stream dropped and couldn't be reconnected")
+)
+
+// IsStream tells remote streams apart from local files
+func IsStream(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// titledDecoder is a decoder that learns what's playing as it goes, like an internet radio
+type titledDecoder interface {
+	OnTitle(callback func(title string))
+}
+
+// streamDecoder plays a remote http stream, reconnecting with backoff when it drops.
+// the network is read in the background so the player never waits on it for long,
+// if nothing arrived in time it gets silence instead
+type streamDecoder struct {
+	url     string
+	client  *http.Client
+	format  Format
+	source  Decoder
+	body    io.Closer
+	pending []float32
+	title   string
+	onTitle func(title string)
+	err     error
+	closed  bool
+	mutex   sync.Mutex
+	cond    *sync.Cond
+}
+
+func openStream(url string) (Decoder, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// only the headers have a deadline, the body is read for as long as the stream lasts
+	transport.ResponseHeaderTimeout = streamConnectTimeout
+
+	decoder := &streamDecoder{
+		url:    url,
+		client: &http.Client{Transport: transport},
+	}
+
+	decoder.cond = sync.NewCond(&decoder.mutex)
+
+	source, body, err := decoder.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	decoder.source = source
+	decoder.body = body
+	decoder.format = source.Format()
+
+	go decoder.run(source)
+
+	return decoder, nil
+}
+
+// connect requests the stream with icy metadata and picks a decoder from its content type
+func (decoder *streamDecoder) connect() (Decoder, io.Closer, error) {
+	request, err := http.NewRequest(http.MethodGet, decoder.url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	request.Header.Set("Icy-MetaData", "1")
+	request.Header.Set("User-Agent", "wired")
+
+	response, err := decoder.client.Do(request)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		return nil, nil, fmt.Errorf("stream returned %s", response.Status)
+	}
+
+	var reader io.Reader = response.Body
+	if interval, err := strconv.Atoi(response.Header.Get("icy-metaint")); err == nil && interval > 0 {
+		reader = &icyReader{reader: response.Body, interval: interval, remaining: interval, onTitle: decoder.setTitle}
+	}
+
+	contentType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type"))
+
+	var source Decoder
+
+	switch contentType {
+	case "audio/mpeg", "audio/mp3", "audio/mpeg3":
+		source, err = newMP3Decoder(io.NopCloser(reader))
+	case "audio/wav", "audio/wave", "audio/x-wav", "audio/vnd.wave":
+		source, err = newWAVStreamDecoder(reader)
+	default:
+		source, err = openFFmpegStream(reader)
+	}
+
+	if err != nil {
+		response.Body.Close()
+		return nil, nil, err
+	}
+
+	return source, response.Body, nil
+}
+
+// run owns source, the fields are only there so Close can interrupt it
+func (decoder *streamDecoder) run(source Decoder) {
+	buffer := make([]float32, bufferFrames*decoder.format.Channels)
+	limit := int(decoder.format.durationToFrames(streamBufferDuration)) * decoder.format.Channels
+	retries := 0
+
+	for {
+		n, err := source.Read(buffer)
+
+		decoder.mutex.Lock()
+
+		if n > 0 {
+			retries = 0
+			decoder.pending = append(decoder.pending, buffer[:n]...)
+			decoder.cond.Broadcast()
+
+			for len(decoder.pending) >= limit && !decoder.closed {
+				decoder.cond.Wait()
+			}
+		}
+
+		closed := decoder.closed
+		decoder.mutex.Unlock()
+
+		if closed {
+			return
+		}
+
+		if err == nil {
+			continue
+		}
+
+		// the stream dropped, wait a bit longer every time before trying again
+		decoder.closeSource()
+
+		for {
+			if retries == streamRetries {
+				decoder.fail(ErrStreamGaveUp)
+				return
+			}
+
+			if !decoder.sleep(min(streamRetryDelay<<retries, streamMaxRetryDelay)) {
+				return
+			}
+
+			retries++
+
+			reconnected, body, err := decoder.connect()
+			if err != nil {
+				continue
+			}
+
+			if reconnected.Format() != decoder.format {
+				reconnected.Close()
+				body.Close()
+				decoder.fail(ErrStreamFormatChanged)
+
+				return
+			}
+
+			source = reconnected
+
+			decoder.mutex.Lock()
+			decoder.source = source
+			decoder.body = body
+			closed := decoder.closed
+			decoder.mutex.Unlock()
+
+			if closed {
+				decoder.closeSource()
+				return
+			}
+
+			break
+		}
+	}
+}
+
+// sleep waits for delay, returning false when the decoder got closed in the meantime
+func (decoder *streamDecoder) sleep(delay time.Duration) bool {
+	deadline := time.Now().Add(delay)
+	timer := time.AfterFunc(delay, func() {
+		decoder.mutex.Lock()
+		decoder.cond.Broadcast()
+		decoder.mutex.Unlock()
+	})
+	defer timer.Stop()
+
+	decoder.mutex.Lock()
+	defer decoder.mutex.Unlock()
+
+	for !decoder.closed && time.Now().Before(deadline) {
+		decoder.cond.Wait()
+	}
+
+	return !decoder.closed
+}
+
+func (decoder *streamDecoder) fail(err error) {
+	decoder.mutex.Lock()
+	decoder.err = err
+	decoder.cond.Broadcast()
+	decoder.mutex.Unlock()
+}
+
+func (decoder *streamDecoder) closeSource() {
+	decoder.mutex.Lock()
+	source := decoder.source
+	body := decoder.body
+	decoder.source = nil
+	decoder.body = nil
+	decoder.mutex.Unlock()
+
+	if body != nil {
+		body.Close()
+	}
+
+	if source != nil {
+		source.Close()
+	}
+}
+
+func (decoder *streamDecoder) setTitle(title string) {
+	decoder.mutex.Lock()
+
+	// servers repeat the title in every block, only changes are worth reporting
+	if title == decoder.title {
+		decoder.mutex.Unlock()
+		return
+	}
+
+	decoder.title = title
+	callback := decoder.onTitle
+	decoder.mutex.Unlock()
+
+	if callback != nil {
+		callback(title)
+	}
+}
+
+// OnTitle registers who hears about title changes, the current title is replayed from
+// another goroutine since whoever registers may be holding locks the callback needs
+func (decoder *streamDecoder) OnTitle(callback func(title string)) {
+	decoder.mutex.Lock()
+	decoder.onTitle = callback
+	title := decoder.title
+	decoder.mutex.Unlock()
+
+	if title != "" {
+		go callback(title)
+	}
+}
+
+func (decoder *streamDecoder) Format() Format {
+	return decoder.format
+}
+
+func (decoder *streamDecoder) Read(samples []float32) (int, error) {
+	decoder.mutex.Lock()
+	defer decoder.mutex.Unlock()
+
+	if len(decoder.pending) == 0 && decoder.err == nil && !decoder.closed {
+		timer := time.AfterFunc(streamReadWait, func() {
+			decoder.mutex.Lock()
+			decoder.cond.Broadcast()
+			decoder.mutex.Unlock()
+		})
+
+		deadline := time.Now().Add(streamReadWait)
+		for len(decoder.pending) == 0 && decoder.err == nil && !decoder.closed && time.Now().Before(deadline) {
+			decoder.cond.Wait()
+		}
+
+		timer.Stop()
+	}
+
+	if len(decoder.pending) == 0 {
+		if decoder.err != nil {
+			return 0, decoder.err
+		}
+
+		if decoder.closed {
+			return 0, io.EOF
+		}
+
+		// buffering or reconnecting, silence keeps the output going
+		count := len(samples) - len(samples)%decoder.format.Channels
+		clear(samples[:count])
+
+		return count, nil
+	}
+
+	count := copy(samples, decoder.pending)
+	count -= count % decoder.format.Channels
+	decoder.pending = decoder.pending[:copy(decoder.pending, decoder.pending[count:])]
+	decoder.cond.Broadcast()
+
+	return count, nil
+}
+
+func (decoder *streamDecoder) Seek(position time.Duration) error {
+	return ErrNotSeekable
+}
+
+func (decoder *streamDecoder) Length() time.Duration {
+	return 0
+}
+
+func (decoder *streamDecoder) Close() error {
+	decoder.mutex.Lock()
+	decoder.closed = true
+	decoder.cond.Broadcast()
+	decoder.mutex.Unlock()
+
+	decoder.closeSource()
+
+	return nil
+}
+
+// icyReader strips shoutcast style metadata blocks out of a stream, reporting the titles they carry
+type icyReader struct {
+	reader    io.Reader
+	interval  int
+	remaining int // audio bytes until the next metadata block
+	onTitle   func(title string)
+}
+
+func (icy *icyReader) Read(p []byte) (int, error) {
+	if icy.remaining == 0 {
+		if err := icy.readMetadata(); err != nil {
+			return 0, err
+		}
+
+		icy.remaining = icy.interval
+	}
+
+	n, err := icy.reader.Read(p[:min(len(p), icy.remaining)])
+	icy.remaining -= n
+
+	return n, err
+}
+
+func (icy *icyReader) readMetadata() error {
+	var length [1]byte
+	if _, err := io.ReadFull(icy.reader, length[:]); err != nil {
+		return err
+	}
+
+	if length[0] == 0 {
+		return nil
+	}
+
+	block := make([]byte, int(length[0])*16)
+	if _, err := io.ReadFull(icy.reader, block); err != nil {
+		return err
+	}
+
+	if title, ok := parseStreamTitle(string(bytes.TrimRight(block, "\x00"))); ok {
+		icy.onTitle(title)
+	}
+
+	return nil
+}
+
+// parseStreamTitle finds StreamTitle='...'; among the metadata, titles aren't escaped so
+// the value ends at the first quote followed by a semicolon
+func parseStreamTitle(metadata string) (string, bool) {
+	const key = "StreamTitle='"
+
+	start := strings.Index(metadata, key)
+	if start == -1 {
+		return "", false
+	}
+
+	value := metadata[start+len(key):]
+
+	if end := strings.Index(value, "';"); end != -1 {
+		value = value[:end]
+	} else {
+		value = strings.TrimSuffix(value, "'")
+	}
+
+	return strings.TrimSpace(value), true
+}
@@ -0,0 +1,326 @@
+package playback
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// icyBlock is a metadata block the way shoutcast sends it, a length in 16 byte units then the padded text
+func icyBlock(metadata string) []byte {
+	length := (len(metadata) + 15) / 16
+	block := make([]byte, 1+length*16)
+	block[0] = byte(length)
+	copy(block[1:], metadata)
+
+	return block
+}
+
+// icyInterleave puts a metadata block after every interval bytes of audio, the last chunk may be shorter
+func icyInterleave(audio []byte, interval int, blocks ...[]byte) []byte {
+	var stream []byte
+
+	for i, block := range blocks {
+		chunk := audio[min(i*interval, len(audio)):min((i+1)*interval, len(audio))]
+		stream = append(append(stream, chunk...), block...)
+	}
+
+	return append(stream, audio[min(len(blocks)*interval, len(audio)):]...)
+}
+
+func TestParseStreamTitle(t *testing.T) {
+	tests := []struct {
+		metadata string
+		title    string
+		ok       bool
+	}{
+		{"StreamTitle='Artist - Title';", "Artist - Title", true},
+		{"StreamTitle='Artist - Title';StreamUrl='http://example.com';", "Artist - Title", true},
+		{"StreamUrl='';StreamTitle=' padded ';", "padded", true},
+		// quotes inside the title aren't escaped
+		{"StreamTitle='Guns N' Roses - Patience';", "Guns N' Roses - Patience", true},
+		{"StreamTitle='cut short'", "cut short", true},
+		{"StreamTitle='';", "", true},
+		{"StreamUrl='http://example.com';", "", false},
+		{"", "", false},
+	}
+
+	for _, test := range tests {
+		title, ok := parseStreamTitle(test.metadata)
+		if title != test.title || ok != test.ok {
+			t.Errorf("%q gave %q, %t, want %q, %t", test.metadata, title, ok, test.title, test.ok)
+		}
+	}
+}
+
+func TestIcyReader(t *testing.T) {
+	audio := []byte("abcdefghijklmnopqrstuvwxyz0123456789AB")
+	stream := icyInterleave(audio, 8,
+		icyBlock("StreamTitle='one';"),
+		// an empty block means nothing changed
+		[]byte{0},
+		icyBlock("StreamUrl='http://example.com';"),
+		icyBlock("StreamTitle='two';StreamUrl='';"),
+	)
+
+	// however the reads get split up, metadata never ends up in the audio
+	readers := map[string]func(io.Reader) io.Reader{
+		"whole":    func(reader io.Reader) io.Reader { return reader },
+		"one byte": iotest.OneByteReader,
+		"half":     iotest.HalfReader,
+	}
+
+	for name, wrap := range readers {
+		t.Run(name, func(t *testing.T) {
+			var titles []string
+
+			icy := &icyReader{
+				reader:    wrap(bytes.NewReader(stream)),
+				interval:  8,
+				remaining: 8,
+				onTitle:   func(title string) { titles = append(titles, title) },
+			}
+
+			read, err := io.ReadAll(icy)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(read, audio) {
+				t.Fatalf("read %q, want %q", read, audio)
+			}
+
+			if !slices.Equal(titles, []string{"one", "two"}) {
+				t.Fatalf("got titles %q", titles)
+			}
+		})
+	}
+
+	// a block cut off by the connection dropping is an error, not audio
+	icy := &icyReader{reader: bytes.NewReader(append(audio[:8], 2, 'S')), interval: 8, remaining: 8, onTitle: func(string) {}}
+
+	if _, err := io.ReadAll(icy); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("a truncated block gave %v", err)
+	}
+}
+
+// streamServer serves a wav stream, each connection gets whatever the handler for it writes
+type streamServer struct {
+	*httptest.Server
+	connections []time.Time
+	mutex       sync.Mutex
+}
+
+func newStreamServer(t *testing.T, serve func(connection int, writer http.ResponseWriter)) *streamServer {
+	server := &streamServer{}
+
+	server.Server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Header.Get("Icy-MetaData") != "1" {
+			t.Error("the stream was requested without icy metadata")
+		}
+
+		server.mutex.Lock()
+		server.connections = append(server.connections, time.Now())
+		connection := len(server.connections) - 1
+		server.mutex.Unlock()
+
+		writer.Header().Set("Content-Type", "audio/wav")
+		serve(connection, writer)
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func (server *streamServer) connected() []time.Time {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	return slices.Clone(server.connections)
+}
+
+// streamLength is what a wav stream says its data is, as long as it can be since it has no end
+const streamLength = 1<<32 - 1
+
+func streamHeader() []byte {
+	return WAVHeader(testFormat, streamLength)
+}
+
+func sendAll(writer http.ResponseWriter, data []byte) {
+	writer.Write(data)
+	writer.(http.Flusher).Flush()
+}
+
+func TestStreamTitles(t *testing.T) {
+	audio := EncodeInt16(nil, ramp(800, testFormat.Channels))
+	const interval = 1000
+
+	release := make(chan struct{})
+	done := make(chan struct{})
+	defer close(done)
+
+	server := newStreamServer(t, func(connection int, writer http.ResponseWriter) {
+		writer.Header().Set("icy-metaint", strconv.Itoa(interval))
+
+		// the header is audio as far as icy is concerned
+		stream := append(streamHeader(), audio...)
+
+		sendAll(writer, icyInterleave(stream[:2*interval], interval, icyBlock("StreamTitle='one';")))
+		<-release
+
+		// servers send the title again in every block
+		sendAll(writer, append(icyBlock("StreamTitle='one';"), icyInterleave(stream[2*interval:], interval, icyBlock("StreamTitle='two';"))...))
+
+		<-done
+	})
+
+	decoder, err := openStream(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer decoder.Close()
+
+	if decoder.Format() != testFormat {
+		t.Fatalf("format is %+v", decoder.Format())
+	}
+
+	titles := make(chan string, 10)
+	decoder.(titledDecoder).OnTitle(func(title string) { titles <- title })
+
+	nextTitle := func() string {
+		select {
+		case title := <-titles:
+			return title
+		case <-time.After(5 * time.Second):
+			t.Fatal("no title")
+			return ""
+		}
+	}
+
+	// whether it came before or after registering, the first title is heard once
+	if title := nextTitle(); title != "one" {
+		t.Fatalf("first title is %q", title)
+	}
+
+	close(release)
+
+	// the repeated title isn't reported again
+	if title := nextTitle(); title != "two" {
+		t.Fatalf("next title is %q, want two", title)
+	}
+}
+
+func TestStreamReconnects(t *testing.T) {
+	before := ramp(800, testFormat.Channels)
+	// what comes after the drop is easy to tell apart, and more than a read so it doesn't wait for the rest
+	after := slices.Repeat([]float32{0.5}, 2*bufferFrames*testFormat.Channels)
+
+	done := make(chan struct{})
+	defer close(done)
+
+	server := newStreamServer(t, func(connection int, writer http.ResponseWriter) {
+		switch connection {
+		case 0:
+			// and then the connection drops
+			sendAll(writer, append(streamHeader(), EncodeInt16(nil, before)...))
+		case 1:
+			// not back yet
+			writer.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			sendAll(writer, append(streamHeader(), EncodeInt16(nil, after)...))
+			<-done
+		}
+	})
+
+	decoder, err := openStream(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer decoder.Close()
+
+	var played []float32
+
+	samples := make([]float32, bufferFrames*testFormat.Channels)
+	deadline := time.Now().Add(10 * time.Second)
+
+	for !slices.Contains(played, 0.5) {
+		if time.Now().After(deadline) {
+			t.Fatal("the stream never came back")
+		}
+
+		// reconnecting doesn't stop the player, it gets silence meanwhile
+		n, err := decoder.Read(samples)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		played = append(played, samples[:n]...)
+	}
+
+	// everything from before the drop was played first
+	if !slices.Equal(played[:len(before)], before) {
+		t.Fatal("the audio from before the drop was lost")
+	}
+
+	connections := server.connected()
+	if len(connections) != 3 {
+		t.Fatalf("connected %d times, want 3", len(connections))
+	}
+
+	// backing off, every retry waits longer than the one before
+	first, second := connections[1].Sub(connections[0]), connections[2].Sub(connections[1])
+
+	if first < streamRetryDelay || second < 2*streamRetryDelay {
+		t.Fatalf("retried after %s and %s", first, second)
+	}
+}
+
+func TestStreamFormatChanged(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+
+	server := newStreamServer(t, func(connection int, writer http.ResponseWriter) {
+		format := testFormat
+		if connection > 0 {
+			format.SampleRate = 44100
+		}
+
+		sendAll(writer, append(WAVHeader(format, streamLength), EncodeInt16(nil, ramp(100, format.Channels))...))
+
+		if connection > 0 {
+			<-done
+		}
+	})
+
+	decoder, err := openStream(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer decoder.Close()
+
+	samples := make([]float32, bufferFrames*testFormat.Channels)
+	deadline := time.Now().Add(10 * time.Second)
+
+	for {
+		if time.Now().After(deadline) {
+			t.Fatal("the stream kept going in another format")
+		}
+
+		if _, err := decoder.Read(samples); err != nil {
+			if !errors.Is(err, ErrStreamFormatChanged) {
+				t.Fatalf("got %v", err)
+			}
+
+			return
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package playback
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"time"
+)
+
+const (
+	wavFormatPCM        = 1
+	wavFormatFloat      = 3
+	wavFormatExtensible = 0xfffe
+)
+
+var errInvalidWAV = errors.New("invalid wav file")
+
+type wavDecoder struct {
+	file           *os.File // nil for streams
+	reader         io.Reader
+	format         Format
+	formatTag      uint16
+	bytesPerSample int
+	dataStart      int64
+	dataSize       int64
+	offset         int64 // bytes read from the data chunk
+	buffer         []byte
+}
+
+func openWAV(path string) (Decoder, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder, err := newWAVDecoder(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return decoder, nil
+}
+
+func newWAVDecoder(f *os.File) (*wavDecoder, error) {
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return nil, err
+	}
+
+	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return nil, errInvalidWAV
+	}
+
+	decoder := &wavDecoder{file: f, reader: f}
+	foundFormat := false
+
+	for {
+		chunkHeader := make([]byte, 8)
+		if _, err := io.ReadFull(f, chunkHeader); err != nil {
+			return nil, errInvalidWAV
+		}
+
+		id := string(chunkHeader[0:4])
+		size := int64(binary.LittleEndian.Uint32(chunkHeader[4:8]))
+
+		switch id {
+		case "fmt ":
+			if err := decoder.readFormat(f, size); err != nil {
+				return nil, err
+			}
+
+			foundFormat = true
+
+		case "data":
+			if !foundFormat {
+				return nil, errInvalidWAV
+			}
+
+			start, err := f.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return nil, err
+			}
+
+			// streamed wav writers leave the size at 0 or 0xffffffff
+			if info, err := f.Stat(); err == nil && (size == 0 || start+size > info.Size()) {
+				size = info.Size() - start
+			}
+
+			frameSize := int64(decoder.bytesPerSample * decoder.format.Channels)
+			decoder.dataStart = start
+			decoder.dataSize = size - size%frameSize
+
+			return decoder, nil
+
+		default:
+			// chunks are padded to an even size
+			if _, err := f.Seek(size+size%2, io.SeekCurrent); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// newWAVStreamDecoder reads a wav that can't be seeked, its data chunk is read until the stream ends
+func newWAVStreamDecoder(r io.Reader) (*wavDecoder, error) {
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return nil, errInvalidWAV
+	}
+
+	decoder := &wavDecoder{reader: r}
+	foundFormat := false
+
+	for {
+		chunkHeader := make([]byte, 8)
+		if _, err := io.ReadFull(r, chunkHeader); err != nil {
+			return nil, errInvalidWAV
+		}
+
+		id := string(chunkHeader[0:4])
+		size := int64(binary.LittleEndian.Uint32(chunkHeader[4:8]))
+
+		switch id {
+		case "fmt ":
+			if err := decoder.readFormat(r, size); err != nil {
+				return nil, err
+			}
+
+			foundFormat = true
+
+		case "data":
+			if !foundFormat {
+				return nil, errInvalidWAV
+			}
+
+			decoder.dataSize = math.MaxInt64
+
+			return decoder, nil
+
+		default:
+			if _, err := io.CopyN(io.Discard, r, size+size%2); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func (decoder *wavDecoder) readFormat(r io.Reader, size int64) error {
+	if size < 16 {
+		return errInvalidWAV
+	}
+
+	data := make([]byte, size+size%2)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return err
+	}
+
+	formatTag := binary.LittleEndian.Uint16(data[0:2])
+	channels := int(binary.LittleEndian.Uint16(data[2:4]))
+	sampleRate := int(binary.LittleEndian.Uint32(data[4:8]))
+	bitsPerSample := int(binary.LittleEndian.Uint16(data[14:16]))
+
+	// the real format tag lives in the first two bytes of the sub format GUID
+	if formatTag == wavFormatExtensible && size >= 26 {
+		formatTag = binary.LittleEndian.Uint16(data[24:26])
+	}
+
+	switch {
+	case formatTag == wavFormatPCM && (bitsPerSample == 8 || bitsPerSample == 16 ||
+		bitsPerSample == 24 || bitsPerSample == 32):
+	case formatTag == wavFormatFloat && bitsPerSample == 32:
+	default:
+		return ErrUnsupportedFormat
+	}
+
+	if channels <= 0 || sampleRate <= 0 {
+		return errInvalidWAV
+	}
+
+	decoder.format = Format{SampleRate: sampleRate, Channels: channels}
+	decoder.formatTag = formatTag
+	decoder.bytesPerSample = bitsPerSample / 8
+
+	return nil
+}
+
+func (decoder *wavDecoder) Format() Format {
+	return decoder.format
+}
+
+func (decoder *wavDecoder) Read(samples []float32) (int, error) {
+	remaining := decoder.dataSize - decoder.offset
+	if remaining <= 0 {
+		return 0, io.EOF
+	}
+
+	frameSize := decoder.bytesPerSample * decoder.format.Channels
+	frames := min(int64(len(samples)/decoder.format.Channels), remaining/int64(frameSize))
+	size := int(frames) * frameSize
+
+	if cap(decoder.buffer) < size {
+		decoder.buffer = make([]byte, size)
+	}
+
+	buffer := decoder.buffer[:size]
+
+	n, err := io.ReadFull(decoder.reader, buffer)
+	n -= n % frameSize
+	decoder.offset += int64(n)
+
+	count := n / decoder.bytesPerSample
+	for i := range count {
+		samples[i] = decoder.decodeSample(buffer[i*decoder.bytesPerSample:])
+	}
+
+	if err == io.ErrUnexpectedEOF || (err == io.EOF && count > 0) {
+		err = nil
+	}
+
+	return count, err
+}
+
+func (decoder *wavDecoder) decodeSample(data []byte) float32 {
+	switch decoder.bytesPerSample {
+	case 1:
+		// 8-bit wav is the only unsigned variant
+		return float32(int(data[0])-128) / 128
+	case 2:
+		return float32(int16(binary.LittleEndian.Uint16(data))) / 32768
+	case 3:
+		value := int32(uint32(data[0])<<8|uint32(data[1])<<16|uint32(data[2])<<24) >> 8
+		return float32(value) / 8388608
+	default:
+		if decoder.formatTag == wavFormatFloat {
+			return clampSample(math.Float32frombits(binary.LittleEndian.Uint32(data)))
+		}
+
+		return float32(float64(int32(binary.LittleEndian.Uint32(data))) / 2147483648)
+	}
+}
+
+func (decoder *wavDecoder) Seek(position time.Duration) error {
+	if decoder.file == nil {
+		return ErrNotSeekable
+	}
+
+	frameSize := int64(decoder.bytesPerSample * decoder.format.Channels)
+	offset := decoder.format.durationToFrames(position) * frameSize
+	offset = max(0, min(offset, decoder.dataSize))
+
+	if _, err := decoder.file.Seek(decoder.dataStart+offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	decoder.offset = offset
+
+	return nil
+}
+
+func (decoder *wavDecoder) Length() time.Duration {
+	if decoder.file == nil {
+		return 0
+	}
+
+	frameSize := int64(decoder.bytesPerSample * decoder.format.Channels)
+	return decoder.format.framesToDuration(decoder.dataSize / frameSize)
+}
+
+func (decoder *wavDecoder) Close() error {
+	if decoder.file == nil {
+		return nil
+	}
+
+	return decoder.file.Close()
+}
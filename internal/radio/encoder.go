@@ -0,0 +1,100 @@
+package radio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+)
+
+var ErrEncoderMissing = errors.New(`the radio needs ffmpeg in PATH to encode the stream, or radio.format = "wav"`)
+
+// encoding is what listeners get sent, by radio.format
+type encoding struct {
+	contentType string
+	// ffmpeg's output options, none for wav which is sent as is
+	args []string
+}
+
+var encodings = map[string]encoding{
+	"mp3": {"audio/mpeg", []string{"-c:a", "libmp3lame", "-f", "mp3"}},
+	"ogg": {"audio/ogg", []string{"-c:a", "libvorbis", "-f", "ogg"}},
+	// cd audio as is is around 1411kbps, fine on a local network and nowhere else
+	"wav": {"audio/wav", nil},
+}
+
+func (encoding encoding) raw() bool {
+	return encoding.args == nil
+}
+
+// bitrate is what the stream takes in kbps, wav's is fixed
+func (encoding encoding) bitrate(configured int) int {
+	if encoding.raw() {
+		return sampleRate * channels * 16 / 1000
+	}
+
+	return configured
+}
+
+// encoder is an ffmpeg of a listener's own, ogg can't be joined midway so they aren't shared
+type encoder struct {
+	command *exec.Cmd
+	stdin   io.WriteCloser
+	stdout  io.ReadCloser
+}
+
+func startEncoder(encoding encoding, bitrate int) (*encoder, error) {
+	args := []string{
+		"-v", "error",
+		"-f", "s16le",
+		"-ar", strconv.Itoa(sampleRate),
+		"-ac", strconv.Itoa(channels),
+		"-i", "pipe:0",
+		"-b:a", fmt.Sprintf("%dk", bitrate),
+	}
+
+	args = append(args, encoding.args...)
+	// sent as soon as it's encoded, listeners are already a chunk behind
+	args = append(args, "-flush_packets", "1", "pipe:1")
+
+	command := exec.Command("ffmpeg", args...)
+
+	stdin, err := command.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, err := command.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := command.Start(); err != nil {
+		return nil, err
+	}
+
+	return &encoder{command: command, stdin: stdin, stdout: stdout}, nil
+}
+
+// feed pipes a listener's chunks into the encoder until they stop coming or the encoder is gone
+func (encoder *encoder) feed(chunks <-chan []byte) {
+	defer encoder.stdin.Close()
+
+	for chunk := range chunks {
+		if _, err := encoder.stdin.Write(chunk); err != nil {
+			return
+		}
+	}
+}
+
+func (encoder *encoder) Read(p []byte) (int, error) {
+	return encoder.stdout.Read(p)
+}
+
+func (encoder *encoder) Close() error {
+	encoder.stdin.Close()
+	_ = encoder.command.Process.Kill()
+
+	return encoder.command.Wait()
+}
@@ -0,0 +1,372 @@
+// Package radio broadcasts whatever wired plays as an Icecast-compatible HTTP stream
+package radio
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+
+	config "wired/internal/config"
+	engine "wired/internal/engine"
+	playback "wired/internal/playback"
+)
+
+const (
+	// tracks come in all kinds of formats but a stream can't change its format midway,
+	// so everything is converted to cd quality
+	sampleRate = 44100
+	channels   = 2
+
+	chunkDuration = 100 * time.Millisecond
+	chunkBytes    = sampleRate * channels * 2 / int(time.Second/chunkDuration)
+	// how far ahead of the broadcast the player may get before its writes block
+	pendingChunks = 10
+	// how far behind a listener may fall before it gets dropped
+	listenerChunks = 50
+)
+
+var ErrAlreadyServing = errors.New("the radio is already on air")
+
+var streamFormat = playback.Format{SampleRate: sampleRate, Channels: channels}
+
+// Update tells a frontend how many listeners are tuned in
+type Update struct {
+	Listeners int
+}
+
+type listener struct {
+	chunks chan []byte
+}
+
+// Station mirrors the player's output to every connected listener, filling the gaps with silence
+// so the stream never stops while wired is paused or between tracks
+type Station struct {
+	name      string
+	url       string
+	title     string
+	encoding  encoding
+	bitrate   int
+	serving   bool
+	pending   []byte
+	resampler *playback.Resampler
+	listeners map[*listener]struct{}
+	server    *http.Server
+	updates   chan Update
+	stop      func()
+	done      chan struct{}
+	mutex     sync.Mutex
+	cond      *sync.Cond
+}
+
+func New() *Station {
+	station := &Station{
+		resampler: playback.NewResampler(streamFormat),
+		listeners: map[*listener]struct{}{},
+		updates:   make(chan Update, 1),
+	}
+
+	station.cond = sync.NewCond(&station.mutex)
+
+	return station
+}
+
+// Output wraps the output the engine plays to so the station hears the same thing,
+// it does nothing but pass samples along until the station is serving
+func (station *Station) Output(output playback.Output) playback.Output {
+	return &tap{station: station, output: output}
+}
+
+// Serve puts the station on air under name, titles are taken from the tracks the engine plays
+func (station *Station) Serve(cfg config.Radio, name string, player *engine.Engine) error {
+	station.mutex.Lock()
+	defer station.mutex.Unlock()
+
+	if station.serving {
+		return ErrAlreadyServing
+	}
+
+	encoding := encodings[cfg.Format]
+	if _, err := exec.LookPath("ffmpeg"); err != nil && !encoding.raw() {
+		return ErrEncoderMissing
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(cfg.BindAddress, strconv.Itoa(cfg.Port)))
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(cfg.Mount, station.serveStream)
+
+	station.server = &http.Server{Handler: mux}
+	station.name = name
+	station.encoding = encoding
+	station.bitrate = cfg.Bitrate
+	station.url = streamURL(cfg, listener.Addr())
+	station.serving = true
+	station.pending = station.pending[:0]
+	station.done = make(chan struct{})
+
+	events, stop := player.Subscribe()
+	station.stop = stop
+	station.title = streamTitle(player.Status().Track)
+
+	go station.server.Serve(listener)
+	go station.follow(player, events)
+	go station.broadcast(station.done)
+
+	return nil
+}
+
+// URL is where listeners can tune in, empty when the station isn't serving
+func (station *Station) URL() string {
+	station.mutex.Lock()
+	defer station.mutex.Unlock()
+
+	return station.url
+}
+
+func (station *Station) Listeners() int {
+	station.mutex.Lock()
+	defer station.mutex.Unlock()
+
+	return len(station.listeners)
+}
+
+// Updates is signaled when listeners come and go, only the latest update is kept when nobody listens
+func (station *Station) Updates() <-chan Update {
+	return station.updates
+}
+
+func (station *Station) notify() {
+	update := Update{Listeners: len(station.listeners)}
+
+	select {
+	case station.updates <- update:
+		return
+	default:
+	}
+
+	// drop the stale update so the newest one gets through
+	select {
+	case <-station.updates:
+	default:
+	}
+
+	select {
+	case station.updates <- update:
+	default:
+	}
+}
+
+// Close takes the station off air and disconnects every listener
+func (station *Station) Close() error {
+	station.mutex.Lock()
+
+	if !station.serving {
+		station.mutex.Unlock()
+		return nil
+	}
+
+	station.serving = false
+	station.url = ""
+	close(station.done)
+	station.cond.Broadcast()
+
+	for listener := range station.listeners {
+		close(listener.chunks)
+		delete(station.listeners, listener)
+	}
+
+	server := station.server
+	stop := station.stop
+
+	station.mutex.Unlock()
+
+	stop()
+
+	return server.Close()
+}
+
+// follow keeps the stream title in sync with the engine
+func (station *Station) follow(player *engine.Engine, events <-chan engine.Event) {
+	for event := range events {
+		switch event.Type {
+		case engine.TrackStarted, engine.MetadataChanged:
+			station.setTitle(streamTitle(event.Track))
+		case engine.StateChanged:
+			if player.Status().State == playback.Stopped {
+				station.setTitle("")
+			}
+		}
+	}
+}
+
+func (station *Station) setTitle(title string) {
+	station.mutex.Lock()
+	station.title = title
+	station.mutex.Unlock()
+}
+
+// broadcast hands a chunk to every listener in real time, whether the player has something to say or not
+func (station *Station) broadcast(done chan struct{}) {
+	next := time.Now()
+
+	for {
+		next = next.Add(chunkDuration)
+
+		select {
+		case <-done:
+			return
+		case <-time.After(time.Until(next)):
+		}
+
+		// after the machine slept, catch up with the clock rather than flooding listeners
+		if time.Since(next) > time.Second {
+			next = time.Now()
+		}
+
+		chunk := make([]byte, chunkBytes)
+
+		station.mutex.Lock()
+
+		n := copy(chunk, station.pending)
+		station.pending = station.pending[:copy(station.pending, station.pending[n:])]
+		station.cond.Broadcast()
+
+		for listener := range station.listeners {
+			select {
+			case listener.chunks <- chunk:
+			default:
+				// too slow to keep up, holding on to it would only grow the buffer forever
+				close(listener.chunks)
+				delete(station.listeners, listener)
+				station.notify()
+			}
+		}
+
+		station.mutex.Unlock()
+	}
+}
+
+func (station *Station) join() *listener {
+	station.mutex.Lock()
+	defer station.mutex.Unlock()
+
+	if !station.serving {
+		return nil
+	}
+
+	listener := &listener{chunks: make(chan []byte, listenerChunks)}
+	station.listeners[listener] = struct{}{}
+	station.notify()
+
+	return listener
+}
+
+func (station *Station) leave(listener *listener) {
+	station.mutex.Lock()
+	defer station.mutex.Unlock()
+
+	if _, ok := station.listeners[listener]; ok {
+		close(listener.chunks)
+		delete(station.listeners, listener)
+		station.notify()
+	}
+}
+
+// queue converts samples to the stream's format, blocking while the broadcast is far enough behind
+func (station *Station) queue(samples []float32) {
+	station.mutex.Lock()
+	defer station.mutex.Unlock()
+
+	if !station.serving {
+		return
+	}
+
+	for station.serving && len(station.pending) >= pendingChunks*chunkBytes {
+		station.cond.Wait()
+	}
+
+	station.pending = append(station.pending, playback.EncodeInt16(nil, station.resampler.Process(samples))...)
+}
+
+func (station *Station) open(format playback.Format) {
+	station.mutex.Lock()
+	defer station.mutex.Unlock()
+
+	station.resampler.Reset(format)
+}
+
+func streamTitle(track engine.Track) string {
+	// relaying another radio, its title says more than the station's name
+	if track.StreamTitle != "" {
+		return track.StreamTitle
+	}
+
+	if track.Artist == "" {
+		return track.Title
+	}
+
+	return track.Artist + " - " + track.Title
+}
+
+// streamURL guesses the address other machines can reach, a wildcard bind says nothing about it
+func streamURL(cfg config.Radio, addr net.Addr) string {
+	host := cfg.BindAddress
+	port := strconv.Itoa(cfg.Port)
+
+	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+		port = strconv.Itoa(tcpAddr.Port)
+
+		if tcpAddr.IP.IsUnspecified() {
+			host = lanAddress()
+		}
+	}
+
+	return "http://" + net.JoinHostPort(host, port) + cfg.Mount
+}
+
+func lanAddress() string {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return "localhost"
+	}
+
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && ipNet.IP.To4() != nil {
+			return ipNet.IP.String()
+		}
+	}
+
+	return "localhost"
+}
+
+// tap passes everything to the real output and a copy to the station
+type tap struct {
+	station *Station
+	output  playback.Output
+}
+
+func (tap *tap) Open(format playback.Format) error {
+	tap.station.open(format)
+	return tap.output.Open(format)
+}
+
+func (tap *tap) Write(samples []float32) error {
+	if err := tap.output.Write(samples); err != nil {
+		return err
+	}
+
+	tap.station.queue(samples)
+
+	return nil
+}
+
+func (tap *tap) Close() error {
+	return tap.output.Close()
+}
@@ -0,0 +1,194 @@
+package radio
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	playback "wired/internal/playback"
+)
+
+// bytes of audio between two metadata blocks, the usual value Icecast uses
+const metadataInterval = 16000
+
+func (station *Station) serveStream(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet && request.Method != http.MethodHead {
+		writer.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	station.mutex.Lock()
+	encoding := station.encoding
+	bitrate := encoding.bitrate(station.bitrate)
+	station.mutex.Unlock()
+
+	// clients ask for metadata, sending it to one that didn't would corrupt its audio
+	withMetadata := request.Header.Get("Icy-MetaData") == "1"
+
+	header := writer.Header()
+	header.Set("Content-Type", encoding.contentType)
+	header.Set("Cache-Control", "no-cache, no-store")
+	header.Set("icy-name", station.name)
+	header.Set("icy-pub", "0")
+	header.Set("icy-br", strconv.Itoa(bitrate))
+	header.Set("icy-audio-info", fmt.Sprintf("ice-samplerate=%d;ice-channels=%d;ice-bitrate=%d", sampleRate, channels, bitrate))
+
+	if withMetadata {
+		header.Set("icy-metaint", strconv.Itoa(metadataInterval))
+	}
+
+	if request.Method == http.MethodHead {
+		writer.WriteHeader(http.StatusOK)
+		return
+	}
+
+	listener := station.join()
+	if listener == nil {
+		writer.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	defer station.leave(listener)
+
+	var encoded *encoder
+	if !encoding.raw() {
+		var err error
+		if encoded, err = startEncoder(encoding, bitrate); err != nil {
+			writer.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		defer encoded.Close()
+
+		go encoded.feed(listener.chunks)
+	}
+
+	writer.WriteHeader(http.StatusOK)
+
+	flusher, _ := writer.(http.Flusher)
+	buffered := bufio.NewWriter(writer)
+
+	stream := &icyWriter{writer: buffered}
+	if withMetadata {
+		stream.interval = metadataInterval
+	}
+
+	send := func(data []byte) error {
+		station.mutex.Lock()
+		title := station.title
+		station.mutex.Unlock()
+
+		if err := stream.write(data, title); err != nil {
+			return err
+		}
+
+		if err := buffered.Flush(); err != nil {
+			return err
+		}
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		return nil
+	}
+
+	if encoded != nil {
+		data := make([]byte, 4096)
+
+		for {
+			n, err := encoded.Read(data)
+			if n > 0 && send(data[:n]) != nil {
+				return
+			}
+
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	// a wav that never ends, players stream it happily
+	if err := stream.write(playback.WAVHeader(streamFormat, math.MaxUint32-36), ""); err != nil {
+		return
+	}
+
+	for {
+		select {
+		case <-request.Context().Done():
+			return
+
+		case chunk, ok := <-listener.chunks:
+			if !ok || send(chunk) != nil {
+				return
+			}
+		}
+	}
+}
+
+// icyWriter interleaves audio with shoutcast style metadata blocks every interval bytes,
+// a block only carries the title when it changed and is a single zero byte otherwise
+type icyWriter struct {
+	writer   io.Writer
+	interval int
+	written  int
+	title    string
+	sent     bool
+}
+
+func (stream *icyWriter) write(data []byte, title string) error {
+	if stream.interval == 0 {
+		_, err := stream.writer.Write(data)
+		return err
+	}
+
+	for len(data) > 0 {
+		n := min(len(data), stream.interval-stream.written)
+
+		if _, err := stream.writer.Write(data[:n]); err != nil {
+			return err
+		}
+
+		data = data[n:]
+		stream.written += n
+
+		if stream.written == stream.interval {
+			if err := stream.writeMetadata(title); err != nil {
+				return err
+			}
+
+			stream.written = 0
+		}
+	}
+
+	return nil
+}
+
+func (stream *icyWriter) writeMetadata(title string) error {
+	if stream.sent && title == stream.title {
+		_, err := stream.writer.Write([]byte{0})
+		return err
+	}
+
+	stream.title = title
+	stream.sent = true
+
+	// there's no escaping in the format, a quote followed by a semicolon would end the title early
+	title = strings.ReplaceAll(title, "';", "'")
+
+	metadata := "StreamTitle='" + title + "';"
+	// the length byte counts 16 byte blocks, which caps the metadata at 4080 bytes
+	if len(metadata) > 255*16 {
+		metadata = metadata[:255*16-2] + "';"
+	}
+
+	blocks := (len(metadata) + 15) / 16
+	block := make([]byte, 1+blocks*16)
+	block[0] = byte(blocks)
+	copy(block[1:], metadata)
+
+	_, err := stream.writer.Write(block)
+	return err
+}
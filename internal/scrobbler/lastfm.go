@@ -0,0 +1,244 @@
+package scrobbler
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	config "wired/internal/config"
+)
+
+// the most track.scrobble takes in a single call
+const lastFMBatchSize = 50
+
+var ErrLastFMResponse = errors.New("unexpected response from last.fm")
+
+// error codes that come from the service or the credentials rather than the scrobbles themselves,
+// the scrobbles are kept until they go through
+var lastFMRetryable = []int{
+	4,  // authentication failed
+	8,  // operation failed
+	9,  // invalid session key
+	10, // invalid api key
+	11, // service offline
+	13, // invalid method signature
+	16, // temporarily unavailable
+	26, // suspended api key
+	29, // rate limit exceeded
+}
+
+type lastFMError struct {
+	Code    int
+	Message string
+}
+
+func (err *lastFMError) Error() string {
+	return fmt.Sprintf("error %d: %s", err.Code, err.Message)
+}
+
+// LastFM talks to the Last.fm api, or anything that speaks it
+type LastFM struct {
+	apiKey     string
+	apiSecret  string
+	sessionKey string
+	endpoint   string
+	authURL    string
+	client     *http.Client
+}
+
+func NewLastFM(cfg config.LastFM) *LastFM {
+	return &LastFM{
+		apiKey:     cfg.APIKey,
+		apiSecret:  cfg.APISecret,
+		sessionKey: cfg.SessionKey,
+		endpoint:   cfg.Endpoint,
+		authURL:    cfg.AuthURL,
+		client:     &http.Client{Timeout: requestTimeout},
+	}
+}
+
+func (lastfm *LastFM) Name() string {
+	return "last.fm"
+}
+
+func (lastfm *LastFM) BatchSize() int {
+	return lastFMBatchSize
+}
+
+// Token starts the auth flow, the token has to be allowed by the user at AuthURL before asking for a session
+func (lastfm *LastFM) Token(ctx context.Context) (string, error) {
+	var result struct {
+		Token string `json:"token"`
+	}
+
+	if err := lastfm.call(ctx, "auth.getToken", url.Values{}, &result); err != nil {
+		return "", err
+	}
+
+	if result.Token == "" {
+		return "", ErrLastFMResponse
+	}
+
+	return result.Token, nil
+}
+
+func (lastfm *LastFM) AuthURL(token string) string {
+	params := url.Values{}
+	params.Set("api_key", lastfm.apiKey)
+	params.Set("token", token)
+
+	return lastfm.authURL + "?" + params.Encode()
+}
+
+// Session trades an allowed token for a session key, which doesn't expire, along with the user's name
+func (lastfm *LastFM) Session(ctx context.Context, token string) (string, string, error) {
+	var result struct {
+		Session struct {
+			Name string `json:"name"`
+			Key  string `json:"key"`
+		} `json:"session"`
+	}
+
+	params := url.Values{}
+	params.Set("token", token)
+
+	if err := lastfm.call(ctx, "auth.getSession", params, &result); err != nil {
+		return "", "", err
+	}
+
+	if result.Session.Key == "" {
+		return "", "", ErrLastFMResponse
+	}
+
+	return result.Session.Key, result.Session.Name, nil
+}
+
+func (lastfm *LastFM) NowPlaying(ctx context.Context, scrobble Scrobble) error {
+	params := url.Values{}
+	params.Set("sk", lastfm.sessionKey)
+	setTrackParams(params, "", scrobble)
+
+	return lastfm.call(ctx, "track.updateNowPlaying", params, nil)
+}
+
+func (lastfm *LastFM) Submit(ctx context.Context, scrobbles []Scrobble) error {
+	params := url.Values{}
+	params.Set("sk", lastfm.sessionKey)
+
+	for i, scrobble := range scrobbles {
+		suffix := "[" + strconv.Itoa(i) + "]"
+
+		setTrackParams(params, suffix, scrobble)
+		params.Set("timestamp"+suffix, strconv.FormatInt(scrobble.Timestamp.Unix(), 10))
+	}
+
+	// scrobbles last.fm ignores (e.g. too old) come back as accepted calls, there's nothing to retry about them
+	return lastfm.call(ctx, "track.scrobble", params, nil)
+}
+
+func setTrackParams(params url.Values, suffix string, scrobble Scrobble) {
+	params.Set("artist"+suffix, scrobble.Artist)
+	params.Set("track"+suffix, scrobble.Title)
+
+	if scrobble.Album != "" {
+		params.Set("album"+suffix, scrobble.Album)
+	}
+
+	if scrobble.AlbumArtist != "" {
+		params.Set("albumArtist"+suffix, scrobble.AlbumArtist)
+	}
+
+	if scrobble.TrackNumber > 0 {
+		params.Set("trackNumber"+suffix, strconv.Itoa(scrobble.TrackNumber))
+	}
+
+	if scrobble.Duration > 0 {
+		params.Set("duration"+suffix, strconv.Itoa(int(scrobble.Duration.Seconds())))
+	}
+}
+
+// call signs and posts a method, every call wired makes is a signed one
+func (lastfm *LastFM) call(ctx context.Context, method string, params url.Values, result any) error {
+	params.Set("method", method)
+	params.Set("api_key", lastfm.apiKey)
+	params.Set("api_sig", lastfm.sign(params))
+	params.Set("format", "json")
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, lastfm.endpoint, strings.NewReader(params.Encode()))
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := lastfm.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	var body struct {
+		Error   int    `json:"error"`
+		Message string `json:"message"`
+	}
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(response.Body).Decode(&raw); err != nil {
+		if response.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("server returned %s", response.Status)
+		}
+
+		return fmt.Errorf("%w: %w", ErrLastFMResponse, err)
+	}
+
+	if err := json.Unmarshal(raw, &body); err == nil && body.Error != 0 {
+		err := &lastFMError{Code: body.Error, Message: body.Message}
+		if slices.Contains(lastFMRetryable, body.Error) {
+			return err
+		}
+
+		return fmt.Errorf("%w: %w", ErrRejected, err)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned %s", response.Status)
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.Unmarshal(raw, result)
+}
+
+// sign is the md5 of every parameter's name and value sorted by name, followed by the secret
+func (lastfm *LastFM) sign(params url.Values) string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		if name != "format" && name != "callback" && name != "api_sig" {
+			names = append(names, name)
+		}
+	}
+
+	slices.Sort(names)
+
+	var builder strings.Builder
+	for _, name := range names {
+		builder.WriteString(name)
+		builder.WriteString(params.Get(name))
+	}
+
+	builder.WriteString(lastfm.apiSecret)
+
+	sum := md5.Sum([]byte(builder.String()))
+
+	return hex.EncodeToString(sum[:])
+}
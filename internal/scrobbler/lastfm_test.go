@@ -0,0 +1,267 @@
+package scrobbler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	config "wired/internal/config"
+)
+
+// fakeLastFM answers the api's methods with whatever the test sets up, keeping every call it got
+type fakeLastFM struct {
+	*httptest.Server
+	t       *testing.T
+	answers map[string]func(form url.Values) any
+	calls   []url.Values
+	mutex   sync.Mutex
+}
+
+func newFakeLastFM(t *testing.T) *fakeLastFM {
+	fake := &fakeLastFM{t: t, answers: map[string]func(form url.Values) any{}}
+
+	fake.Server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if err := request.ParseForm(); err != nil {
+			t.Error(err)
+		}
+
+		form := request.PostForm
+
+		fake.mutex.Lock()
+		fake.calls = append(fake.calls, form)
+		answer := fake.answers[form.Get("method")]
+		fake.mutex.Unlock()
+
+		if form.Get("format") != "json" {
+			t.Errorf("%s asked for %q", form.Get("method"), form.Get("format"))
+		}
+
+		// checked against the known signature in TestLastFMSign
+		if sig := (&LastFM{apiSecret: "secret"}).sign(form); form.Get("api_sig") != sig {
+			writer.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(writer).Encode(map[string]any{"error": 13, "message": "Invalid method signature supplied"})
+
+			return
+		}
+
+		if answer == nil {
+			json.NewEncoder(writer).Encode(map[string]any{})
+			return
+		}
+
+		json.NewEncoder(writer).Encode(answer(form))
+	}))
+
+	t.Cleanup(fake.Close)
+
+	return fake
+}
+
+func (fake *fakeLastFM) target(sessionKey string) *LastFM {
+	return NewLastFM(config.LastFM{
+		APIKey:     "key",
+		APISecret:  "secret",
+		SessionKey: sessionKey,
+		Endpoint:   fake.URL,
+		AuthURL:    "https://www.last.fm/api/auth/",
+	})
+}
+
+func (fake *fakeLastFM) answer(method string, answer func(form url.Values) any) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	fake.answers[method] = answer
+}
+
+func (fake *fakeLastFM) received() []url.Values {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	return fake.calls
+}
+
+func lastFMFailure(code int) func(url.Values) any {
+	return func(url.Values) any {
+		return map[string]any{"error": code, "message": "failed"}
+	}
+}
+
+func TestLastFMSign(t *testing.T) {
+	lastfm := &LastFM{apiSecret: "secret"}
+
+	params := url.Values{}
+	params.Set("token", "tok-1")
+	params.Set("method", "auth.getSession")
+	params.Set("api_key", "key")
+	// never part of the signature
+	params.Set("format", "json")
+	params.Set("callback", "cb")
+
+	if sig := lastfm.sign(params); sig != "e6534e2119fe5e25ef3ad495520afd32" {
+		t.Fatalf("signature is %s", sig)
+	}
+
+	params.Set("api_sig", "whatever")
+	if sig := lastfm.sign(params); sig != "e6534e2119fe5e25ef3ad495520afd32" {
+		t.Fatalf("signature changed with api_sig set, got %s", sig)
+	}
+}
+
+func TestLastFMAuth(t *testing.T) {
+	fake := newFakeLastFM(t)
+	lastfm := fake.target("")
+
+	fake.answer("auth.getToken", func(url.Values) any {
+		return map[string]any{"token": "tok-1"}
+	})
+
+	fake.answer("auth.getSession", func(form url.Values) any {
+		// the token is only good once the user allowed it
+		if form.Get("token") != "tok-1" {
+			return map[string]any{"error": 14, "message": "Unauthorized Token - This token has not been issued"}
+		}
+
+		return map[string]any{"session": map[string]any{"name": "lain", "key": "session-1", "subscriber": 0}}
+	})
+
+	token, err := lastfm.Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if token != "tok-1" {
+		t.Fatalf("token is %q", token)
+	}
+
+	if authURL := lastfm.AuthURL(token); authURL != "https://www.last.fm/api/auth/?api_key=key&token=tok-1" {
+		t.Fatalf("auth url is %s", authURL)
+	}
+
+	key, name, err := lastfm.Session(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if key != "session-1" || name != "lain" {
+		t.Fatalf("got session %q for %q", key, name)
+	}
+
+	if _, _, err := lastfm.Session(context.Background(), "tok-2"); !errors.Is(err, ErrRejected) {
+		t.Fatalf("an unknown token gave %v, want it rejected", err)
+	}
+
+	calls := fake.received()
+	if len(calls) != 3 || calls[0].Get("api_sig") != "b4705499705a550b07ca058a15bde9b0" {
+		t.Fatalf("got calls %v", calls)
+	}
+}
+
+func TestLastFMBadResponse(t *testing.T) {
+	fake := newFakeLastFM(t)
+
+	if _, err := fake.target("").Token(context.Background()); !errors.Is(err, ErrLastFMResponse) {
+		t.Fatalf("a response without a token gave %v", err)
+	}
+
+	// signed with the wrong secret, which last.fm says is worth retrying
+	lastfm := fake.target("")
+	lastfm.apiSecret = "wrong"
+
+	err := lastfm.Submit(context.Background(), []Scrobble{{Artist: "a", Title: "t", Timestamp: time.Unix(1, 0)}})
+
+	var lastfmErr *lastFMError
+	if !errors.As(err, &lastfmErr) || lastfmErr.Code != 13 || errors.Is(err, ErrRejected) {
+		t.Fatalf("a bad signature gave %v", err)
+	}
+}
+
+func TestLastFMSubmit(t *testing.T) {
+	fake := newFakeLastFM(t)
+
+	scrobbles := []Scrobble{
+		{Artist: "a", Title: "one", Album: "x", AlbumArtist: "va", TrackNumber: 3, Duration: 200 * time.Second, Timestamp: time.Unix(100, 0)},
+		{Artist: "b", Title: "two", Timestamp: time.Unix(400, 0)},
+	}
+
+	if err := fake.target("session-1").Submit(context.Background(), scrobbles); err != nil {
+		t.Fatal(err)
+	}
+
+	form := fake.received()[0]
+
+	want := map[string]string{
+		"method":         "track.scrobble",
+		"sk":             "session-1",
+		"artist[0]":      "a",
+		"track[0]":       "one",
+		"album[0]":       "x",
+		"albumArtist[0]": "va",
+		"trackNumber[0]": "3",
+		"duration[0]":    "200",
+		"timestamp[0]":   "100",
+		"artist[1]":      "b",
+		"track[1]":       "two",
+		"timestamp[1]":   "400",
+	}
+
+	for name, value := range want {
+		if form.Get(name) != value {
+			t.Errorf("%s is %q, want %q", name, form.Get(name), value)
+		}
+	}
+
+	if form.Has("album[1]") || form.Has("duration[1]") {
+		t.Errorf("empty fields were sent: %v", form)
+	}
+}
+
+func TestLastFMErrors(t *testing.T) {
+	tests := []struct {
+		code     int
+		rejected bool
+	}{
+		{6, true},   // invalid parameters
+		{9, false},  // invalid session key
+		{11, false}, // service offline
+		{29, false}, // rate limit exceeded
+	}
+
+	for _, test := range tests {
+		t.Run(strconv.Itoa(test.code), func(t *testing.T) {
+			fake := newFakeLastFM(t)
+			fake.answer("track.scrobble", lastFMFailure(test.code))
+
+			err := fake.target("session-1").Submit(context.Background(), []Scrobble{{Artist: "a", Title: "t"}})
+
+			var lastfmErr *lastFMError
+			if !errors.As(err, &lastfmErr) || lastfmErr.Code != test.code {
+				t.Fatalf("got %v, want error %d", err, test.code)
+			}
+
+			if errors.Is(err, ErrRejected) != test.rejected {
+				t.Fatalf("error %d rejected: %t, want %t", test.code, errors.Is(err, ErrRejected), test.rejected)
+			}
+		})
+	}
+
+	// a server that's down doesn't say anything in json
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		http.Error(writer, "bad gateway", http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	lastfm := NewLastFM(config.LastFM{APIKey: "key", APISecret: "secret", Endpoint: server.URL})
+
+	err := lastfm.Submit(context.Background(), []Scrobble{{Artist: "a", Title: "t"}})
+	if err == nil || errors.Is(err, ErrRejected) {
+		t.Fatalf("a bad gateway gave %v, want it retried", err)
+	}
+}
@@ -0,0 +1,151 @@
+package scrobbler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	config "wired/internal/config"
+)
+
+// listenbrainz takes up to 1000 listens per request, smaller batches keep a failing request cheap to retry
+const listenBrainzBatchSize = 100
+
+type listenBrainzSubmission struct {
+	ListenType string               `json:"listen_type"`
+	Payload    []listenBrainzListen `json:"payload"`
+}
+
+type listenBrainzListen struct {
+	ListenedAt    int64                     `json:"listened_at,omitempty"`
+	TrackMetadata listenBrainzTrackMetadata `json:"track_metadata"`
+}
+
+type listenBrainzTrackMetadata struct {
+	ArtistName     string                 `json:"artist_name"`
+	TrackName      string                 `json:"track_name"`
+	ReleaseName    string                 `json:"release_name,omitempty"`
+	AdditionalInfo listenBrainzAdditional `json:"additional_info"`
+}
+
+type listenBrainzAdditional struct {
+	DurationMs       int64  `json:"duration_ms,omitempty"`
+	TrackNumber      int    `json:"tracknumber,omitempty"`
+	ReleaseArtist    string `json:"release_artist_name,omitempty"`
+	MediaPlayer      string `json:"media_player"`
+	SubmissionClient string `json:"submission_client"`
+}
+
+// ListenBrainz submits listens through the ListenBrainz json api, or anything that speaks it
+type ListenBrainz struct {
+	token    string
+	endpoint string
+	client   *http.Client
+}
+
+func NewListenBrainz(cfg config.ListenBrainz) *ListenBrainz {
+	return &ListenBrainz{
+		token:    cfg.Token,
+		endpoint: strings.TrimSuffix(cfg.Endpoint, "/"),
+		client:   &http.Client{Timeout: requestTimeout},
+	}
+}
+
+func (listenbrainz *ListenBrainz) Name() string {
+	return "listenbrainz"
+}
+
+func (listenbrainz *ListenBrainz) BatchSize() int {
+	return listenBrainzBatchSize
+}
+
+func (listenbrainz *ListenBrainz) NowPlaying(ctx context.Context, scrobble Scrobble) error {
+	listen := newListenBrainzListen(scrobble)
+	// a listen that's still playing has no time yet
+	listen.ListenedAt = 0
+
+	return listenbrainz.submit(ctx, "playing_now", []listenBrainzListen{listen})
+}
+
+func (listenbrainz *ListenBrainz) Submit(ctx context.Context, scrobbles []Scrobble) error {
+	listens := make([]listenBrainzListen, len(scrobbles))
+	for i, scrobble := range scrobbles {
+		listens[i] = newListenBrainzListen(scrobble)
+	}
+
+	listenType := "import"
+	if len(listens) == 1 {
+		listenType = "single"
+	}
+
+	return listenbrainz.submit(ctx, listenType, listens)
+}
+
+func newListenBrainzListen(scrobble Scrobble) listenBrainzListen {
+	return listenBrainzListen{
+		ListenedAt: scrobble.Timestamp.Unix(),
+		TrackMetadata: listenBrainzTrackMetadata{
+			ArtistName:  scrobble.Artist,
+			TrackName:   scrobble.Title,
+			ReleaseName: scrobble.Album,
+			AdditionalInfo: listenBrainzAdditional{
+				DurationMs:       scrobble.Duration.Milliseconds(),
+				TrackNumber:      scrobble.TrackNumber,
+				ReleaseArtist:    scrobble.AlbumArtist,
+				MediaPlayer:      "wired",
+				SubmissionClient: "wired",
+			},
+		},
+	}
+}
+
+func (listenbrainz *ListenBrainz) submit(ctx context.Context, listenType string, listens []listenBrainzListen) error {
+	data, err := json.Marshal(listenBrainzSubmission{ListenType: listenType, Payload: listens})
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		listenbrainz.endpoint+"/1/submit-listens",
+		bytes.NewReader(data),
+	)
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Authorization", "Token "+listenbrainz.token)
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := listenbrainz.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var body struct {
+		Error string `json:"error"`
+	}
+
+	message := response.Status
+	if json.NewDecoder(response.Body).Decode(&body) == nil && body.Error != "" {
+		message = body.Error
+	}
+
+	err = fmt.Errorf("server returned %d: %s", response.StatusCode, message)
+
+	// a bad token, rate limits and server trouble all pass, malformed listens never will
+	if response.StatusCode == http.StatusBadRequest {
+		return fmt.Errorf("%w: %w", ErrRejected, err)
+	}
+
+	return err
+}
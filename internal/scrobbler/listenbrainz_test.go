@@ -0,0 +1,117 @@
+package scrobbler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	config "wired/internal/config"
+)
+
+func TestListenBrainzSubmit(t *testing.T) {
+	var submissions []listenBrainzSubmission
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path != "/1/submit-listens" || request.Header.Get("Authorization") != "Token token-1" {
+			t.Errorf("got %s with %q", request.URL.Path, request.Header.Get("Authorization"))
+		}
+
+		var submission listenBrainzSubmission
+		if err := json.NewDecoder(request.Body).Decode(&submission); err != nil {
+			t.Error(err)
+		}
+
+		submissions = append(submissions, submission)
+	}))
+	defer server.Close()
+
+	listenbrainz := NewListenBrainz(config.ListenBrainz{Token: "token-1", Endpoint: server.URL + "/"})
+
+	scrobble := Scrobble{
+		Artist:      "a",
+		Title:       "one",
+		Album:       "x",
+		AlbumArtist: "va",
+		TrackNumber: 3,
+		Duration:    200 * time.Second,
+		Timestamp:   time.Unix(100, 0),
+	}
+
+	if err := listenbrainz.NowPlaying(context.Background(), scrobble); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := listenbrainz.Submit(context.Background(), []Scrobble{scrobble}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := listenbrainz.Submit(context.Background(), []Scrobble{scrobble, scrobble}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(submissions) != 3 {
+		t.Fatalf("got %d submissions", len(submissions))
+	}
+
+	for i, want := range []string{"playing_now", "single", "import"} {
+		if submissions[i].ListenType != want {
+			t.Errorf("submission %d is %q, want %q", i, submissions[i].ListenType, want)
+		}
+	}
+
+	if listen := submissions[0].Payload[0]; listen.ListenedAt != 0 {
+		t.Errorf("now playing was sent with a time, %d", listen.ListenedAt)
+	}
+
+	listen := submissions[1].Payload[0]
+	metadata := listen.TrackMetadata
+
+	if listen.ListenedAt != 100 || metadata.ArtistName != "a" || metadata.TrackName != "one" || metadata.ReleaseName != "x" {
+		t.Errorf("got listen %+v", listen)
+	}
+
+	if info := metadata.AdditionalInfo; info.DurationMs != 200000 || info.TrackNumber != 3 || info.ReleaseArtist != "va" {
+		t.Errorf("got additional info %+v", info)
+	}
+
+	if len(submissions[2].Payload) != 2 {
+		t.Errorf("got %d listens in the import", len(submissions[2].Payload))
+	}
+}
+
+func TestListenBrainzErrors(t *testing.T) {
+	tests := []struct {
+		status   int
+		rejected bool
+	}{
+		{http.StatusBadRequest, true},
+		{http.StatusUnauthorized, false},
+		{http.StatusTooManyRequests, false},
+		{http.StatusServiceUnavailable, false},
+	}
+
+	for _, test := range tests {
+		t.Run(http.StatusText(test.status), func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(test.status)
+				json.NewEncoder(writer).Encode(map[string]any{"code": test.status, "error": "nope"})
+			}))
+			defer server.Close()
+
+			listenbrainz := NewListenBrainz(config.ListenBrainz{Token: "token-1", Endpoint: server.URL})
+
+			err := listenbrainz.Submit(context.Background(), []Scrobble{{Artist: "a", Title: "t"}})
+			if err == nil {
+				t.Fatal("no error")
+			}
+
+			if errors.Is(err, ErrRejected) != test.rejected {
+				t.Fatalf("%v rejected: %t, want %t", err, errors.Is(err, ErrRejected), test.rejected)
+			}
+		})
+	}
+}
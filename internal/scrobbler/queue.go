@@ -0,0 +1,154 @@
+package scrobbler
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	dirPerm  = 0o755
+	filePerm = 0o644
+)
+
+type scrobbleFile struct {
+	Artist      string `json:"artist"`
+	Title       string `json:"title"`
+	Album       string `json:"album,omitempty"`
+	AlbumArtist string `json:"album_artist,omitempty"`
+	TrackNumber int    `json:"track_number,omitempty"`
+	DurationMs  int64  `json:"duration_ms,omitempty"`
+	Timestamp   int64  `json:"timestamp"` // unix seconds
+}
+
+// queue holds the scrobbles a target hasn't accepted yet, it's written to disk on every change
+// so nothing listened to while offline gets lost
+type queue struct {
+	path      string
+	scrobbles []Scrobble
+}
+
+func (queue *queue) len() int {
+	return len(queue.scrobbles)
+}
+
+func (queue *queue) add(scrobble Scrobble) error {
+	queue.scrobbles = append(queue.scrobbles, scrobble)
+	return queue.save()
+}
+
+// peek returns up to size of the oldest scrobbles, services expect them in order
+func (queue *queue) peek(size int) []Scrobble {
+	return queue.scrobbles[:min(size, len(queue.scrobbles))]
+}
+
+func (queue *queue) drop(count int) error {
+	queue.scrobbles = queue.scrobbles[min(count, len(queue.scrobbles)):]
+	return queue.save()
+}
+
+func (queue *queue) save() error {
+	if queue.path == "" {
+		return nil
+	}
+
+	if len(queue.scrobbles) == 0 {
+		err := os.Remove(queue.path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	files := make([]scrobbleFile, len(queue.scrobbles))
+	for i, scrobble := range queue.scrobbles {
+		files[i] = scrobbleFile{
+			Artist:      scrobble.Artist,
+			Title:       scrobble.Title,
+			Album:       scrobble.Album,
+			AlbumArtist: scrobble.AlbumArtist,
+			TrackNumber: scrobble.TrackNumber,
+			DurationMs:  scrobble.Duration.Milliseconds(),
+			Timestamp:   scrobble.Timestamp.Unix(),
+		}
+	}
+
+	data, err := json.MarshalIndent(files, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(queue.path), dirPerm); err != nil {
+		return err
+	}
+
+	// written next to the queue and renamed over it, a crash mid-write shouldn't eat the backlog
+	temporary := queue.path + ".tmp"
+	if err := os.WriteFile(temporary, data, filePerm); err != nil {
+		return err
+	}
+
+	return os.Rename(temporary, queue.path)
+}
+
+// loadQueue reads the pending scrobbles of a target, on errors an empty queue is returned
+// that still saves to the same file
+func loadQueue(name string) (*queue, error) {
+	path, err := getQueuePath(name)
+	if err != nil {
+		return &queue{}, err
+	}
+
+	loaded := &queue{path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return loaded, nil
+	}
+
+	if err != nil {
+		return loaded, err
+	}
+
+	var files []scrobbleFile
+	if err := json.Unmarshal(data, &files); err != nil {
+		return loaded, err
+	}
+
+	for _, file := range files {
+		loaded.scrobbles = append(loaded.scrobbles, Scrobble{
+			Artist:      file.Artist,
+			Title:       file.Title,
+			Album:       file.Album,
+			AlbumArtist: file.AlbumArtist,
+			TrackNumber: file.TrackNumber,
+			Duration:    time.Duration(file.DurationMs) * time.Millisecond,
+			Timestamp:   time.Unix(file.Timestamp, 0),
+		})
+	}
+
+	return loaded, nil
+}
+
+// pending scrobbles can't be rebuilt, so like the history they go to the state dir
+func getQueuePath(name string) (string, error) {
+	dir := os.Getenv("XDG_STATE_HOME")
+
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+
+		dir = filepath.Join(home, ".local", "state")
+	}
+
+	name = strings.NewReplacer(".", "", " ", "-", "/", "-").Replace(strings.ToLower(name))
+
+	return filepath.Join(dir, "wired", "scrobbles", name+".json"), nil
+}
@@ -0,0 +1,253 @@
+// Package scrobbler submits what was listened to to scrobbling services like Last.fm
+package scrobbler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	config "wired/internal/config"
+	engine "wired/internal/engine"
+)
+
+const (
+	// the usual scrobbling rule: tracks longer than minimumDuration count once half of them,
+	// or maximumThreshold, were heard
+	minimumDuration  = 30 * time.Second
+	maximumThreshold = 4 * time.Minute
+
+	requestTimeout = 15 * time.Second
+	retryInterval  = 5 * time.Minute
+	reportBuffer   = 16
+)
+
+var (
+	// ErrRejected is wrapped by targets when a service refused the scrobbles for good, retrying wouldn't help
+	ErrRejected = errors.New("rejected")
+
+	ErrLastFMNotAuthenticated = errors.New("last.fm is enabled but not authenticated yet, run `wired auth lastfm`")
+)
+
+type Scrobble struct {
+	Artist      string
+	Title       string
+	Album       string
+	AlbumArtist string
+	TrackNumber int
+	Duration    time.Duration
+	// when the track started playing
+	Timestamp time.Time
+}
+
+// Target is a scrobbling service
+type Target interface {
+	Name() string
+	// BatchSize is how many scrobbles the service takes at once
+	BatchSize() int
+	NowPlaying(ctx context.Context, scrobble Scrobble) error
+	Submit(ctx context.Context, scrobbles []Scrobble) error
+}
+
+// Report is an error one of the targets ran into
+type Report struct {
+	Target string
+	Error  error
+}
+
+type target struct {
+	Target
+	queue *queue
+	// whether the last attempt failed, so an offline machine doesn't report every retry
+	failing bool
+}
+
+type Scrobbler struct {
+	targets []*target
+	reports chan Report
+	stop    func()
+	done    chan struct{}
+}
+
+func Eligible(duration time.Duration, listened time.Duration) bool {
+	if duration > 0 && duration <= minimumDuration {
+		return false
+	}
+
+	threshold := maximumThreshold
+	if duration > 0 {
+		threshold = min(duration/2, maximumThreshold)
+	}
+
+	return listened >= threshold
+}
+
+func NewScrobble(track engine.Track, started time.Time) Scrobble {
+	return Scrobble{
+		Artist:      track.Artist,
+		Title:       track.Title,
+		Album:       track.Album,
+		AlbumArtist: track.AlbumArtist,
+		TrackNumber: track.TrackNumber,
+		Duration:    track.Duration,
+		Timestamp:   started,
+	}
+}
+
+// Targets builds every service the config enables, those that can't be used yet are reported instead
+func Targets(cfg *config.Config) ([]Target, []error) {
+	var targets []Target
+	var errs []error
+
+	if cfg.LastFM.Enabled {
+		if cfg.LastFM.SessionKey == "" {
+			errs = append(errs, ErrLastFMNotAuthenticated)
+		} else {
+			targets = append(targets, NewLastFM(cfg.LastFM))
+		}
+	}
+
+	if cfg.ListenBrainz.Enabled {
+		targets = append(targets, NewListenBrainz(cfg.ListenBrainz))
+	}
+
+	return targets, errs
+}
+
+// Start follows the engine and sends every eligible play to all the targets,
+// scrobbles that couldn't be sent are kept on disk and retried later
+func Start(player *engine.Engine, targets ...Target) *Scrobbler {
+	events, stop := player.Subscribe()
+
+	scrobbler := &Scrobbler{
+		reports: make(chan Report, reportBuffer),
+		stop:    stop,
+		done:    make(chan struct{}),
+	}
+
+	for _, t := range targets {
+		queue, err := loadQueue(t.Name())
+		if err != nil {
+			scrobbler.report(t.Name(), fmt.Errorf("couldn't read the pending scrobbles: %w", err))
+		}
+
+		scrobbler.targets = append(scrobbler.targets, &target{Target: t, queue: queue})
+	}
+
+	go scrobbler.run(events)
+
+	return scrobbler
+}
+
+// Reports delivers the targets' errors, they're dropped when nobody reads them
+func (scrobbler *Scrobbler) Reports() <-chan Report {
+	return scrobbler.reports
+}
+
+func (scrobbler *Scrobbler) report(name string, err error) {
+	select {
+	case scrobbler.reports <- Report{Target: name, Error: err}:
+	default:
+	}
+}
+
+func (scrobbler *Scrobbler) run(events <-chan engine.Event) {
+	defer close(scrobbler.done)
+
+	retry := time.NewTicker(retryInterval)
+	defer retry.Stop()
+
+	// whatever was left over from last time goes first
+	scrobbler.flush()
+
+	for {
+		select {
+		case <-retry.C:
+			scrobbler.flush()
+
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+
+			switch event.Type {
+			case engine.TrackStarted:
+				if event.Track.Artist != "" {
+					scrobbler.nowPlaying(NewScrobble(event.Track, time.Now()))
+				}
+
+			case engine.TrackEnded:
+				scrobble := NewScrobble(event.Track, event.Started)
+
+				// services refuse tracks without an artist, and a whole batch with them
+				if scrobble.Artist == "" || !Eligible(event.Track.Duration, event.Listened) {
+					continue
+				}
+
+				for _, t := range scrobbler.targets {
+					if err := t.queue.add(scrobble); err != nil {
+						scrobbler.report(t.Name(), fmt.Errorf("couldn't save the scrobble: %w", err))
+					}
+				}
+
+				scrobbler.flush()
+			}
+		}
+	}
+}
+
+func (scrobbler *Scrobbler) nowPlaying(scrobble Scrobble) {
+	for _, t := range scrobbler.targets {
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		err := t.NowPlaying(ctx, scrobble)
+		cancel()
+
+		// now playing is best effort, being offline is reported by the scrobbles themselves
+		if errors.Is(err, ErrRejected) {
+			scrobbler.report(t.Name(), err)
+		}
+	}
+}
+
+// flush submits the pending scrobbles of every target, stopping at a target's first failure
+func (scrobbler *Scrobbler) flush() {
+	for _, t := range scrobbler.targets {
+		for {
+			batch := t.queue.peek(t.BatchSize())
+			if len(batch) == 0 {
+				break
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+			err := t.Submit(ctx, batch)
+			cancel()
+
+			if err != nil && !errors.Is(err, ErrRejected) {
+				if !t.failing {
+					scrobbler.report(t.Name(), fmt.Errorf("%w, %d scrobbles will be retried later", err, t.queue.len()))
+				}
+
+				t.failing = true
+
+				break
+			}
+
+			t.failing = false
+
+			if err != nil {
+				scrobbler.report(t.Name(), err)
+			}
+
+			if err := t.queue.drop(len(batch)); err != nil {
+				scrobbler.report(t.Name(), fmt.Errorf("couldn't save the pending scrobbles: %w", err))
+				break
+			}
+		}
+	}
+}
+
+// Close stops following the engine, scrobbles still pending stay on disk
+func (scrobbler *Scrobbler) Close() {
+	scrobbler.stop()
+	<-scrobbler.done
+}
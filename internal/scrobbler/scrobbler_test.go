@@ -0,0 +1,268 @@
+package scrobbler
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+// newTestScrobbler keeps the queues of its targets in a state dir of its own
+func newTestScrobbler(t *testing.T, targets ...Target) *Scrobbler {
+	t.Helper()
+
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	scrobbler := &Scrobbler{reports: make(chan Report, reportBuffer)}
+
+	for _, target := range targets {
+		addTarget(t, scrobbler, target)
+	}
+
+	return scrobbler
+}
+
+// addTarget loads the target's queue the way Start does
+func addTarget(t *testing.T, scrobbler *Scrobbler, added Target) {
+	t.Helper()
+
+	queue, err := loadQueue(added.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scrobbler.targets = append(scrobbler.targets, &target{Target: added, queue: queue})
+}
+
+func testScrobbles(count int) []Scrobble {
+	scrobbles := make([]Scrobble, count)
+	for i := range scrobbles {
+		scrobbles[i] = Scrobble{
+			Artist:    "artist",
+			Title:     fmt.Sprintf("track %d", i),
+			Timestamp: time.Unix(int64(1000+i), 0),
+		}
+	}
+
+	return scrobbles
+}
+
+func queueScrobbles(t *testing.T, scrobbler *Scrobbler, scrobbles []Scrobble) {
+	t.Helper()
+
+	for _, scrobble := range scrobbles {
+		for _, target := range scrobbler.targets {
+			if err := target.queue.add(scrobble); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func nextReport(t *testing.T, scrobbler *Scrobbler) Report {
+	t.Helper()
+
+	select {
+	case report := <-scrobbler.Reports():
+		return report
+	default:
+		t.Fatal("nothing was reported")
+		return Report{}
+	}
+}
+
+func TestFlushBatches(t *testing.T) {
+	fake := newFakeLastFM(t)
+	scrobbler := newTestScrobbler(t, fake.target("session-1"))
+
+	queueScrobbles(t, scrobbler, testScrobbles(120))
+	scrobbler.flush()
+
+	calls := fake.received()
+	if len(calls) != 3 {
+		t.Fatalf("120 scrobbles took %d calls, want 3", len(calls))
+	}
+
+	// in order, 50 at a time
+	for i, size := range []int{50, 50, 20} {
+		if !calls[i].Has(fmt.Sprintf("track[%d]", size-1)) || calls[i].Has(fmt.Sprintf("track[%d]", size)) {
+			t.Fatalf("call %d didn't have %d scrobbles: %v", i, size, calls[i])
+		}
+
+		if first := calls[i].Get("track[0]"); first != fmt.Sprintf("track %d", i*50) {
+			t.Fatalf("call %d starts with %q", i, first)
+		}
+	}
+
+	if scrobbler.targets[0].queue.len() != 0 {
+		t.Fatalf("%d scrobbles are still queued", scrobbler.targets[0].queue.len())
+	}
+}
+
+func TestFlushKeepsRetryable(t *testing.T) {
+	fake := newFakeLastFM(t)
+	fake.answer("track.scrobble", lastFMFailure(11))
+
+	scrobbler := newTestScrobbler(t, fake.target("session-1"))
+
+	queueScrobbles(t, scrobbler, testScrobbles(60))
+	scrobbler.flush()
+
+	// the first failure stops the flush, there's no point trying the next batch
+	if calls := len(fake.received()); calls != 1 {
+		t.Fatalf("made %d calls while the service was offline", calls)
+	}
+
+	if report := nextReport(t, scrobbler); report.Target != "last.fm" || report.Error == nil {
+		t.Fatalf("got %+v", report)
+	}
+
+	if scrobbler.targets[0].queue.len() != 60 {
+		t.Fatalf("%d scrobbles are queued, want all 60 kept", scrobbler.targets[0].queue.len())
+	}
+
+	// still offline, that was already said once
+	scrobbler.flush()
+
+	select {
+	case report := <-scrobbler.Reports():
+		t.Fatalf("reported %+v again", report)
+	default:
+	}
+
+	fake.answer("track.scrobble", nil)
+	scrobbler.flush()
+
+	if scrobbler.targets[0].queue.len() != 0 {
+		t.Fatalf("%d scrobbles are queued after the service came back", scrobbler.targets[0].queue.len())
+	}
+}
+
+func TestFlushDropsRejected(t *testing.T) {
+	fake := newFakeLastFM(t)
+	fake.answer("track.scrobble", func(form url.Values) any {
+		// only the first batch is broken
+		if form.Get("track[0]") == "track 0" {
+			return map[string]any{"error": 6, "message": "Invalid parameters"}
+		}
+
+		return map[string]any{}
+	})
+
+	scrobbler := newTestScrobbler(t, fake.target("session-1"))
+
+	queueScrobbles(t, scrobbler, testScrobbles(60))
+	scrobbler.flush()
+
+	if report := nextReport(t, scrobbler); !errors.Is(report.Error, ErrRejected) {
+		t.Fatalf("got %+v, want the batch rejected", report)
+	}
+
+	// retrying wouldn't help, so they're gone and the next batch went through
+	if calls := len(fake.received()); calls != 2 {
+		t.Fatalf("made %d calls, want 2", calls)
+	}
+
+	if scrobbler.targets[0].queue.len() != 0 {
+		t.Fatalf("%d scrobbles are still queued", scrobbler.targets[0].queue.len())
+	}
+}
+
+func TestQueueSurvivesRestart(t *testing.T) {
+	fake := newFakeLastFM(t)
+	fake.answer("track.scrobble", lastFMFailure(16))
+
+	scrobbler := newTestScrobbler(t, fake.target("session-1"))
+
+	scrobbles := testScrobbles(3)
+	scrobbles[0].Album = "album"
+	scrobbles[0].AlbumArtist = "various"
+	scrobbles[0].TrackNumber = 2
+	scrobbles[0].Duration = 3 * time.Minute
+
+	queueScrobbles(t, scrobbler, scrobbles)
+	scrobbler.flush()
+
+	// the next start picks up where this one left off
+	restarted := &Scrobbler{reports: make(chan Report, reportBuffer)}
+	addTarget(t, restarted, fake.target("session-1"))
+
+	if queued := restarted.targets[0].queue.scrobbles; !slices.EqualFunc(queued, scrobbles, sameScrobble) {
+		t.Fatalf("got %+v back, want %+v", queued, scrobbles)
+	}
+
+	fake.answer("track.scrobble", nil)
+	restarted.flush()
+
+	// nothing left, so nothing left on disk either
+	if _, err := os.Stat(restarted.targets[0].queue.path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("the queue file is still there: %v", err)
+	}
+
+	again := &Scrobbler{reports: make(chan Report, reportBuffer)}
+	addTarget(t, again, fake.target("session-1"))
+
+	if again.targets[0].queue.len() != 0 {
+		t.Fatalf("%d scrobbles came back after they were submitted", again.targets[0].queue.len())
+	}
+}
+
+func TestQueuesArePerTarget(t *testing.T) {
+	lastfm := newFakeLastFM(t)
+	lastfm.answer("track.scrobble", lastFMFailure(11))
+
+	working := newFakeLastFM(t)
+
+	// both speak the last.fm api, like libre.fm does
+	other := working.target("session-2")
+	scrobbler := newTestScrobbler(t, lastfm.target("session-1"), renamed{other, "libre.fm"})
+
+	queueScrobbles(t, scrobbler, testScrobbles(5))
+	scrobbler.flush()
+
+	if scrobbler.targets[0].queue.len() != 5 || scrobbler.targets[1].queue.len() != 0 {
+		t.Fatalf("queued %d and %d", scrobbler.targets[0].queue.len(), scrobbler.targets[1].queue.len())
+	}
+
+	if scrobbler.targets[0].queue.path == scrobbler.targets[1].queue.path {
+		t.Fatal("both targets share a queue file")
+	}
+}
+
+func TestEligible(t *testing.T) {
+	tests := []struct {
+		duration time.Duration
+		listened time.Duration
+		eligible bool
+	}{
+		{20 * time.Second, 20 * time.Second, false},
+		{3 * time.Minute, 80 * time.Second, false},
+		{3 * time.Minute, 90 * time.Second, true},
+		{20 * time.Minute, 4 * time.Minute, true},
+		{0, 3 * time.Minute, false},
+		{0, 4 * time.Minute, true},
+	}
+
+	for _, test := range tests {
+		if Eligible(test.duration, test.listened) != test.eligible {
+			t.Errorf("%s of %s eligible: %t", test.listened, test.duration, !test.eligible)
+		}
+	}
+}
+
+type renamed struct {
+	Target
+	name string
+}
+
+func (target renamed) Name() string {
+	return target.name
+}
+
+func sameScrobble(a Scrobble, b Scrobble) bool {
+	return a.Artist == b.Artist && a.Title == b.Title && a.Album == b.Album && a.AlbumArtist == b.AlbumArtist &&
+		a.TrackNumber == b.TrackNumber && a.Duration == b.Duration && a.Timestamp.Equal(b.Timestamp)
+}
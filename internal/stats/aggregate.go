@@ -0,0 +1,178 @@
+package stats
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+const (
+	// a play counts towards the top lists once it was heard this long, or to its end
+	countedListened = 30 * time.Second
+	// skips shorter than this were accidental and are left out of the reports altogether
+	minimumListened = 5 * time.Second
+	topSize         = 10
+)
+
+type Period int
+
+const (
+	Week Period = iota
+	Month
+	AllTime
+)
+
+var Periods = []Period{Week, Month, AllTime}
+
+func (period Period) String() string {
+	switch period {
+	case Week:
+		return "last 7 days"
+	case Month:
+		return "last 30 days"
+	default:
+		return "all time"
+	}
+}
+
+// since returns the start of the period, the zero time for AllTime
+func (period Period) since(now time.Time) time.Time {
+	switch period {
+	case Week:
+		return now.AddDate(0, 0, -7)
+	case Month:
+		return now.AddDate(0, 0, -30)
+	default:
+		return time.Time{}
+	}
+}
+
+type Count struct {
+	Name     string
+	Detail   string // the artist of an album or a track
+	Plays    int
+	Listened time.Duration
+}
+
+type Summary struct {
+	Plays    int
+	Listened time.Duration
+	Artists  []Count
+	Albums   []Count
+	Tracks   []Count
+}
+
+type Report struct {
+	Periods map[Period]Summary
+	// listening time by weekday (time.Sunday first) and hour of the day, over the whole history
+	Heatmap [7][24]time.Duration
+}
+
+func Aggregate(plays []Play, now time.Time) Report {
+	report := Report{Periods: map[Period]Summary{}}
+
+	plays = slices.DeleteFunc(slices.Clone(plays), accidental)
+
+	for _, period := range Periods {
+		report.Periods[period] = summarize(plays, period.since(now))
+	}
+
+	for _, play := range plays {
+		spreadOverHours(&report.Heatmap, play)
+	}
+
+	return report
+}
+
+func summarize(plays []Play, since time.Time) Summary {
+	var summary Summary
+
+	artists := map[string]*Count{}
+	albums := map[string]*Count{}
+	tracks := map[string]*Count{}
+
+	add := func(counts map[string]*Count, key string, name string, detail string, play Play, counted bool) {
+		count, ok := counts[key]
+		if !ok {
+			count = &Count{Name: name, Detail: detail}
+			counts[key] = count
+		}
+
+		count.Listened += play.Listened
+		if counted {
+			count.Plays++
+		}
+	}
+
+	for _, play := range plays {
+		if play.Started.Before(since) {
+			continue
+		}
+
+		counted := play.Completed || play.Listened >= countedListened
+
+		summary.Listened += play.Listened
+		if counted {
+			summary.Plays++
+		}
+
+		add(artists, play.Artist, play.Artist, "", play, counted)
+		add(albums, play.Artist+"\x00"+play.Album, play.Album, play.Artist, play, counted)
+		add(tracks, play.Path, play.Title, play.Artist, play, counted)
+	}
+
+	summary.Artists = top(artists)
+	summary.Albums = top(albums)
+	summary.Tracks = top(tracks)
+
+	return summary
+}
+
+func accidental(play Play) bool {
+	return !play.Completed && play.Listened < minimumListened
+}
+
+// top sorts by plays, then by time listened, keeping the first topSize entries
+func top(counts map[string]*Count) []Count {
+	sorted := make([]Count, 0, len(counts))
+	for _, count := range counts {
+		if count.Plays > 0 {
+			sorted = append(sorted, *count)
+		}
+	}
+
+	slices.SortFunc(sorted, func(a Count, b Count) int {
+		if a.Plays != b.Plays {
+			return cmp.Compare(b.Plays, a.Plays)
+		}
+
+		if a.Listened != b.Listened {
+			return cmp.Compare(b.Listened, a.Listened)
+		}
+
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	return sorted[:min(len(sorted), topSize)]
+}
+
+// spreadOverHours splits a play over the hours it actually covered, long albums cross hour boundaries
+func spreadOverHours(heatmap *[7][24]time.Duration, play Play) {
+	start := play.Started.Local()
+	remaining := play.Listened
+
+	for remaining > 0 {
+		// built from the local clock since Truncate works on absolute time, which is off in zones like +05:30
+		hourEnd := time.Date(start.Year(), start.Month(), start.Day(), start.Hour()+1, 0, 0, 0, start.Location())
+		if !hourEnd.After(start) {
+			hourEnd = start.Add(time.Hour)
+		}
+
+		chunk := min(remaining, hourEnd.Sub(start))
+
+		heatmap[start.Weekday()][start.Hour()] += chunk
+
+		remaining -= chunk
+		start = start.Add(chunk)
+	}
+}
@@ -0,0 +1,131 @@
+package stats
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestAggregateSkips(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		play     Play
+		plays    int
+		listened time.Duration
+	}{
+		// a track shorter than the thresholds still counts when it played through
+		{"short and completed", Play{Listened: 2 * time.Second, Completed: true}, 1, 2 * time.Second},
+		{"accidental skip", Play{Listened: minimumListened - time.Second}, 0, 0},
+		// heard long enough to be time listened, not long enough to be a play
+		{"skipped", Play{Listened: minimumListened}, 0, minimumListened},
+		{"skipped late", Play{Listened: countedListened}, 1, countedListened},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			play := test.play
+			play.Path, play.Title, play.Artist, play.Album = "/music/a.flac", "a", "artist", "album"
+			play.Started = now.Add(-time.Hour)
+
+			report := Aggregate([]Play{play}, now)
+
+			for _, period := range Periods {
+				summary := report.Periods[period]
+				if summary.Plays != test.plays || summary.Listened != test.listened {
+					t.Fatalf("%s has %d plays over %s, want %d over %s", period, summary.Plays, summary.Listened, test.plays, test.listened)
+				}
+
+				// only counted plays make the top lists
+				if len(summary.Tracks) != test.plays || len(summary.Artists) != test.plays || len(summary.Albums) != test.plays {
+					t.Fatalf("%s tops are %v, %v, %v", period, summary.Artists, summary.Albums, summary.Tracks)
+				}
+			}
+
+			var heatmap time.Duration
+			for _, hours := range report.Heatmap {
+				for _, listened := range hours {
+					heatmap += listened
+				}
+			}
+
+			if heatmap != test.listened {
+				t.Fatalf("the heatmap has %s, want %s", heatmap, test.listened)
+			}
+		})
+	}
+}
+
+func TestAggregateAcrossRestarts(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	now := time.Now()
+	play := func(path string, title string, ago time.Duration) Play {
+		return Play{Path: path, Title: title, Artist: "artist", Album: "album", Started: now.Add(-ago), Listened: time.Minute, Completed: true}
+	}
+
+	// each run appends to what the ones before it left
+	runs := [][]Play{
+		{play("/music/a.flac", "a", 40*24*time.Hour), play("/music/b.flac", "b", 10*24*time.Hour)},
+		{play("/music/a.flac", "a", 2*time.Hour)},
+		{play("/music/a.flac", "a", time.Hour), play("/music/b.flac", "b", time.Hour)},
+	}
+
+	var loaded []Play
+
+	for i, run := range runs {
+		for _, play := range run {
+			if err := appendPlay(play); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if i == 0 {
+			// and one got cut short by a crash
+			path, err := getHistoryPath()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, filePerm)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			file.WriteString(`{"path":"/music/c.fl`)
+			file.Close()
+		}
+
+		var err error
+		if loaded, err = loadHistory(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(loaded) != 5 {
+		t.Fatalf("loaded %d plays, want 5", len(loaded))
+	}
+
+	report := Aggregate(loaded, now)
+
+	want := map[Period][]Count{
+		Week:    {{Name: "a", Plays: 2}, {Name: "b", Plays: 1}},
+		Month:   {{Name: "a", Plays: 2}, {Name: "b", Plays: 2}},
+		AllTime: {{Name: "a", Plays: 3}, {Name: "b", Plays: 2}},
+	}
+
+	for period, tracks := range want {
+		summary := report.Periods[period]
+		if len(summary.Tracks) != len(tracks) {
+			t.Fatalf("%s tracks are %v", period, summary.Tracks)
+		}
+
+		for i, track := range tracks {
+			got := summary.Tracks[i]
+			if got.Name != track.Name || got.Plays != track.Plays || got.Listened != time.Duration(track.Plays)*time.Minute {
+				t.Fatalf("%s track %d is %+v, want %s played %d times", period, i, got, track.Name, track.Plays)
+			}
+		}
+	}
+}
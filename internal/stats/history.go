@@ -0,0 +1,141 @@
+// Package stats keeps a log of everything that was listened to and aggregates it into listening statistics
+package stats
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	dirPerm  = 0o755
+	filePerm = 0o644
+)
+
+// Play is a single entry of the history log
+type Play struct {
+	Path      string
+	Title     string
+	Artist    string
+	Album     string
+	Started   time.Time
+	Listened  time.Duration
+	Completed bool // false when the track was skipped or stopped before its end
+}
+
+type playLine struct {
+	Path      string  `json:"path"`
+	Title     string  `json:"title,omitempty"`
+	Artist    string  `json:"artist,omitempty"`
+	Album     string  `json:"album,omitempty"`
+	Started   int64   `json:"started"` // unix seconds
+	Seconds   float64 `json:"seconds"`
+	Completed bool    `json:"completed"`
+}
+
+// appendPlay adds a play at the end of the log, one json object per line so writes never rewrite the file
+func appendPlay(play Play) error {
+	path, err := getHistoryPath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), dirPerm); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(playLine{
+		Path:      play.Path,
+		Title:     play.Title,
+		Artist:    play.Artist,
+		Album:     play.Album,
+		Started:   play.Started.Unix(),
+		Seconds:   play.Listened.Seconds(),
+		Completed: play.Completed,
+	})
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, filePerm)
+	if err != nil {
+		return err
+	}
+
+	line := append(data, '\n')
+
+	// a line cut short by a crash would swallow this one too
+	if info, err := file.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := file.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			line = append([]byte{'\n'}, line...)
+		}
+	}
+
+	_, writeErr := file.Write(line)
+	closeErr := file.Close()
+
+	return errors.Join(writeErr, closeErr)
+}
+
+// loadHistory reads the whole log, lines that can't be parsed (e.g. cut short by a crash) are skipped
+func loadHistory() ([]Play, error) {
+	path, err := getHistoryPath()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var plays []Play
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		var line playLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil || line.Path == "" {
+			continue
+		}
+
+		plays = append(plays, Play{
+			Path:      line.Path,
+			Title:     line.Title,
+			Artist:    line.Artist,
+			Album:     line.Album,
+			Started:   time.Unix(line.Started, 0),
+			Listened:  time.Duration(line.Seconds * float64(time.Second)),
+			Completed: line.Completed,
+		})
+	}
+
+	return plays, scanner.Err()
+}
+
+// the history isn't something that can be rebuilt like the library cache, so it goes to the state dir
+func getHistoryPath() (string, error) {
+	dir := os.Getenv("XDG_STATE_HOME")
+
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+
+		dir = filepath.Join(home, ".local", "state")
+	}
+
+	return filepath.Join(dir, "wired", "history.jsonl"), nil
+}
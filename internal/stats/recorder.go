@@ -0,0 +1,124 @@
+package stats
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	engine "wired/internal/engine"
+)
+
+// Update tells a frontend the history changed, or that it couldn't be written
+type Update struct {
+	Error error
+}
+
+// Recorder logs the tracks the engine plays into the history
+type Recorder struct {
+	plays   []Play
+	updates chan Update
+	stop    func()
+	// false when someone else writes the history and this only keeps up with it
+	writes bool
+	mutex  sync.Mutex
+}
+
+// subscriber is the engine, or a remote one when the ui is attached to a daemon
+type subscriber interface {
+	Subscribe() (<-chan engine.Event, func())
+}
+
+// Record loads the history and starts logging what the engine plays
+func Record(player subscriber) (*Recorder, error) {
+	return start(player, true)
+}
+
+// Follow loads the history and keeps up with what the engine plays without logging it,
+// for when the wired doing the playing records it already
+func Follow(player subscriber) (*Recorder, error) {
+	return start(player, false)
+}
+
+func start(player subscriber, writes bool) (*Recorder, error) {
+	plays, err := loadHistory()
+
+	events, stop := player.Subscribe()
+
+	recorder := &Recorder{
+		plays:   plays,
+		updates: make(chan Update, 1),
+		stop:    stop,
+		writes:  writes,
+	}
+
+	go recorder.run(events)
+
+	return recorder, err
+}
+
+// Updates is signaled after each logged play, only the latest update is kept when nobody listens
+func (recorder *Recorder) Updates() <-chan Update {
+	return recorder.updates
+}
+
+func (recorder *Recorder) run(events <-chan engine.Event) {
+	for event := range events {
+		// everything is logged, skips included, it's up to the reports what counts
+		if event.Type != engine.TrackEnded {
+			continue
+		}
+
+		play := Play{
+			Path:      event.Track.Path,
+			Title:     event.Track.Title,
+			Artist:    event.Track.Artist,
+			Album:     event.Track.Album,
+			Started:   event.Started,
+			Listened:  event.Listened,
+			Completed: event.Completed,
+		}
+
+		var err error
+		if recorder.writes {
+			err = appendPlay(play)
+		}
+
+		recorder.mutex.Lock()
+		recorder.plays = append(recorder.plays, play)
+		recorder.mutex.Unlock()
+
+		recorder.notify(Update{Error: err})
+	}
+}
+
+func (recorder *Recorder) notify(update Update) {
+	select {
+	case recorder.updates <- update:
+		return
+	default:
+	}
+
+	// drop the stale update so the newest one gets through
+	select {
+	case <-recorder.updates:
+	default:
+	}
+
+	select {
+	case recorder.updates <- update:
+	default:
+	}
+}
+
+// Report aggregates the whole history as of now
+func (recorder *Recorder) Report(now time.Time) Report {
+	recorder.mutex.Lock()
+	plays := slices.Clone(recorder.plays)
+	recorder.mutex.Unlock()
+
+	return Aggregate(plays, now)
+}
+
+func (recorder *Recorder) Close() {
+	recorder.stop()
+}
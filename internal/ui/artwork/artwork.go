@@ -0,0 +1,197 @@
+// Package artwork draws album covers inside the terminal, either with unicode half blocks
+// or with the kitty/sixel graphics protocols when the terminal supports them
+package artwork
+
+import (
+	"hash/fnv"
+	"os"
+	"strings"
+
+	bubbletea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+
+	config "wired/internal/config"
+)
+
+type Protocol int
+
+const (
+	None Protocol = iota
+	HalfBlocks
+	Kitty
+	Sixel
+)
+
+// scaled pictures kept around, a handful of panel sizes per cover is plenty
+const cacheSize = 32
+
+var protocolNames = map[string]Protocol{
+	"none":       None,
+	"halfblocks": HalfBlocks,
+	"kitty":      Kitty,
+	"sixel":      Sixel,
+}
+
+// Capabilities is what the terminal answered when it was queried on startup
+type Capabilities struct {
+	Kitty      bool
+	Sixel      bool
+	CellWidth  int
+	CellHeight int
+}
+
+// Picture is a cover already encoded for a given amount of cells
+type Picture struct {
+	Lines  []string
+	Width  int
+	Height int
+	// sixel data is drawn once all of the picture's lines have been printed
+	sixel []byte
+}
+
+type RenderedMsg struct {
+	key     cacheKey
+	picture *Picture
+}
+
+type cacheKey struct {
+	path     string
+	cols     int
+	rows     int
+	protocol Protocol
+}
+
+type cache struct {
+	entries map[cacheKey]*Picture
+	order   []cacheKey
+	pending map[cacheKey]bool
+	nextID  uint32
+}
+
+type Renderer struct {
+	capabilities Capabilities
+	protocol     Protocol
+	// shared between model copies, only ever touched from Update and View
+	cache *cache
+}
+
+func New(capabilities Capabilities) Renderer {
+	return Renderer{
+		capabilities: capabilities,
+		protocol:     pickProtocol("auto", capabilities),
+		cache: &cache{
+			entries: map[cacheKey]*Picture{},
+			pending: map[cacheKey]bool{},
+			// kitty image ids are global to the terminal, so try not to clash with other programs
+			nextID: uint32(os.Getpid()&0xffff) << 8,
+		},
+	}
+}
+
+func (renderer *Renderer) ApplyConfig(cfg *config.Config) {
+	renderer.protocol = pickProtocol(cfg.Covers.Protocol, renderer.capabilities)
+}
+
+func pickProtocol(name string, capabilities Capabilities) Protocol {
+	if protocol, ok := protocolNames[name]; ok {
+		return protocol
+	}
+
+	switch {
+	case capabilities.Kitty:
+		return Kitty
+	case capabilities.Sixel:
+		return Sixel
+	default:
+		return HalfBlocks
+	}
+}
+
+func (renderer Renderer) Enabled() bool {
+	return renderer.protocol != None
+}
+
+// Request returns a command scaling the cover for the given amount of cells,
+// or nil when it's already cached or being worked on
+func (renderer Renderer) Request(path string, cols int, rows int) bubbletea.Cmd {
+	if renderer.cache == nil || renderer.protocol == None || path == "" || cols <= 0 || rows <= 0 {
+		return nil
+	}
+
+	key := cacheKey{path: path, cols: cols, rows: rows, protocol: renderer.protocol}
+
+	if _, ok := renderer.cache.entries[key]; ok || renderer.cache.pending[key] {
+		return nil
+	}
+
+	renderer.cache.pending[key] = true
+	renderer.cache.nextID = (renderer.cache.nextID + 1) & 0xffffff
+
+	id := max(renderer.cache.nextID, 1)
+	capabilities := renderer.capabilities
+
+	return func() bubbletea.Msg {
+		// unreadable covers are simply not drawn
+		picture, _ := render(key, capabilities, id)
+		return RenderedMsg{key: key, picture: picture}
+	}
+}
+
+// Update stores a rendered picture, failures are cached too so they aren't retried every frame
+func (renderer Renderer) Update(msg RenderedMsg) {
+	if renderer.cache == nil {
+		return
+	}
+
+	delete(renderer.cache.pending, msg.key)
+
+	if _, ok := renderer.cache.entries[msg.key]; !ok {
+		renderer.cache.order = append(renderer.cache.order, msg.key)
+	}
+
+	renderer.cache.entries[msg.key] = msg.picture
+
+	for len(renderer.cache.order) > cacheSize {
+		delete(renderer.cache.entries, renderer.cache.order[0])
+		renderer.cache.order = renderer.cache.order[1:]
+	}
+}
+
+func (renderer Renderer) Get(path string, cols int, rows int) (*Picture, bool) {
+	if renderer.cache == nil {
+		return nil, false
+	}
+
+	picture := renderer.cache.entries[cacheKey{path: path, cols: cols, rows: rows, protocol: renderer.protocol}]
+
+	return picture, picture != nil
+}
+
+// View returns the picture's lines, surrounding is whatever shares those lines on screen.
+// Sixels are plain pixels that get wiped by any text printed over them, so the sequence
+// is signed with the surroundings to make the renderer repaint it when they change
+func (picture *Picture) View(surrounding string) string {
+	if picture.sixel == nil {
+		return strings.Join(picture.Lines, "\n")
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(surrounding))
+
+	lines := make([]string, len(picture.Lines))
+	copy(lines, picture.Lines)
+
+	// the third sixel parameter is ignored by terminals, which makes it a free spot for the signature
+	var draw strings.Builder
+	draw.WriteString(ansi.SaveCursor)
+	if picture.Height > 1 {
+		draw.WriteString(ansi.CursorUp(picture.Height - 1))
+	}
+	draw.WriteString(ansi.CursorBackward(picture.Width))
+	draw.WriteString(ansi.SixelGraphics(0, 1, int(hash.Sum32()%100000)+1, picture.sixel))
+	draw.WriteString(ansi.RestoreCursor)
+
+	lines[len(lines)-1] += draw.String()
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,96 @@
+package artwork
+
+import (
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/charmbracelet/x/term"
+)
+
+// most terminals answer within a few milliseconds, this only matters for the ones that never do
+const detectTimeout = 500 * time.Millisecond
+
+var (
+	kittyReplyPattern    = regexp.MustCompile(`\x1b_Gi=31;OK`)
+	cellSizeReplyPattern = regexp.MustCompile(`\x1b\[6;(\d+);(\d+)t`)
+	attributesPattern    = regexp.MustCompile(`\x1b\[\?([\d;]*)c`)
+)
+
+// Detect asks the terminal which graphics protocols it speaks and how big its cells are.
+// It must run before bubbletea takes over the terminal, otherwise the replies would show up as key presses
+func Detect() Capabilities {
+	capabilities := Capabilities{CellWidth: 10, CellHeight: 20}
+
+	// multiplexers don't forward graphics unless configured to, half blocks always work there
+	if os.Getenv("TMUX") != "" || strings.HasPrefix(os.Getenv("TERM"), "screen") {
+		return capabilities
+	}
+
+	capabilities.Kitty = os.Getenv("KITTY_WINDOW_ID") != "" ||
+		os.Getenv("TERM") == "xterm-kitty" ||
+		slices.Contains([]string{"ghostty", "WezTerm"}, os.Getenv("TERM_PROGRAM"))
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return capabilities
+	}
+	defer tty.Close()
+
+	// without deadlines a silent terminal would hang the startup
+	if err := tty.SetReadDeadline(time.Now().Add(detectTimeout)); err != nil {
+		return capabilities
+	}
+
+	state, err := term.MakeRaw(tty.Fd())
+	if err != nil {
+		return capabilities
+	}
+	defer term.Restore(tty.Fd(), state)
+
+	// every terminal answers the device attributes request, and does so in order,
+	// so once its reply is in there's nothing left to wait for
+	query := "\x1b_Gi=31,s=1,v=1,a=q,t=d,f=24;AAAA\x1b\\" +
+		ansi.WindowOp(ansi.RequestCellSizeWinOp) +
+		ansi.RequestPrimaryDeviceAttributes
+
+	if _, err := tty.WriteString(query); err != nil {
+		return capabilities
+	}
+
+	var reply []byte
+	buffer := make([]byte, 256)
+
+	for !attributesPattern.Match(reply) {
+		n, err := tty.Read(buffer)
+		reply = append(reply, buffer[:n]...)
+
+		if err != nil {
+			break
+		}
+	}
+
+	if kittyReplyPattern.Match(reply) {
+		capabilities.Kitty = true
+	}
+
+	if match := attributesPattern.FindSubmatch(reply); match != nil {
+		capabilities.Sixel = slices.Contains(strings.Split(string(match[1]), ";"), "4")
+	}
+
+	if match := cellSizeReplyPattern.FindSubmatch(reply); match != nil {
+		height, _ := strconv.Atoi(string(match[1]))
+		width, _ := strconv.Atoi(string(match[2]))
+
+		if width > 0 && height > 0 {
+			capabilities.CellWidth = width
+			capabilities.CellHeight = height
+		}
+	}
+
+	return capabilities
+}
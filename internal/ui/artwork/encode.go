@@ -0,0 +1,221 @@
+package artwork
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi/kitty"
+	"github.com/charmbracelet/x/ansi/sixel"
+)
+
+// kitty placeholders address rows and columns through a fixed table of diacritics
+const maxPlaceholderCells = 297
+
+func render(key cacheKey, capabilities Capabilities, id uint32) (*Picture, error) {
+	file, err := os.Open(key.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	source, _, err := image.Decode(file)
+	if err != nil {
+		return nil, err
+	}
+
+	cellWidth := max(capabilities.CellWidth, 1)
+	cellHeight := max(capabilities.CellHeight, 1)
+
+	cols, rows := fit(source.Bounds(), key.cols, key.rows, cellWidth, cellHeight)
+
+	switch key.protocol {
+	case Kitty:
+		cols, rows = min(cols, maxPlaceholderCells), min(rows, maxPlaceholderCells)
+		return renderKitty(source, cols, rows, cellWidth, cellHeight, id)
+	case Sixel:
+		return renderSixel(source, cols, rows, cellWidth, cellHeight)
+	default:
+		return renderHalfBlocks(source, cols, rows), nil
+	}
+}
+
+// fit returns the biggest amount of cells within cols x rows keeping the image's aspect ratio
+func fit(bounds image.Rectangle, cols int, rows int, cellWidth int, cellHeight int) (int, int) {
+	width := float64(bounds.Dx() * cellHeight)
+	height := float64(bounds.Dy() * cellWidth)
+
+	if width <= 0 || height <= 0 {
+		return 1, 1
+	}
+
+	fitCols := float64(cols)
+	fitRows := fitCols * height / width
+
+	if fitRows > float64(rows) {
+		fitRows = float64(rows)
+		fitCols = fitRows * width / height
+	}
+
+	return max(int(fitCols+0.5), 1), max(int(fitRows+0.5), 1)
+}
+
+// scale box filters the image down to width x height, or stretches it when it's smaller
+func scale(source image.Image, width int, height int) *image.RGBA {
+	bounds := source.Bounds()
+
+	// draw has fast paths for the usual jpeg/png color models, unlike calling At per pixel
+	flat := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(flat, flat.Bounds(), source, bounds.Min, draw.Src)
+
+	sourceWidth, sourceHeight := bounds.Dx(), bounds.Dy()
+	scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := range height {
+		y0 := y * sourceHeight / height
+		y1 := max((y+1)*sourceHeight/height, y0+1)
+
+		for x := range width {
+			x0 := x * sourceWidth / width
+			x1 := max((x+1)*sourceWidth/width, x0+1)
+
+			var r, g, b, a, count int
+
+			for sy := y0; sy < y1; sy++ {
+				row := flat.Pix[sy*flat.Stride:]
+
+				for sx := x0; sx < x1; sx++ {
+					pixel := row[sx*4 : sx*4+4]
+					r += int(pixel[0])
+					g += int(pixel[1])
+					b += int(pixel[2])
+					a += int(pixel[3])
+					count++
+				}
+			}
+
+			offset := y*scaled.Stride + x*4
+			scaled.Pix[offset] = uint8(r / count)
+			scaled.Pix[offset+1] = uint8(g / count)
+			scaled.Pix[offset+2] = uint8(b / count)
+			scaled.Pix[offset+3] = uint8(a / count)
+		}
+	}
+
+	return scaled
+}
+
+// renderHalfBlocks draws two pixels per cell, the upper half block takes the top one
+// as its foreground and the background shows through as the bottom one
+func renderHalfBlocks(source image.Image, cols int, rows int) *Picture {
+	scaled := scale(source, cols, rows*2)
+	lines := make([]string, rows)
+
+	for row := range rows {
+		var line strings.Builder
+		var lastTop, lastBottom string
+
+		for col := range cols {
+			top := truecolor(scaled, col, row*2)
+			bottom := truecolor(scaled, col, row*2+1)
+
+			if top != lastTop {
+				line.WriteString("\x1b[38;2;" + top + "m")
+				lastTop = top
+			}
+
+			if bottom != lastBottom {
+				line.WriteString("\x1b[48;2;" + bottom + "m")
+				lastBottom = bottom
+			}
+
+			line.WriteString("▀")
+		}
+
+		line.WriteString("\x1b[0m")
+		lines[row] = line.String()
+	}
+
+	return &Picture{Lines: lines, Width: cols, Height: rows}
+}
+
+func truecolor(img *image.RGBA, x int, y int) string {
+	offset := y*img.Stride + x*4
+	return fmt.Sprintf("%d;%d;%d", img.Pix[offset], img.Pix[offset+1], img.Pix[offset+2])
+}
+
+// renderKitty transmits the image once as a virtual placement, the cells are then filled
+// with unicode placeholders whose foreground color carries the image id, which lets the
+// picture flow through the usual line based rendering like any other text
+func renderKitty(source image.Image, cols int, rows int, cellWidth int, cellHeight int, id uint32) (*Picture, error) {
+	width, height := cols*cellWidth, rows*cellHeight
+
+	if bounds := source.Bounds(); bounds.Dx() > width || bounds.Dy() > height {
+		source = scale(source, width, height)
+	}
+
+	var transmit bytes.Buffer
+
+	err := kitty.EncodeGraphics(&transmit, source, &kitty.Options{
+		Action:           kitty.TransmitAndPut,
+		Transmission:     kitty.Direct,
+		Format:           kitty.PNG,
+		ID:               int(id),
+		Quite:            2,
+		Chunk:            true,
+		VirtualPlacement: true,
+		Columns:          cols,
+		Rows:             rows,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	color := fmt.Sprintf("\x1b[38;2;%d;%d;%dm", id>>16&0xff, id>>8&0xff, id&0xff)
+	lines := make([]string, rows)
+
+	for row := range rows {
+		var line strings.Builder
+
+		if row == 0 {
+			line.Write(transmit.Bytes())
+		}
+
+		line.WriteString(color)
+
+		for col := range cols {
+			line.WriteRune(kitty.Placeholder)
+			line.WriteRune(kitty.Diacritic(row))
+			line.WriteRune(kitty.Diacritic(col))
+		}
+
+		line.WriteString("\x1b[39m")
+		lines[row] = line.String()
+	}
+
+	return &Picture{Lines: lines, Width: cols, Height: rows}, nil
+}
+
+// renderSixel reserves the cells with blanks, the pixels are drawn over them in Picture.View
+func renderSixel(source image.Image, cols int, rows int, cellWidth int, cellHeight int) (*Picture, error) {
+	scaled := scale(source, cols*cellWidth, rows*cellHeight)
+
+	var data bytes.Buffer
+
+	encoder := sixel.Encoder{}
+	if err := encoder.Encode(&data, scaled); err != nil {
+		return nil, err
+	}
+
+	lines := make([]string, rows)
+	for row := range rows {
+		lines[row] = strings.Repeat(" ", cols)
+	}
+
+	return &Picture{Lines: lines, Width: cols, Height: rows, sixel: data.Bytes()}, nil
+}
@@ -0,0 +1,472 @@
+// Package browser implements the Library panel, three Miller columns going from artists to albums to songs
+package browser
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	bubbletea "github.com/charmbracelet/bubbletea"
+	lipgloss "github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+
+	config "wired/internal/config"
+	library "wired/internal/library"
+)
+
+type Column int
+
+const (
+	Artists Column = iota
+	Albums
+	Songs
+)
+
+const columnCount = 3
+
+// QueueMsg asks for the songs under the cursor to be queued, right after the current track when Next is set
+type QueueMsg struct {
+	Songs []*library.Song
+	Next  bool
+}
+
+// PlayMsg asks for a song to be played right away
+type PlayMsg struct {
+	Song *library.Song
+}
+
+type Style struct {
+	BorderColor  lipgloss.Color
+	CursorFg     lipgloss.Color
+	InactiveText lipgloss.Color
+}
+
+func defaultStyle() Style {
+	return Style{
+		BorderColor:  lipgloss.Color("#6f3d49"),
+		CursorFg:     lipgloss.Color("#965363"),
+		InactiveText: lipgloss.Color("#44262d"),
+	}
+}
+
+type Browser struct {
+	library *library.Library
+	artists []string
+	focus   Column
+	cursors [columnCount]int
+	offsets [columnCount]int
+	// cursors left behind in the albums and songs columns, keyed by artist and by album
+	albumPositions map[string]int
+	songPositions  map[string]int
+	width          int
+	height         int
+	style          Style
+	keybinds       config.KeybindMapping
+}
+
+func New() Browser {
+	return Browser{
+		albumPositions: map[string]int{},
+		songPositions:  map[string]int{},
+		style:          defaultStyle(),
+	}
+}
+
+func (browser *Browser) ApplyConfig(cfg *config.Config) {
+	browser.keybinds = cfg.Keybinds
+	browser.style = Style{
+		BorderColor:  lipgloss.Color(cfg.Colors.Border),
+		CursorFg:     lipgloss.Color(cfg.Colors.CursorForeground),
+		InactiveText: lipgloss.Color(cfg.Colors.TextInactive),
+	}
+}
+
+func (browser *Browser) SetSize(width int, height int) {
+	browser.width = width
+	browser.height = height
+
+	for column := range columnCount {
+		browser.scroll(Column(column))
+	}
+}
+
+// SetLibrary swaps the browsed library, keeping the cursor on the same artist when it's still there
+func (browser *Browser) SetLibrary(lib *library.Library) {
+	current := browser.artistName()
+
+	browser.library = lib
+	browser.artists = nil
+
+	if lib != nil {
+		for name := range lib.Artists {
+			browser.artists = append(browser.artists, name)
+		}
+	}
+
+	slices.SortFunc(browser.artists, compareNames)
+
+	browser.cursors[Artists] = max(slices.Index(browser.artists, current), 0)
+	browser.restore()
+}
+
+func compareNames(a string, b string) int {
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+func (browser Browser) Focus() Column {
+	return browser.focus
+}
+
+func (browser Browser) artistName() string {
+	if browser.cursors[Artists] >= len(browser.artists) {
+		return ""
+	}
+
+	return browser.artists[browser.cursors[Artists]]
+}
+
+// Artist returns the artist under the cursor
+func (browser Browser) Artist() *library.Artist {
+	if browser.library == nil {
+		return nil
+	}
+
+	return browser.library.Artists[browser.artistName()]
+}
+
+func (browser Browser) albums() []*library.Album {
+	artist := browser.Artist()
+	if artist == nil {
+		return nil
+	}
+
+	albums := slices.Clone(artist.Albums)
+	slices.SortFunc(albums, func(a *library.Album, b *library.Album) int {
+		return compareNames(a.AlbumName, b.AlbumName)
+	})
+
+	return albums
+}
+
+// Album returns the album under the cursor, even while the artists column is focused
+func (browser Browser) Album() *library.Album {
+	albums := browser.albums()
+	if browser.cursors[Albums] >= len(albums) {
+		return nil
+	}
+
+	return albums[browser.cursors[Albums]]
+}
+
+// Song returns the song under the cursor
+func (browser Browser) Song() *library.Song {
+	album := browser.Album()
+	if album == nil || browser.cursors[Songs] >= len(album.Songs) {
+		return nil
+	}
+
+	return album.Songs[browser.cursors[Songs]]
+}
+
+func albumKey(album *library.Album) string {
+	return album.ArtistName + "\x00" + album.AlbumName
+}
+
+func (browser Browser) length(column Column) int {
+	switch column {
+	case Artists:
+		return len(browser.artists)
+	case Albums:
+		return len(browser.albums())
+	default:
+		if album := browser.Album(); album != nil {
+			return len(album.Songs)
+		}
+
+		return 0
+	}
+}
+
+// restore brings back the albums and songs cursors remembered for the current artist and album
+func (browser *Browser) restore() {
+	browser.cursors[Artists] = min(browser.cursors[Artists], max(browser.length(Artists)-1, 0))
+	browser.cursors[Albums] = min(browser.albumPositions[browser.artistName()], max(browser.length(Albums)-1, 0))
+
+	browser.cursors[Songs] = 0
+	if album := browser.Album(); album != nil {
+		browser.cursors[Songs] = min(browser.songPositions[albumKey(album)], max(len(album.Songs)-1, 0))
+	}
+
+	for column := range columnCount {
+		browser.scroll(Column(column))
+	}
+}
+
+func (browser *Browser) remember() {
+	browser.albumPositions[browser.artistName()] = browser.cursors[Albums]
+
+	if album := browser.Album(); album != nil {
+		browser.songPositions[albumKey(album)] = browser.cursors[Songs]
+	}
+}
+
+// Reveal puts the cursors on the song and focuses the songs column, e.g. for a search result
+func (browser *Browser) Reveal(song *library.Song) {
+	artist := slices.Index(browser.artists, song.Metadata.ArtistName)
+	if artist < 0 {
+		return
+	}
+
+	browser.remember()
+	browser.cursors[Artists] = artist
+
+	album := slices.IndexFunc(browser.albums(), func(album *library.Album) bool {
+		return album.AlbumName == song.Metadata.AlbumName
+	})
+	if album < 0 {
+		browser.restore()
+		return
+	}
+
+	browser.albumPositions[browser.artistName()] = album
+
+	if found := browser.library.Album(song); found != nil {
+		browser.songPositions[albumKey(found)] = max(slices.Index(found.Songs, song), 0)
+	}
+
+	browser.restore()
+	browser.focus = Songs
+}
+
+func (browser *Browser) move(delta int) {
+	column := browser.focus
+	length := browser.length(column)
+
+	if length == 0 {
+		return
+	}
+
+	cursor := max(0, min(length-1, browser.cursors[column]+delta))
+
+	switch column {
+	case Artists:
+		browser.remember()
+		browser.cursors[Artists] = cursor
+		browser.restore()
+
+	case Albums:
+		browser.remember()
+		browser.albumPositions[browser.artistName()] = cursor
+		browser.restore()
+
+	case Songs:
+		browser.cursors[Songs] = cursor
+		browser.remember()
+		browser.scroll(Songs)
+	}
+}
+
+// scroll keeps the cursor of the column inside the visible rows
+func (browser *Browser) scroll(column Column) {
+	rows := max(browser.height, 1)
+	cursor := browser.cursors[column]
+
+	offset := browser.offsets[column]
+	offset = min(offset, cursor)
+	offset = max(offset, cursor-rows+1)
+	offset = max(0, min(offset, browser.length(column)-rows))
+
+	browser.offsets[column] = offset
+}
+
+func (browser *Browser) Update(msg bubbletea.Msg) bubbletea.Cmd {
+	keyMsg, ok := msg.(bubbletea.KeyMsg)
+	if !ok {
+		return nil
+	}
+
+	switch browser.keybinds.Action(config.LibraryKeybinds, keyMsg.String()) {
+	case "move_down":
+		browser.move(1)
+
+	case "move_up":
+		browser.move(-1)
+
+	case "move_top":
+		browser.move(-browser.length(browser.focus))
+
+	case "move_bottom":
+		browser.move(browser.length(browser.focus))
+
+	case "center":
+		column := browser.focus
+		browser.offsets[column] = browser.cursors[column] - max(browser.height, 1)/2
+		browser.scroll(column)
+
+	case "move_left":
+		browser.focus = max(browser.focus-1, Artists)
+
+	case "select":
+		if browser.focus == Songs {
+			if song := browser.Song(); song != nil {
+				return func() bubbletea.Msg { return PlayMsg{Song: song} }
+			}
+
+			return nil
+		}
+
+		if browser.length(browser.focus+1) > 0 {
+			browser.focus++
+		}
+
+	case "queue_append":
+		return browser.queue(false)
+
+	case "queue_insert_next":
+		return browser.queue(true)
+	}
+
+	return nil
+}
+
+// queue sends the songs of whatever is under the cursor of the focused column
+func (browser Browser) queue(next bool) bubbletea.Cmd {
+	var songs []*library.Song
+
+	switch browser.focus {
+	case Artists:
+		for _, album := range browser.albums() {
+			songs = append(songs, album.Songs...)
+		}
+
+	case Albums:
+		if album := browser.Album(); album != nil {
+			songs = slices.Clone(album.Songs)
+		}
+
+	case Songs:
+		if song := browser.Song(); song != nil {
+			songs = []*library.Song{song}
+		}
+	}
+
+	if len(songs) == 0 {
+		return nil
+	}
+
+	return func() bubbletea.Msg { return QueueMsg{Songs: songs, Next: next} }
+}
+
+func (browser Browser) View() string {
+	if len(browser.artists) == 0 {
+		return lipgloss.NewStyle().Foreground(browser.style.InactiveText).Render("your library is empty")
+	}
+
+	// songs get the most room since their lines are the longest
+	separator := lipgloss.NewStyle().Foreground(browser.style.BorderColor).Render(" │ ")
+	available := max(browser.width-2*lipgloss.Width(separator), columnCount)
+
+	widths := [columnCount]int{available / 4, available / 4, 0}
+	widths[Songs] = available - widths[Artists] - widths[Albums]
+
+	columns := make([]string, 0, columnCount*2-1)
+
+	for column := range columnCount {
+		if column > 0 {
+			columns = append(columns, strings.TrimSuffix(strings.Repeat(separator+"\n", max(browser.height, 1)), "\n"))
+		}
+
+		columns = append(columns, browser.viewColumn(Column(column), widths[column]))
+	}
+
+	return lipgloss.JoinHorizontal(lipgloss.Top, columns...)
+}
+
+func (browser Browser) viewColumn(column Column, width int) string {
+	labels := browser.labels(column)
+	rows := max(browser.height, 1)
+
+	itemStyle := lipgloss.NewStyle().Width(width)
+	if column != browser.focus {
+		itemStyle = itemStyle.Foreground(browser.style.InactiveText)
+	}
+
+	cursorStyle := itemStyle.Foreground(browser.style.CursorFg).Bold(column == browser.focus)
+
+	lines := make([]string, 0, rows)
+
+	for index := browser.offsets[column]; index < len(labels) && len(lines) < rows; index++ {
+		label := ansi.Truncate(labels[index], width, "…")
+
+		if index == browser.cursors[column] {
+			lines = append(lines, cursorStyle.Render(label))
+		} else {
+			lines = append(lines, itemStyle.Render(label))
+		}
+	}
+
+	for len(lines) < rows {
+		lines = append(lines, strings.Repeat(" ", width))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (browser Browser) labels(column Column) []string {
+	var labels []string
+
+	switch column {
+	case Artists:
+		labels = slices.Clone(browser.artists)
+
+	case Albums:
+		for _, album := range browser.albums() {
+			label := album.AlbumName
+			if year := albumYear(album); year > 0 {
+				label = fmt.Sprintf("%s (%d)", label, year)
+			}
+
+			labels = append(labels, label)
+		}
+
+	case Songs:
+		album := browser.Album()
+		if album == nil {
+			return nil
+		}
+
+		for _, song := range album.Songs {
+			labels = append(labels, songLabel(song))
+		}
+	}
+
+	return labels
+}
+
+func albumYear(album *library.Album) int {
+	for _, song := range album.Songs {
+		if song.Metadata.Year > 0 {
+			return song.Metadata.Year
+		}
+	}
+
+	return 0
+}
+
+func songLabel(song *library.Song) string {
+	title := song.Metadata.SongName
+	if title == "" {
+		title = song.FileName
+	}
+
+	if song.Metadata.TrackNumber > 0 {
+		title = fmt.Sprintf("%02d. %s", song.Metadata.TrackNumber, title)
+	}
+
+	if song.Metadata.Duration > 0 {
+		seconds := int(song.Metadata.Duration.Seconds())
+		title = fmt.Sprintf("%s (%d:%02d)", title, seconds/60, seconds%60)
+	}
+
+	return title
+}
@@ -0,0 +1,214 @@
+// Package commandline implements the ex-style command bar that takes the footer's place while typing
+package commandline
+
+import (
+	"fmt"
+	"slices"
+
+	textinput "github.com/charmbracelet/bubbles/textinput"
+	bubbletea "github.com/charmbracelet/bubbletea"
+	lipgloss "github.com/charmbracelet/lipgloss"
+
+	config "wired/internal/config"
+	modal "wired/internal/ui/modal"
+)
+
+// lines kept for browsing back with the history keys
+const historyLimit = 100
+
+// SubmitMsg carries the typed line, without the colon
+type SubmitMsg struct {
+	Line string
+}
+
+// CompleteMsg asks for the completions of the line, answered with SetCompletions
+type CompleteMsg struct {
+	Line string
+}
+
+type CommandLine struct {
+	input   textinput.Model
+	history []string
+	// where in the history the input is, len(history) while typing a new line
+	position int
+	// what was being typed before going through the history
+	draft string
+	// completions for the line as it was when tab was first pressed, tab again cycles through them
+	completions []string
+	completion  int
+	visible     bool
+	width       int
+	style       modal.Style
+	keybinds    config.KeybindMapping
+}
+
+func New() CommandLine {
+	input := textinput.New()
+	input.Prompt = ":"
+
+	return CommandLine{input: input}
+}
+
+func (commandLine *CommandLine) ApplyConfig(cfg *config.Config) {
+	commandLine.keybinds = cfg.Keybinds
+	commandLine.input.CharLimit = cfg.InputCharLimit
+	commandLine.style = modal.Style{
+		BorderColor:  lipgloss.Color(cfg.Colors.Border),
+		CursorFg:     lipgloss.Color(cfg.Colors.CursorForeground),
+		InactiveText: lipgloss.Color(cfg.Colors.FooterHintFg),
+	}
+
+	modal.StyleInput(&commandLine.input, commandLine.style)
+	commandLine.input.PromptStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(cfg.Colors.FooterBarFg))
+	commandLine.input.TextStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(cfg.Colors.FooterBarFg))
+}
+
+func (commandLine *CommandLine) SetWidth(width int) {
+	commandLine.width = width
+	// room is left for the padding and the completion counter
+	commandLine.input.Width = max(width-lipgloss.Width(commandLine.input.Prompt)-12, 1)
+}
+
+func (commandLine *CommandLine) Show() bubbletea.Cmd {
+	commandLine.visible = true
+	commandLine.position = len(commandLine.history)
+	commandLine.draft = ""
+	commandLine.completions = nil
+	commandLine.input.SetValue("")
+
+	return commandLine.input.Focus()
+}
+
+func (commandLine *CommandLine) Hide() {
+	commandLine.visible = false
+	commandLine.input.Blur()
+}
+
+func (commandLine CommandLine) Visible() bool {
+	return commandLine.visible
+}
+
+// SetCompletions fills the line with the first of them, the rest come with the next tabs
+func (commandLine *CommandLine) SetCompletions(completions []string) {
+	if len(completions) == 0 {
+		commandLine.completions = nil
+		return
+	}
+
+	commandLine.completions = completions
+	commandLine.completion = 0
+	commandLine.setValue(completions[0])
+}
+
+func (commandLine *CommandLine) setValue(value string) {
+	commandLine.input.SetValue(value)
+	commandLine.input.CursorEnd()
+}
+
+func (commandLine *CommandLine) remember(line string) {
+	if line == "" {
+		return
+	}
+
+	// like vim, running a line again moves it to the end instead of repeating it
+	commandLine.history = slices.DeleteFunc(commandLine.history, func(previous string) bool {
+		return previous == line
+	})
+	commandLine.history = append(commandLine.history, line)
+
+	if len(commandLine.history) > historyLimit {
+		commandLine.history = commandLine.history[len(commandLine.history)-historyLimit:]
+	}
+}
+
+func (commandLine *CommandLine) browse(delta int) {
+	position := max(0, min(len(commandLine.history), commandLine.position+delta))
+	if position == commandLine.position {
+		return
+	}
+
+	if commandLine.position == len(commandLine.history) {
+		commandLine.draft = commandLine.input.Value()
+	}
+
+	commandLine.position = position
+
+	if position == len(commandLine.history) {
+		commandLine.setValue(commandLine.draft)
+	} else {
+		commandLine.setValue(commandLine.history[position])
+	}
+}
+
+func (commandLine *CommandLine) Update(msg bubbletea.Msg) bubbletea.Cmd {
+	if !commandLine.visible {
+		return nil
+	}
+
+	if keyMsg, ok := msg.(bubbletea.KeyMsg); ok {
+		key := keyMsg.String()
+		action := commandLine.keybinds.Action(config.CommandLineKeybinds, key)
+
+		if action != "command_complete" {
+			commandLine.completions = nil
+		}
+
+		switch {
+		case action == "cancel":
+			commandLine.Hide()
+			return nil
+
+		case action == "command_submit":
+			line := commandLine.input.Value()
+			commandLine.remember(line)
+			commandLine.Hide()
+
+			return func() bubbletea.Msg { return SubmitMsg{Line: line} }
+
+		case action == "command_history_previous":
+			commandLine.browse(-1)
+			return nil
+
+		case action == "command_history_next":
+			commandLine.browse(1)
+			return nil
+
+		case action == "command_complete":
+			if len(commandLine.completions) > 0 {
+				commandLine.completion = (commandLine.completion + 1) % len(commandLine.completions)
+				commandLine.setValue(commandLine.completions[commandLine.completion])
+
+				return nil
+			}
+
+			line := commandLine.input.Value()
+
+			return func() bubbletea.Msg { return CompleteMsg{Line: line} }
+
+		case key == "backspace" && commandLine.input.Value() == "":
+			// backspacing past the colon leaves, as in vim
+			commandLine.Hide()
+			return nil
+		}
+	}
+
+	var cmd bubbletea.Cmd
+	commandLine.input, cmd = commandLine.input.Update(msg)
+
+	return cmd
+}
+
+func (commandLine CommandLine) View() string {
+	if !commandLine.visible {
+		return ""
+	}
+
+	view := commandLine.input.View()
+
+	if len(commandLine.completions) > 1 {
+		hint := fmt.Sprintf("(%d/%d)", commandLine.completion+1, len(commandLine.completions))
+		view += " " + lipgloss.NewStyle().Foreground(commandLine.style.InactiveText).Render(hint)
+	}
+
+	return lipgloss.NewStyle().PaddingLeft(1).Render(view)
+}
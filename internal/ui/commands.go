@@ -0,0 +1,588 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	bubbletea "github.com/charmbracelet/bubbletea"
+
+	config "wired/internal/config"
+	engine "wired/internal/engine"
+	library "wired/internal/library"
+	playback "wired/internal/playback"
+	header "wired/internal/ui/header"
+	modal "wired/internal/ui/modal"
+	notification "wired/internal/ui/notification"
+)
+
+var (
+	errUnknownCommand = errors.New("not a command")
+	errUnknownOption  = errors.New("unknown option")
+	errUnbalanced     = errors.New("unbalanced quotes")
+	errNothingFound   = errors.New("nothing in the library by that name")
+)
+
+// command is something that can be typed after a colon, keybinds run commands too
+type command struct {
+	name    string
+	aliases []string
+	usage   string
+	run     func(model *Model, args []string) (bubbletea.Cmd, error)
+	// complete offers values for the argument being typed, args are the ones before it
+	complete func(model *Model, args []string) []string
+}
+
+var commands = []command{
+	{
+		name:    "quit",
+		aliases: []string{"q", "qa"},
+		usage:   "quit",
+		run: func(model *Model, args []string) (bubbletea.Cmd, error) {
+			return bubbletea.Quit, nil
+		},
+	},
+	{
+		name:  "scan",
+		usage: "scan",
+		run: func(model *Model, args []string) (bubbletea.Cmd, error) {
+			return model.scan(), nil
+		},
+	},
+	{
+		name:  "view",
+		usage: "view library|playlist|statistics",
+		run: func(model *Model, args []string) (bubbletea.Cmd, error) {
+			if len(args) != 1 {
+				return nil, errUsage
+			}
+
+			switch args[0] {
+			case "library":
+				model.Header.SetActive(header.Library)
+			case "playlist":
+				model.Header.SetActive(header.Playlist)
+			case "statistics":
+				model.Header.SetActive(header.Statistics)
+				// the periods are relative to now, so the report goes stale on its own
+				model.Statistics.SetReport(model.Stats.Report(time.Now()))
+			default:
+				return nil, errUsage
+			}
+
+			return nil, nil
+		},
+		complete: func(model *Model, args []string) []string {
+			if len(args) > 0 {
+				return nil
+			}
+
+			return []string{"library", "playlist", "statistics"}
+		},
+	},
+	{
+		name:  "toggle",
+		usage: "toggle",
+		run: func(model *Model, args []string) (bubbletea.Cmd, error) {
+			return nil, model.Player.TogglePause()
+		},
+	},
+	{
+		name:  "next",
+		usage: "next",
+		run: func(model *Model, args []string) (bubbletea.Cmd, error) {
+			return nil, model.Player.Next()
+		},
+	},
+	{
+		name:    "previous",
+		aliases: []string{"prev"},
+		usage:   "previous",
+		run: func(model *Model, args []string) (bubbletea.Cmd, error) {
+			return nil, model.Player.Previous()
+		},
+	},
+	{
+		name:  "seek",
+		usage: "seek [+|-]seconds",
+		run: func(model *Model, args []string) (bubbletea.Cmd, error) {
+			if len(args) != 1 {
+				return nil, errUsage
+			}
+
+			seconds, err := strconv.ParseFloat(args[0], 64)
+			if err != nil {
+				return nil, errUsage
+			}
+
+			status := model.Player.Status()
+			if status.State == playback.Stopped {
+				return nil, nil
+			}
+
+			position := time.Duration(seconds * float64(time.Second))
+			if strings.HasPrefix(args[0], "+") || strings.HasPrefix(args[0], "-") {
+				position += status.Position
+			}
+
+			return nil, model.Player.Seek(position)
+		},
+	},
+	{
+		name:  "clear",
+		usage: "clear",
+		run: func(model *Model, args []string) (bubbletea.Cmd, error) {
+			return nil, model.Player.Clear()
+		},
+	},
+	{
+		name:  "search",
+		usage: "search",
+		run: func(model *Model, args []string) (bubbletea.Cmd, error) {
+			return model.Search.Show(), nil
+		},
+	},
+	{
+		name:  "open",
+		usage: "open [station|url]",
+		run: func(model *Model, args []string) (bubbletea.Cmd, error) {
+			if len(args) == 0 {
+				names := make([]string, len(model.Config.Stations))
+				for i, station := range model.Config.Stations {
+					names[i] = station.Name
+				}
+
+				cmd := model.GetUserInput(modal.StreamURL, "Station or stream url:", "https://")
+				model.Modal.SetSuggestions(names)
+
+				return cmd, nil
+			}
+
+			value := strings.Join(args, " ")
+
+			track, ok := model.streamTrack(value)
+			if !ok {
+				return nil, fmt.Errorf("%q is neither a station nor an http(s) url", value)
+			}
+
+			return playStreamCmd(model.Player, track), nil
+		},
+		complete: func(model *Model, args []string) []string {
+			if len(args) > 0 {
+				return nil
+			}
+
+			names := make([]string, len(model.Config.Stations))
+			for i, station := range model.Config.Stations {
+				names[i] = station.Name
+			}
+
+			return names
+		},
+	},
+	{
+		name:  "set",
+		usage: "set option[=value]",
+		run: func(model *Model, args []string) (bubbletea.Cmd, error) {
+			if len(args) != 1 {
+				return nil, errUsage
+			}
+
+			name, value, assign := strings.Cut(args[0], "=")
+
+			option, ok := options[name]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s", errUnknownOption, name)
+			}
+
+			if !assign {
+				model.EnqueueNotification(
+					fmt.Sprintf("%s=%s", name, option.get(model)),
+					notification.Info,
+					time.Second*time.Duration(model.Config.Notification.NotificationDurationSecs),
+				)
+
+				return nil, nil
+			}
+
+			return nil, option.set(model, value)
+		},
+		complete: func(model *Model, args []string) []string {
+			if len(args) > 0 {
+				return nil
+			}
+
+			names := make([]string, 0, len(options))
+			for name := range options {
+				names = append(names, name+"=")
+			}
+
+			return names
+		},
+	},
+	{
+		name:  "add",
+		usage: "add artist|album name",
+		run: func(model *Model, args []string) (bubbletea.Cmd, error) {
+			if len(args) < 2 {
+				return nil, errUsage
+			}
+
+			name := strings.Join(args[1:], " ")
+
+			var songs []*library.Song
+
+			switch args[0] {
+			case "artist":
+				songs = model.artistSongs(name)
+			case "album":
+				songs = model.albumSongs(name)
+			default:
+				return nil, errUsage
+			}
+
+			if len(songs) == 0 {
+				return nil, fmt.Errorf("%w: %s", errNothingFound, name)
+			}
+
+			model.queueSongs(songs, false)
+
+			return nil, nil
+		},
+		complete: func(model *Model, args []string) []string {
+			switch {
+			case len(args) == 0:
+				return []string{"artist", "album"}
+			case len(args) == 1 && args[0] == "artist":
+				return model.artistNames()
+			case len(args) == 1 && args[0] == "album":
+				return model.albumNames()
+			}
+
+			return nil
+		},
+	},
+	{
+		name:  "save",
+		usage: "save playlist name",
+		run: func(model *Model, args []string) (bubbletea.Cmd, error) {
+			if len(args) < 2 || args[0] != "playlist" {
+				return nil, errUsage
+			}
+
+			tracks, _ := model.Player.Queue()
+
+			path, err := engine.SavePlaylist(strings.Join(args[1:], " "), tracks)
+			if err != nil {
+				return nil, err
+			}
+
+			model.EnqueueNotification(
+				"playlist saved to "+path,
+				notification.Success,
+				time.Second*time.Duration(model.Config.Notification.NotificationDurationSecs),
+			)
+
+			return nil, nil
+		},
+		complete: func(model *Model, args []string) []string {
+			if len(args) > 0 {
+				return nil
+			}
+
+			return []string{"playlist"}
+		},
+	},
+	{
+		name:  "theme",
+		usage: "theme [name]",
+		run: func(model *Model, args []string) (bubbletea.Cmd, error) {
+			switch len(args) {
+			case 0:
+				// picks up edits to the theme file, the config itself is reloaded as it changes
+				if err := model.Config.SetTheme(model.Config.Theme); err != nil {
+					return nil, err
+				}
+
+			case 1:
+				// written to the config as well, the next reload would put the old theme back otherwise
+				if err := model.Config.SetAndSaveTheme(args[0]); err != nil {
+					return nil, err
+				}
+
+			default:
+				return nil, errUsage
+			}
+
+			model.applyConfig()
+
+			return model.requestCover(), nil
+		},
+		complete: func(model *Model, args []string) []string {
+			if len(args) > 0 {
+				return nil
+			}
+
+			return config.Themes()
+		},
+	},
+}
+
+// errUsage is replaced by the usage of whichever command returned it
+var errUsage = errors.New("usage")
+
+// options are what :set can read and change
+var options = map[string]struct {
+	get func(model *Model) string
+	set func(model *Model, value string) error
+}{
+	"volume": {
+		get: func(model *Model) string {
+			return strconv.Itoa(model.Player.Status().Volume)
+		},
+		set: func(model *Model, value string) error {
+			volume, err := strconv.Atoi(value)
+			if err != nil || volume < 0 || volume > 100 {
+				return fmt.Errorf("volume must be between 0 and 100, got %q", value)
+			}
+
+			model.Player.SetVolume(volume)
+
+			return nil
+		},
+	},
+}
+
+func findCommand(name string) (command, bool) {
+	for _, command := range commands {
+		if command.name == name || slices.Contains(command.aliases, name) {
+			return command, true
+		}
+	}
+
+	return command{}, false
+}
+
+// execute runs a command line, as typed after the colon or bound to a key
+func (model *Model) execute(line string) bubbletea.Cmd {
+	words, err := splitCommand(line)
+	if err != nil {
+		model.reportCommandError(err)
+		return nil
+	}
+
+	if len(words) == 0 {
+		return nil
+	}
+
+	command, ok := findCommand(words[0])
+	if !ok {
+		model.reportCommandError(fmt.Errorf("%w: %s", errUnknownCommand, words[0]))
+		return nil
+	}
+
+	cmd, err := command.run(model, words[1:])
+	if errors.Is(err, errUsage) {
+		err = fmt.Errorf("usage: :%s", command.usage)
+	} else if err != nil {
+		err = fmt.Errorf(":%s: %w", command.name, err)
+	}
+
+	model.reportCommandError(err)
+
+	return cmd
+}
+
+// reportCommandError tells what was wrong with a command line, which is the user's to fix
+// rather than something the engine ran into
+func (model *Model) reportCommandError(err error) {
+	if err == nil {
+		return
+	}
+
+	model.EnqueueNotification(
+		err.Error(),
+		notification.Error,
+		time.Second*time.Duration(model.Config.Notification.NotificationDurationSecs),
+	)
+}
+
+// complete returns the whole lines the one being typed could become
+func (model *Model) complete(line string) []string {
+	words, err := splitCommand(line)
+
+	// a name with spaces is still being typed inside its quotes
+	quoting := errors.Is(err, errUnbalanced)
+	if quoting {
+		words, err = splitCommand(line + `"`)
+	}
+
+	if err != nil {
+		return nil
+	}
+
+	// a trailing space means the next word was started but nothing typed yet
+	if len(words) == 0 || (strings.HasSuffix(line, " ") && !quoting) {
+		words = append(words, "")
+	}
+
+	typed := words[len(words)-1]
+
+	var candidates []string
+
+	if len(words) == 1 {
+		for _, command := range commands {
+			candidates = append(candidates, command.name)
+		}
+	} else if command, ok := findCommand(words[0]); ok && command.complete != nil {
+		candidates = command.complete(model, words[1:len(words)-1])
+	}
+
+	prefix := ""
+	for _, word := range words[:len(words)-1] {
+		prefix += quoteWord(word) + " "
+	}
+
+	var lines []string
+
+	for _, candidate := range candidates {
+		if strings.HasPrefix(strings.ToLower(candidate), strings.ToLower(typed)) {
+			lines = append(lines, prefix+quoteWord(candidate))
+		}
+	}
+
+	slices.Sort(lines)
+
+	return slices.Compact(lines)
+}
+
+// splitCommand splits a line into words, double quotes keep spaces in and backslashes escape
+func splitCommand(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+
+	quoted, started := false, false
+
+	for i := 0; i < len(line); i++ {
+		char := line[i]
+
+		switch {
+		case char == '\\' && i+1 < len(line):
+			i++
+			word.WriteByte(line[i])
+			started = true
+
+		case char == '"':
+			quoted = !quoted
+			started = true
+
+		case char == ' ' && !quoted:
+			if started {
+				words = append(words, word.String())
+				word.Reset()
+				started = false
+			}
+
+		default:
+			word.WriteByte(char)
+			started = true
+		}
+	}
+
+	if quoted {
+		return nil, errUnbalanced
+	}
+
+	if started {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
+
+func quoteWord(word string) string {
+	if !strings.ContainsAny(word, ` "\`) {
+		return word
+	}
+
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+	return `"` + replacer.Replace(word) + `"`
+}
+
+func (model *Model) artistNames() []string {
+	if model.Library == nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(model.Library.Artists))
+	for name := range model.Library.Artists {
+		names = append(names, name)
+	}
+
+	return names
+}
+
+func (model *Model) albumNames() []string {
+	if model.Library == nil {
+		return nil
+	}
+
+	var names []string
+
+	for _, artist := range model.Library.Artists {
+		for _, album := range artist.Albums {
+			names = append(names, album.AlbumName)
+		}
+	}
+
+	return names
+}
+
+// artistSongs finds the artist ignoring case, albums in the order the browser lists them
+func (model *Model) artistSongs(name string) []*library.Song {
+	if model.Library == nil {
+		return nil
+	}
+
+	var songs []*library.Song
+
+	for artistName, artist := range model.Library.Artists {
+		if !strings.EqualFold(artistName, name) {
+			continue
+		}
+
+		albums := slices.Clone(artist.Albums)
+		slices.SortFunc(albums, func(a *library.Album, b *library.Album) int {
+			return strings.Compare(strings.ToLower(a.AlbumName), strings.ToLower(b.AlbumName))
+		})
+
+		for _, album := range albums {
+			songs = append(songs, album.Songs...)
+		}
+	}
+
+	return songs
+}
+
+// albumSongs queues every album with that name, there's rarely more than one
+func (model *Model) albumSongs(name string) []*library.Song {
+	if model.Library == nil {
+		return nil
+	}
+
+	var songs []*library.Song
+
+	for _, artist := range model.Library.Artists {
+		for _, album := range artist.Albums {
+			if strings.EqualFold(album.AlbumName, name) {
+				songs = append(songs, album.Songs...)
+			}
+		}
+	}
+
+	return songs
+}
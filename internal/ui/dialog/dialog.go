@@ -1,4 +1,4 @@
-// Package dialog implements a centered text bubble for displaying information, config agnostic
+// Package dialog implements a centered text bubble for displaying information, it works before any config is loaded
 package dialog
 
 import (
@@ -7,6 +7,8 @@ import (
 	viewport "github.com/charmbracelet/bubbles/viewport"
 	bubbletea "github.com/charmbracelet/bubbletea"
 	lipgloss "github.com/charmbracelet/lipgloss"
+
+	config "wired/internal/config"
 )
 
 const (
@@ -14,11 +16,22 @@ const (
 	maxContentHeight = 20
 )
 
-var (
-	borderColor = lipgloss.Color("#6f3d49")
-	dimColor    = lipgloss.Color("#44262d")
-	accentColor = lipgloss.Color("#965363")
-)
+type Style struct {
+	BorderColor  lipgloss.Color
+	HeaderFg     lipgloss.Color
+	InactiveText lipgloss.Color
+}
+
+// the dialog is how config errors show up, so it takes the default theme's colors until there's a config
+func defaultStyle() Style {
+	palette := config.DefaultPalette()
+
+	return Style{
+		BorderColor:  lipgloss.Color(palette.Border),
+		HeaderFg:     lipgloss.Color(palette.CursorForeground),
+		InactiveText: lipgloss.Color(palette.TextInactive),
+	}
+}
 
 type Options struct {
 	Header string
@@ -34,11 +47,21 @@ type Dialog struct {
 	visible  bool
 	width    int
 	height   int
+	style    Style
 }
 
 func New() Dialog {
 	return Dialog{
 		viewport: viewport.New(0, 0),
+		style:    defaultStyle(),
+	}
+}
+
+func (dialog *Dialog) ApplyConfig(cfg *config.Config) {
+	dialog.style = Style{
+		BorderColor:  lipgloss.Color(cfg.Colors.Border),
+		HeaderFg:     lipgloss.Color(cfg.Colors.CursorForeground),
+		InactiveText: lipgloss.Color(cfg.Colors.TextInactive),
 	}
 }
 
@@ -80,15 +103,15 @@ func (dialog Dialog) View() string {
 
 	boxStyle := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(borderColor).
+		BorderForeground(dialog.style.BorderColor).
 		Padding(1, 2)
 
 	dimStyle := lipgloss.NewStyle().
-		Foreground(dimColor)
+		Foreground(dialog.style.InactiveText)
 
 	headerStyle := lipgloss.NewStyle().
 		Bold(true).
-		Foreground(accentColor)
+		Foreground(dialog.style.HeaderFg)
 
 	var sections []string
 
@@ -57,9 +57,12 @@ type Footer struct {
 	state    State
 	spinner  spinner.Model
 	content  Content
+	radio    string
 	width    int
 	style    Style
 	keybinds config.KeybindMapping
+	// keys typed so far of a sequence or count, like vim's showcmd
+	pending string
 }
 
 func New() Footer {
@@ -95,8 +98,28 @@ func (footer *Footer) SetState(state State) bubbletea.Cmd {
 	return nil
 }
 
-func (footer *Footer) SetScanState(current int, total int) {
-	footer.content.Message = fmt.Sprintf("%d/%d", current, total)
+func (footer *Footer) SetScanState(added int, updated int, removed int) {
+	footer.content.Message = fmt.Sprintf("+%d added ~%d updated -%d removed", added, updated, removed)
+}
+
+// SetRadio shows where the radio can be tuned in, an empty url hides it
+func (footer *Footer) SetRadio(url string, listeners int) {
+	if url == "" {
+		footer.radio = ""
+		return
+	}
+
+	noun := "listeners"
+	if listeners == 1 {
+		noun = "listener"
+	}
+
+	footer.radio = fmt.Sprintf("on air at %s · %d %s", url, listeners, noun)
+}
+
+// SetPending shows the keys typed towards a binding, an empty string hides them
+func (footer *Footer) SetPending(keys string) {
+	footer.pending = keys
 }
 
 func (footer *Footer) SetWidth(width int) {
@@ -181,6 +204,29 @@ func (footer Footer) View() string {
 		contentWidth += lipgloss.Width(sep) + lipgloss.Width(msgRendered)
 	}
 
+	// Pending keys
+	if footer.pending != "" {
+		pendingRendered := barStyle.Bold(true).Render(footer.pending)
+
+		parts = append(parts, sep)
+		parts = append(parts, pendingRendered)
+
+		contentWidth += lipgloss.Width(sep) + lipgloss.Width(pendingRendered)
+	}
+
+	// Radio
+	if footer.radio != "" {
+		radioRendered := barStyle.Render(footer.radio)
+
+		radioTotalWidth := lipgloss.Width(sep) + lipgloss.Width(radioRendered)
+
+		if contentWidth+radioTotalWidth <= innerWidth {
+			parts = append(parts, sep)
+			parts = append(parts, radioRendered)
+			contentWidth += radioTotalWidth
+		}
+	}
+
 	// Hint
 	if footer.content.Hint != "" {
 		hintRendered := hintStyle.Render(footer.content.Hint)
@@ -0,0 +1,186 @@
+package ui
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	bubbletea "github.com/charmbracelet/bubbletea"
+
+	config "wired/internal/config"
+	header "wired/internal/ui/header"
+)
+
+// counts past this are surely a typo, and would take a while to repeat
+const maxCount = 999
+
+// keySequence is what was typed so far of a binding longer than one key, and the count typed before it
+type keySequence struct {
+	keys  []string
+	count int
+	// bumped on every change so only the timeout of the latest key does anything
+	id int
+}
+
+// String is how the footer shows it, like vim's showcmd
+func (sequence keySequence) String() string {
+	var shown strings.Builder
+
+	if sequence.count > 0 {
+		shown.WriteString(strconv.Itoa(sequence.count))
+	}
+
+	for _, key := range sequence.keys {
+		shown.WriteString(key)
+	}
+
+	return shown.String()
+}
+
+// pressKey runs whatever the key completes, holding on to it while it could still become a sequence
+func (model *Model) pressKey(msg bubbletea.KeyMsg) bubbletea.Cmd {
+	key := msg.String()
+
+	// the spacebar comes through as a literal space
+	if key == " " {
+		key = "space"
+	}
+
+	sequences := model.Config.Keybinds.Sequences()
+	typed := append(slices.Clone(model.sequence.keys), key)
+	exact, longer := matchSequence(sequences, typed)
+
+	// digits count how many times the next binding runs, unless they're bound themselves
+	if len(model.sequence.keys) == 0 && !exact && !longer && isCountDigit(key, model.sequence.count) {
+		model.sequence.count = min(model.sequence.count*10+int(key[0]-'0'), maxCount)
+		return model.waitForSequence()
+	}
+
+	if longer {
+		model.sequence.keys = typed
+		return model.waitForSequence()
+	}
+
+	count := max(model.sequence.count, 1)
+	model.resetSequence()
+
+	// like vim, a sequence that leads nowhere is dropped
+	if len(typed) > 1 && !exact {
+		return nil
+	}
+
+	return model.runKeys(msg, typed, count)
+}
+
+// runKeys handles a complete binding count times, a sequence reaches the panels as a single key
+// named after all of its keys, e.g. "g g", which is how they're written in the config
+func (model *Model) runKeys(msg bubbletea.KeyMsg, keys []string, count int) bubbletea.Cmd {
+	key := strings.Join(keys, " ")
+	if len(keys) > 1 {
+		msg = bubbletea.KeyMsg{Type: bubbletea.KeyRunes, Runes: []rune(key)}
+	}
+
+	var cmds []bubbletea.Cmd
+
+	for range count {
+		cmds = append(cmds, model.handleKey(msg, key))
+
+		// the rest of the count would end up typed into whatever just opened
+		if model.Modal.Visible() || model.Search.Visible() || model.CommandLine.Visible() {
+			break
+		}
+	}
+
+	return bubbletea.Batch(cmds...)
+}
+
+// handleKey runs the global binding for key, or hands it to the active panel
+func (model *Model) handleKey(msg bubbletea.KeyMsg, key string) bubbletea.Cmd {
+	switch action := model.Config.Keybinds.Action(config.GlobalKeybinds, key); action {
+	case "":
+	case "command_line":
+		return model.CommandLine.Show()
+	default:
+		return model.execute(globalCommands[action])
+	}
+
+	switch model.Header.Active() {
+	case header.Library:
+		cmd := model.Browser.Update(msg)
+		return bubbletea.Batch(cmd, model.requestCover())
+
+	case header.Playlist:
+		return model.Playlist.Update(msg)
+
+	case header.Statistics:
+		return model.Statistics.Update(msg)
+	}
+
+	return nil
+}
+
+// waitForSequence shows what's pending and gives up on it after the timeout
+func (model *Model) waitForSequence() bubbletea.Cmd {
+	model.sequence.id++
+	model.Footer.SetPending(model.sequence.String())
+
+	id := model.sequence.id
+	timeout := time.Duration(model.Config.KeySequenceTimeoutMs) * time.Millisecond
+
+	return bubbletea.Tick(timeout, func(time.Time) bubbletea.Msg {
+		return KeySequenceTimeoutMsg{ID: id}
+	})
+}
+
+// timeoutSequence runs what was typed when it's a binding by itself, e.g. "g" while "g g" exists too
+func (model *Model) timeoutSequence(id int) bubbletea.Cmd {
+	if id != model.sequence.id {
+		return nil
+	}
+
+	keys := model.sequence.keys
+	count := max(model.sequence.count, 1)
+	model.resetSequence()
+
+	if exact, _ := matchSequence(model.Config.Keybinds.Sequences(), keys); !exact || len(keys) == 0 {
+		return nil
+	}
+
+	msg := bubbletea.KeyMsg{Type: bubbletea.KeyRunes, Runes: []rune(keys[0])}
+
+	return model.runKeys(msg, keys, count)
+}
+
+func (model *Model) resetSequence() {
+	model.sequence = keySequence{id: model.sequence.id + 1}
+	model.Footer.SetPending("")
+}
+
+// matchSequence tells whether typed is bound, and whether it's the start of a longer binding
+func matchSequence(sequences [][]string, typed []string) (bool, bool) {
+	exact, longer := false, false
+
+	for _, sequence := range sequences {
+		if len(sequence) < len(typed) || !slices.Equal(sequence[:len(typed)], typed) {
+			continue
+		}
+
+		if len(sequence) == len(typed) {
+			exact = true
+		} else {
+			longer = true
+		}
+	}
+
+	return exact, longer
+}
+
+// a count can't start with 0, which vim keeps for going to the start of the line
+func isCountDigit(key string, count int) bool {
+	if len(key) != 1 || key[0] < '0' || key[0] > '9' {
+		return false
+	}
+
+	return key[0] != '0' || count > 0
+}
@@ -5,7 +5,11 @@ import (
 	"time"
 
 	"wired/internal/config"
+	"wired/internal/engine"
 	"wired/internal/library"
+	"wired/internal/radio"
+	"wired/internal/scrobbler"
+	"wired/internal/stats"
 )
 
 type LoadConfigMsg struct {
@@ -18,27 +22,75 @@ type HeartbeatMsg time.Time
 
 type FileScanningState struct {
 	Total           int
-	Current         int
-	ProgressChannel <-chan int
+	Progress        library.ScanProgress
+	ProgressChannel <-chan library.ScanProgress
 	ResultChannel   <-chan library.FileScanningResult
 	CancelContext   context.CancelFunc
 }
 
 type ScanStartMsg struct {
 	Total           int
-	ProgressChannel <-chan int
+	ProgressChannel <-chan library.ScanProgress
 	ResultChannel   <-chan library.FileScanningResult
 }
 
 type ScanProgressMsg struct {
-	Current int
+	Progress library.ScanProgress
 }
 
 type ScanCompleteMsg struct {
-	Library *library.Library
-	Error   error
+	Library  *library.Library
+	Progress library.ScanProgress
+	Error    error
 }
 
 type LoadLibraryMsg struct {
 	Library *library.Library
 }
+
+type WatcherStartMsg struct {
+	Watcher *library.Watcher
+	Error   error
+}
+
+type ConfigWatcherStartMsg struct {
+	Watcher *config.Watcher
+	Error   error
+}
+
+// ConfigReloadMsg carries the config file as it was after being edited
+type ConfigReloadMsg struct {
+	Event config.WatchEvent
+}
+
+type LibraryWatchMsg struct {
+	Event library.WatchEvent
+}
+
+type EngineEventMsg struct {
+	Event engine.Event
+}
+
+// EngineGoneMsg is sent when the daemon the ui was attached to went away
+type EngineGoneMsg struct{}
+
+// KeySequenceTimeoutMsg gives up on a half typed key sequence, unless more keys came since
+type KeySequenceTimeoutMsg struct {
+	ID int
+}
+
+type StatsUpdateMsg struct {
+	Update stats.Update
+}
+
+type StreamOpenedMsg struct {
+	Error error
+}
+
+type RadioUpdateMsg struct {
+	Update radio.Update
+}
+
+type ScrobbleReportMsg struct {
+	Report scrobbler.Report
+}
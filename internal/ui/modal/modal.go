@@ -2,8 +2,6 @@
 package modal
 
 import (
-	"slices"
-
 	textinput "github.com/charmbracelet/bubbles/textinput"
 	bubbletea "github.com/charmbracelet/bubbletea"
 	lipgloss "github.com/charmbracelet/lipgloss"
@@ -15,6 +13,7 @@ type Type int
 
 const (
 	MusicPath Type = iota
+	StreamURL
 )
 
 type SubmitMsg struct {
@@ -65,11 +64,19 @@ func (modal *Modal) Show(promptType Type, title string, placeholder string, char
 	modal.input.Placeholder = placeholder
 	modal.input.CharLimit = charLimit
 	modal.input.SetValue("")
+	modal.input.SetSuggestions(nil)
+	modal.input.ShowSuggestions = false
 	modal.visible = true
 
 	return modal.input.Focus()
 }
 
+// SetSuggestions offers completions for the current prompt, accepted with tab
+func (modal *Modal) SetSuggestions(suggestions []string) {
+	modal.input.SetSuggestions(suggestions)
+	modal.input.ShowSuggestions = len(suggestions) > 0
+}
+
 func (modal *Modal) Hide() {
 	modal.visible = false
 	modal.input.Blur()
@@ -94,9 +101,23 @@ func (modal *Modal) ApplyConfig(cfg *config.Config) {
 		InactiveText: lipgloss.Color(cfg.Colors.TextInactive),
 	}
 
-	modal.input.Cursor.Style = lipgloss.NewStyle().Foreground(modal.style.CursorFg)
-	modal.input.PlaceholderStyle = lipgloss.NewStyle().Foreground(modal.style.InactiveText)
-	modal.input.PromptStyle = lipgloss.NewStyle().Foreground(modal.style.InactiveText)
+	StyleInput(&modal.input, modal.style)
+}
+
+// StyleInput dresses a text input like the modal's, for other overlays that take typing
+func StyleInput(input *textinput.Model, style Style) {
+	input.Cursor.Style = lipgloss.NewStyle().Foreground(style.CursorFg)
+	input.PlaceholderStyle = lipgloss.NewStyle().Foreground(style.InactiveText)
+	input.PromptStyle = lipgloss.NewStyle().Foreground(style.InactiveText)
+	input.CompletionStyle = lipgloss.NewStyle().Foreground(style.InactiveText)
+}
+
+// BoxStyle is the border every overlay is drawn in
+func BoxStyle(style Style) lipgloss.Style {
+	return lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(style.BorderColor).
+		Padding(1, 2)
 }
 
 func (modal *Modal) Update(msg bubbletea.Msg) bubbletea.Cmd {
@@ -106,11 +127,11 @@ func (modal *Modal) Update(msg bubbletea.Msg) bubbletea.Cmd {
 
 	switch msg := msg.(type) {
 	case bubbletea.KeyMsg:
-		key := msg.String()
+		action := modal.keybinds.Action(config.PromptKeybinds, msg.String())
 		promptType := modal.promptType
 
 		// Confirm input
-		if slices.Contains(modal.keybinds.Select, key) {
+		if action == "prompt_submit" {
 			value := modal.input.Value()
 			modal.Hide()
 
@@ -118,7 +139,7 @@ func (modal *Modal) Update(msg bubbletea.Msg) bubbletea.Cmd {
 		}
 
 		// Leave input screen
-		if slices.Contains(modal.keybinds.Cancel, key) {
+		if action == "cancel" {
 			modal.Hide()
 
 			return func() bubbletea.Msg { return CancelMsg{Type: promptType} }
@@ -136,10 +157,7 @@ func (modal Modal) View() string {
 		return ""
 	}
 
-	boxStyle := lipgloss.NewStyle().
-		Border(lipgloss.RoundedBorder()).
-		BorderForeground(modal.style.BorderColor).
-		Padding(1, 2)
+	boxStyle := BoxStyle(modal.style)
 
 	titleStyle := lipgloss.NewStyle().
 		Bold(true).
@@ -1,31 +1,90 @@
 package ui
 
 import (
+	"strings"
 	"time"
 
 	bubbletea "github.com/charmbracelet/bubbletea"
 
 	config "wired/internal/config"
+	control "wired/internal/control"
+	engine "wired/internal/engine"
 	library "wired/internal/library"
+	mpd "wired/internal/mpd"
+	mpris "wired/internal/mpris"
+	playback "wired/internal/playback"
+	radio "wired/internal/radio"
+	scrobbler "wired/internal/scrobbler"
+	stats "wired/internal/stats"
+	artwork "wired/internal/ui/artwork"
+	browser "wired/internal/ui/browser"
+	commandline "wired/internal/ui/commandline"
 	dialog "wired/internal/ui/dialog"
 	footer "wired/internal/ui/footer"
 	header "wired/internal/ui/header"
 	modal "wired/internal/ui/modal"
 	notification "wired/internal/ui/notification"
+	playlist "wired/internal/ui/playlist"
+	search "wired/internal/ui/search"
+	statistics "wired/internal/ui/statistics"
 )
 
+// player is what the ui drives, the engine itself or the one of a daemon it's attached to
+type player interface {
+	Subscribe() (<-chan engine.Event, func())
+	Status() engine.Status
+	Queue() ([]engine.Track, int)
+	PlayIndex(index int) error
+	TogglePause() error
+	Next() error
+	Previous() error
+	Seek(position time.Duration) error
+	SetVolume(volume int)
+	Append(tracks ...engine.Track) error
+	InsertNext(tracks ...engine.Track) error
+	PlayNow(tracks ...engine.Track) error
+	Move(from int, to int) error
+	Remove(index int) error
+	Clear() error
+	Refresh(lib *library.Library)
+}
+
 type Model struct {
 	Config        *config.Config
 	FileScanState *FileScanningState
 	Library       *library.Library
+	Watcher       *library.Watcher
+	ConfigWatcher *config.Watcher
+	Player        player
+	// nil when attached to a daemon, everything that needs the engine itself runs over there
+	Engine        *engine.Engine
+	Stats         *stats.Recorder
+	Scrobbler     *scrobbler.Scrobbler
+	Radio         *radio.Station
+	MPRIS         *mpris.Server
+	MPD           *mpd.Server
+	Control       *control.Server
 	Errors        []error
 	Header        header.Header
 	Dialog        dialog.Dialog
 	Modal         modal.Modal
+	Search        search.Search
+	CommandLine   commandline.CommandLine
 	Notifications notification.NotificationStack
 	Footer        footer.Footer
+	Browser       browser.Browser
+	Playlist      playlist.Playlist
+	Statistics    statistics.Statistics
+	Artwork       artwork.Renderer
 	width         int
 	height        int
+	// watcher changes that arrived mid-scan, applied once the scan is done
+	watchBacklog []library.Change
+	engineEvents <-chan engine.Event
+	// problems found while starting up, reported once the config is loaded
+	startupErrors []error
+	// keys typed towards a longer binding or a count, waiting for the rest
+	sequence keySequence
 }
 
 func NewModel() Model {
@@ -33,8 +92,13 @@ func NewModel() Model {
 		Header:        header.New(),
 		Dialog:        dialog.New(),
 		Modal:         modal.New(),
+		Search:        search.New(),
+		CommandLine:   commandline.New(),
 		Notifications: notification.New(),
 		Footer:        footer.New(),
+		Browser:       browser.New(),
+		Playlist:      playlist.New(),
+		Statistics:    statistics.New(),
 	}
 }
 
@@ -58,6 +122,185 @@ func (model *Model) EnqueueNotification(
 	model.Notifications.Push(message, notificationType, duration)
 }
 
+// applyConfig hands the config to every component
+func (model *Model) applyConfig() {
+	model.Dialog.ApplyConfig(model.Config)
+	model.Modal.ApplyConfig(model.Config)
+	model.Search.ApplyConfig(model.Config)
+	model.CommandLine.ApplyConfig(model.Config)
+	model.Footer.ApplyConfig(model.Config)
+	model.Notifications.ApplyConfig(model.Config)
+	model.Header.ApplyConfig(model.Config)
+	model.Artwork.ApplyConfig(model.Config)
+	model.Browser.ApplyConfig(model.Config)
+	model.Browser.SetSize(model.browserSize(model.width, max(model.height-2, 0)))
+	model.Playlist.ApplyConfig(model.Config)
+	model.Statistics.ApplyConfig(model.Config)
+}
+
+func (model Model) scanOptions() library.ScanOptions {
+	return library.ScanOptions{CoverMinBytes: int64(model.Config.Covers.MinImageBytes)}
+}
+
+// coverSize is the room given to the cover of the selected album inside a panel of width x height cells
+func (model Model) coverSize(width int, height int) (int, int) {
+	if !model.Artwork.Enabled() {
+		return 0, height
+	}
+
+	return width / 3, height
+}
+
+// browserSize is what's left of the Library panel once the cover has its share
+func (model Model) browserSize(width int, height int) (int, int) {
+	cols, _ := model.coverSize(width, height)
+	if cols == 0 {
+		return width, height
+	}
+
+	return max(width-cols-1, 0), height
+}
+
+func (model Model) requestCover() bubbletea.Cmd {
+	album := model.Browser.Album()
+	if album == nil {
+		return nil
+	}
+
+	cols, rows := model.coverSize(model.width, max(model.height-2, 0))
+
+	return model.Artwork.Request(album.CoverImage, cols, rows)
+}
+
+// tracksFromSongs snapshots songs for the play queue
+func (model Model) tracksFromSongs(songs []*library.Song) []engine.Track {
+	tracks := make([]engine.Track, len(songs))
+
+	for i, song := range songs {
+		var album *library.Album
+		if model.Library != nil {
+			album = model.Library.Album(song)
+		}
+
+		tracks[i] = engine.NewTrack(song, album)
+	}
+
+	return tracks
+}
+
+// streamTrack resolves what was typed in the stream prompt, a station name or a url
+func (model Model) streamTrack(value string) (engine.Track, bool) {
+	value = strings.TrimSpace(value)
+
+	for _, station := range model.Config.Stations {
+		if strings.EqualFold(station.Name, value) {
+			return engine.NewStreamTrack(station.URL, station.Name), true
+		}
+	}
+
+	if !playback.IsStream(value) {
+		return engine.Track{}, false
+	}
+
+	return engine.NewStreamTrack(value, ""), true
+}
+
+// refreshLibrary lets everything holding on to songs know the library changed
+func (model *Model) refreshLibrary() {
+	model.Player.Refresh(model.Library)
+	model.Search.SetLibrary(model.Library)
+
+	if model.MPD != nil {
+		model.MPD.SetLibrary(model.Library, model.Config.MusicLibraryPath)
+	}
+}
+
+// startScrobbler follows the engine for every scrobbling service that's enabled and authenticated
+func (model *Model) startScrobbler() bubbletea.Cmd {
+	targets, errs := scrobbler.Targets(model.Config)
+
+	for _, err := range errs {
+		model.EnqueueNotification(
+			err.Error(),
+			notification.Error,
+			time.Second*time.Duration(model.Config.Notification.NotificationDurationSecs),
+		)
+	}
+
+	if len(targets) == 0 {
+		return nil
+	}
+
+	model.Scrobbler = scrobbler.Start(model.Engine, targets...)
+
+	return waitForScrobbleReport(model.Scrobbler)
+}
+
+// startRadio puts the radio on air when it's enabled
+func (model *Model) startRadio() bubbletea.Cmd {
+	if !model.Config.Radio.Enabled {
+		return nil
+	}
+
+	if err := model.Radio.Serve(model.Config.Radio, model.Config.Title, model.Engine); err != nil {
+		model.EnqueueNotification(
+			"couldn't start the radio: "+err.Error(),
+			notification.Error,
+			time.Second*time.Duration(model.Config.Notification.NotificationDurationSecs),
+		)
+
+		return nil
+	}
+
+	model.Footer.SetRadio(model.Radio.URL(), model.Radio.Listeners())
+
+	return waitForRadioUpdate(model.Radio)
+}
+
+// startMPRIS shows wired to the desktop, a missing session bus only matters if it was asked for
+func (model *Model) startMPRIS() {
+	if !model.Config.MPRIS.Enabled {
+		return
+	}
+
+	server, err := mpris.Serve(model.Engine)
+	if err != nil {
+		model.EnqueueNotification(
+			"couldn't register with the session bus: "+err.Error(),
+			notification.Error,
+			time.Second*time.Duration(model.Config.Notification.NotificationDurationSecs),
+		)
+
+		return
+	}
+
+	model.MPRIS = server
+}
+
+// startMPD lets mpd clients in when it's enabled
+func (model *Model) startMPD() {
+	if !model.Config.MPD.Enabled {
+		return
+	}
+
+	server, err := mpd.Listen(model.Config.MPD, model.Engine)
+	if err != nil {
+		model.EnqueueNotification(
+			"couldn't start the mpd server: "+err.Error(),
+			notification.Error,
+			time.Second*time.Duration(model.Config.Notification.NotificationDurationSecs),
+		)
+
+		return
+	}
+
+	model.MPD = server
+
+	if model.Library != nil {
+		model.MPD.SetLibrary(model.Library, model.Config.MusicLibraryPath)
+	}
+}
+
 func LoadLibraryCmd() bubbletea.Cmd {
 	return func() bubbletea.Msg {
 		return LoadLibraryMsg{Library: library.LoadLibrary()}
@@ -68,6 +311,8 @@ func (model Model) Init() bubbletea.Cmd {
 	return bubbletea.Batch(
 		bubbletea.SetWindowTitle("wire(d)"),
 		model.Footer.Init(),
+		waitForEngineEvent(model.engineEvents),
+		waitForStatsUpdate(model.Stats),
 		func() bubbletea.Msg {
 			return footer.StartCompleteMsg{}
 		},
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	lipgloss "github.com/charmbracelet/lipgloss"
+	termenv "github.com/muesli/termenv"
 
 	config "wired/internal/config"
 )
@@ -259,6 +260,11 @@ func wrapText(text string, width int, maxHeight int) []string {
 
 // TODO: do I really want to do it this way...
 func fadeColor(hex string, ratio float64) lipgloss.Color {
+	// ANSI colors have no hex of their own to fade, xterm's take on them is close enough
+	if index, err := strconv.Atoi(hex); err == nil && index >= 0 && index <= 255 {
+		hex = termenv.ConvertToRGB(termenv.ANSI256Color(index)).Hex()
+	}
+
 	if len(hex) > 0 && hex[0] == '#' {
 		hex = hex[1:]
 	}
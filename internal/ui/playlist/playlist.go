@@ -0,0 +1,236 @@
+// Package playlist implements the Playlist panel, a view over the play queue
+package playlist
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	bubbletea "github.com/charmbracelet/bubbletea"
+	lipgloss "github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+
+	config "wired/internal/config"
+	engine "wired/internal/engine"
+	playback "wired/internal/playback"
+)
+
+type PlayMsg struct {
+	Index int
+}
+
+type MoveMsg struct {
+	From int
+	To   int
+}
+
+type RemoveMsg struct {
+	Index int
+}
+
+type ClearMsg struct{}
+
+type Style struct {
+	CursorFg     lipgloss.Color
+	InactiveText lipgloss.Color
+	CurrentFg    lipgloss.Color
+}
+
+func defaultStyle() Style {
+	return Style{
+		CursorFg:     lipgloss.Color("#965363"),
+		InactiveText: lipgloss.Color("#44262d"),
+		CurrentFg:    lipgloss.Color("#539686"),
+	}
+}
+
+type Playlist struct {
+	tracks  []engine.Track
+	current int
+	state   playback.State
+	// what the current stream says it's playing
+	streamTitle string
+	cursor      int
+	offset      int
+	width       int
+	height      int
+	style       Style
+	keybinds    config.KeybindMapping
+}
+
+func New() Playlist {
+	return Playlist{
+		current: -1,
+		style:   defaultStyle(),
+	}
+}
+
+func (playlist *Playlist) ApplyConfig(cfg *config.Config) {
+	playlist.keybinds = cfg.Keybinds
+	playlist.style = Style{
+		CursorFg:     lipgloss.Color(cfg.Colors.CursorForeground),
+		InactiveText: lipgloss.Color(cfg.Colors.TextInactive),
+		CurrentFg:    lipgloss.Color(cfg.Colors.NotificationInfo),
+	}
+}
+
+func (playlist *Playlist) SetStreamTitle(title string) {
+	playlist.streamTitle = title
+}
+
+func (playlist *Playlist) SetSize(width int, height int) {
+	playlist.width = width
+	playlist.height = height
+	playlist.scroll()
+}
+
+func (playlist *Playlist) SetQueue(tracks []engine.Track, current int) {
+	playlist.tracks = tracks
+	playlist.current = current
+	playlist.cursor = max(0, min(playlist.cursor, len(tracks)-1))
+	playlist.scroll()
+}
+
+func (playlist *Playlist) SetState(state playback.State) {
+	playlist.state = state
+}
+
+func (playlist *Playlist) scroll() {
+	rows := max(playlist.height, 1)
+
+	playlist.offset = min(playlist.offset, playlist.cursor)
+	playlist.offset = max(playlist.offset, playlist.cursor-rows+1)
+	playlist.offset = max(0, min(playlist.offset, len(playlist.tracks)-rows))
+}
+
+func (playlist *Playlist) Update(msg bubbletea.Msg) bubbletea.Cmd {
+	keyMsg, ok := msg.(bubbletea.KeyMsg)
+	if !ok || len(playlist.tracks) == 0 {
+		return nil
+	}
+
+	cursor := playlist.cursor
+
+	switch playlist.keybinds.Action(config.PlaylistKeybinds, keyMsg.String()) {
+	case "move_down":
+		playlist.cursor = min(cursor+1, len(playlist.tracks)-1)
+
+	case "move_up":
+		playlist.cursor = max(cursor-1, 0)
+
+	case "move_top":
+		playlist.cursor = 0
+
+	case "move_bottom":
+		playlist.cursor = len(playlist.tracks) - 1
+
+	case "center":
+		playlist.offset = cursor - max(playlist.height, 1)/2
+
+	case "select":
+		return func() bubbletea.Msg { return PlayMsg{Index: cursor} }
+
+	case "queue_move_down":
+		if cursor+1 >= len(playlist.tracks) {
+			return nil
+		}
+
+		// the cursor goes along with the entry
+		playlist.cursor++
+		playlist.scroll()
+
+		return func() bubbletea.Msg { return MoveMsg{From: cursor, To: cursor + 1} }
+
+	case "queue_move_up":
+		if cursor == 0 {
+			return nil
+		}
+
+		playlist.cursor--
+		playlist.scroll()
+
+		return func() bubbletea.Msg { return MoveMsg{From: cursor, To: cursor - 1} }
+
+	case "queue_remove":
+		return func() bubbletea.Msg { return RemoveMsg{Index: cursor} }
+
+	case "queue_clear":
+		return func() bubbletea.Msg { return ClearMsg{} }
+	}
+
+	playlist.scroll()
+
+	return nil
+}
+
+func (playlist Playlist) View() string {
+	if len(playlist.tracks) == 0 {
+		return lipgloss.NewStyle().Foreground(playlist.style.InactiveText).Render("the queue is empty, add songs from the library")
+	}
+
+	rows := max(playlist.height, 1)
+	numberWidth := len(fmt.Sprint(len(playlist.tracks)))
+
+	itemStyle := lipgloss.NewStyle().Width(playlist.width)
+	cursorStyle := itemStyle.Foreground(playlist.style.CursorFg).Bold(true)
+	currentStyle := itemStyle.Foreground(playlist.style.CurrentFg)
+
+	lines := make([]string, 0, rows)
+
+	for index := playlist.offset; index < len(playlist.tracks) && len(lines) < rows; index++ {
+		track := playlist.tracks[index]
+
+		marker := "  "
+		if index == playlist.current {
+			marker = playlist.marker()
+		}
+
+		duration := formatDuration(track.Duration)
+		name := track.Title
+		if track.Artist != "" {
+			name = track.Artist + " - " + track.Title
+		}
+
+		if index == playlist.current && playlist.streamTitle != "" {
+			name += " · " + playlist.streamTitle
+		}
+
+		label := fmt.Sprintf("%s%*d  %s", marker, numberWidth, index+1, name)
+		label = ansi.Truncate(label, max(playlist.width-len(duration)-1, 0), "…")
+
+		padding := max(playlist.width-ansi.StringWidth(label)-len(duration), 1)
+		line := label + strings.Repeat(" ", padding) + duration
+
+		switch index {
+		case playlist.cursor:
+			lines = append(lines, cursorStyle.Render(line))
+		case playlist.current:
+			lines = append(lines, currentStyle.Render(line))
+		default:
+			lines = append(lines, itemStyle.Render(line))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (playlist Playlist) marker() string {
+	switch playlist.state {
+	case playback.Playing:
+		return "▶ "
+	case playback.Paused:
+		return "‖ "
+	default:
+		return "· "
+	}
+}
+
+func formatDuration(duration time.Duration) string {
+	if duration <= 0 {
+		return ""
+	}
+
+	seconds := int(duration.Seconds())
+
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
@@ -0,0 +1,264 @@
+// Package search implements the search overlay, narrowing the library down to songs as you type
+package search
+
+import (
+	"fmt"
+	"strings"
+
+	textinput "github.com/charmbracelet/bubbles/textinput"
+	bubbletea "github.com/charmbracelet/bubbletea"
+	lipgloss "github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+
+	config "wired/internal/config"
+	library "wired/internal/library"
+	modal "wired/internal/ui/modal"
+)
+
+// nobody scrolls further than this, and it keeps ranking big libraries quick
+const maxResults = 200
+
+// JumpMsg asks for the browser to be moved onto the song
+type JumpMsg struct {
+	Song *library.Song
+}
+
+// QueueMsg asks for the song to be queued, right after the current track when Next is set
+type QueueMsg struct {
+	Song *library.Song
+	Next bool
+}
+
+type Search struct {
+	input    textinput.Model
+	index    *library.SearchIndex
+	query    string
+	results  []library.SearchResult
+	cursor   int
+	offset   int
+	visible  bool
+	width    int
+	height   int
+	style    modal.Style
+	keybinds config.KeybindMapping
+}
+
+func New() Search {
+	input := textinput.New()
+	input.Placeholder = "title, artist or album"
+
+	return Search{
+		input: input,
+		index: library.NewSearchIndex(nil),
+	}
+}
+
+func (search *Search) ApplyConfig(cfg *config.Config) {
+	search.keybinds = cfg.Keybinds
+	search.input.CharLimit = cfg.InputCharLimit
+	search.style = modal.Style{
+		BorderColor:  lipgloss.Color(cfg.Colors.Border),
+		CursorFg:     lipgloss.Color(cfg.Colors.CursorForeground),
+		InactiveText: lipgloss.Color(cfg.Colors.TextInactive),
+	}
+
+	modal.StyleInput(&search.input, search.style)
+}
+
+func (search *Search) SetSize(width int, height int) {
+	search.width = width
+	search.height = height
+
+	// minus the padding, the prompt and the cursor
+	search.input.Width = max(search.boxWidth()-7, 1)
+	search.scroll()
+}
+
+// SetLibrary indexes the library again, what's typed so far is matched against the new one
+func (search *Search) SetLibrary(lib *library.Library) {
+	search.index = library.NewSearchIndex(lib)
+	search.filter(true)
+}
+
+func (search *Search) Show() bubbletea.Cmd {
+	search.visible = true
+	search.input.SetValue("")
+	search.filter(true)
+
+	return search.input.Focus()
+}
+
+func (search *Search) Hide() {
+	search.visible = false
+	search.input.Blur()
+}
+
+func (search Search) Visible() bool {
+	return search.visible
+}
+
+// filter reruns the query when it changed, or always when force is set
+func (search *Search) filter(force bool) {
+	query := search.input.Value()
+	if query == search.query && !force {
+		return
+	}
+
+	search.query = query
+	search.results = search.index.Search(query, maxResults)
+	search.cursor = 0
+	search.offset = 0
+}
+
+func (search Search) selected() *library.Song {
+	if search.cursor >= len(search.results) {
+		return nil
+	}
+
+	return search.results[search.cursor].Song
+}
+
+func (search *Search) Update(msg bubbletea.Msg) bubbletea.Cmd {
+	if !search.visible {
+		return nil
+	}
+
+	if keyMsg, ok := msg.(bubbletea.KeyMsg); ok {
+		switch search.keybinds.Action(config.SearchKeybinds, keyMsg.String()) {
+		case "cancel":
+			search.Hide()
+			return nil
+
+		case "search_down":
+			search.cursor = min(search.cursor+1, max(len(search.results)-1, 0))
+			search.scroll()
+
+			return nil
+
+		case "search_up":
+			search.cursor = max(search.cursor-1, 0)
+			search.scroll()
+
+			return nil
+
+		case "search_select":
+			song := search.selected()
+			if song == nil {
+				return nil
+			}
+
+			search.Hide()
+
+			return func() bubbletea.Msg { return JumpMsg{Song: song} }
+
+		case "search_queue":
+			return search.queue(false)
+
+		case "search_queue_next":
+			return search.queue(true)
+		}
+	}
+
+	var cmd bubbletea.Cmd
+	search.input, cmd = search.input.Update(msg)
+	search.filter(false)
+
+	return cmd
+}
+
+// queue leaves the overlay open, so a few songs can be picked in a row
+func (search Search) queue(next bool) bubbletea.Cmd {
+	song := search.selected()
+	if song == nil {
+		return nil
+	}
+
+	return func() bubbletea.Msg { return QueueMsg{Song: song, Next: next} }
+}
+
+func (search Search) boxWidth() int {
+	return min(search.width-4, 80)
+}
+
+// rows is how many results fit in the box under the title and the input
+func (search Search) rows() int {
+	// borders, padding, title, input and the blank lines between them
+	return max(search.height-10, 1)
+}
+
+func (search *Search) scroll() {
+	rows := search.rows()
+
+	search.offset = min(search.offset, search.cursor)
+	search.offset = max(search.offset, search.cursor-rows+1)
+	search.offset = max(0, min(search.offset, len(search.results)-rows))
+}
+
+func (search Search) View() string {
+	if !search.visible {
+		return ""
+	}
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(search.style.CursorFg)
+
+	inactiveStyle := lipgloss.NewStyle().Foreground(search.style.InactiveText)
+	width := max(search.boxWidth()-4, 1)
+
+	title := "Search"
+	if search.query != "" {
+		title = fmt.Sprintf("Search (%d)", len(search.results))
+	}
+
+	var lines []string
+
+	switch {
+	case search.query == "":
+		lines = append(lines, inactiveStyle.Render("start typing to search the library"))
+
+	case len(search.results) == 0:
+		lines = append(lines, inactiveStyle.Render("nothing matches"))
+
+	default:
+		cursorStyle := lipgloss.NewStyle().Foreground(search.style.CursorFg).Bold(true)
+
+		for index := search.offset; index < len(search.results) && len(lines) < search.rows(); index++ {
+			label := ansi.Truncate(resultLabel(search.results[index].Song), width, "…")
+
+			if index == search.cursor {
+				lines = append(lines, cursorStyle.Render(label))
+			} else {
+				lines = append(lines, label)
+			}
+		}
+	}
+
+	content := titleStyle.Render(title) + "\n\n" + search.input.View() + "\n\n" + strings.Join(lines, "\n")
+	box := modal.BoxStyle(search.style).Width(search.boxWidth()).Render(content)
+
+	return lipgloss.Place(search.width, search.height, lipgloss.Center, lipgloss.Center, box)
+}
+
+func resultLabel(song *library.Song) string {
+	title := song.Metadata.SongName
+	if title == "" {
+		title = song.FileName
+	}
+
+	var details []string
+
+	if song.Metadata.ArtistName != "" {
+		details = append(details, song.Metadata.ArtistName)
+	}
+
+	if song.Metadata.AlbumName != "" {
+		details = append(details, song.Metadata.AlbumName)
+	}
+
+	if len(details) == 0 {
+		return title
+	}
+
+	return title + " · " + strings.Join(details, " - ")
+}
@@ -0,0 +1,213 @@
+// Package statistics implements the Statistics panel, showing what was listened to and when
+package statistics
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	bubbletea "github.com/charmbracelet/bubbletea"
+	lipgloss "github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+
+	config "wired/internal/config"
+	stats "wired/internal/stats"
+)
+
+// heatmap cells from no listening at all to the busiest hour
+var shades = []string{"  ", "░░", "▒▒", "▓▓", "██"}
+
+// monday first, as most calendars outside the US do
+var weekdays = []time.Weekday{
+	time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday,
+}
+
+type Style struct {
+	BorderColor  lipgloss.Color
+	CursorFg     lipgloss.Color
+	InactiveText lipgloss.Color
+}
+
+func defaultStyle() Style {
+	return Style{
+		BorderColor:  lipgloss.Color("#6f3d49"),
+		CursorFg:     lipgloss.Color("#965363"),
+		InactiveText: lipgloss.Color("#44262d"),
+	}
+}
+
+type Statistics struct {
+	report   stats.Report
+	period   stats.Period
+	width    int
+	height   int
+	style    Style
+	keybinds config.KeybindMapping
+}
+
+func New() Statistics {
+	return Statistics{
+		period: stats.Week,
+		style:  defaultStyle(),
+	}
+}
+
+func (statistics *Statistics) ApplyConfig(cfg *config.Config) {
+	statistics.keybinds = cfg.Keybinds
+	statistics.style = Style{
+		BorderColor:  lipgloss.Color(cfg.Colors.Border),
+		CursorFg:     lipgloss.Color(cfg.Colors.CursorForeground),
+		InactiveText: lipgloss.Color(cfg.Colors.TextInactive),
+	}
+}
+
+func (statistics *Statistics) SetSize(width int, height int) {
+	statistics.width = width
+	statistics.height = height
+}
+
+func (statistics *Statistics) SetReport(report stats.Report) {
+	statistics.report = report
+}
+
+func (statistics *Statistics) Update(msg bubbletea.Msg) bubbletea.Cmd {
+	keyMsg, ok := msg.(bubbletea.KeyMsg)
+	if !ok {
+		return nil
+	}
+
+	index := slices.Index(stats.Periods, statistics.period)
+
+	switch statistics.keybinds.Action(config.StatisticsKeybinds, keyMsg.String()) {
+	case "move_left":
+		index = max(index-1, 0)
+	case "select":
+		index = min(index+1, len(stats.Periods)-1)
+	}
+
+	statistics.period = stats.Periods[index]
+
+	return nil
+}
+
+func (statistics Statistics) View() string {
+	summary := statistics.report.Periods[statistics.period]
+	inactive := lipgloss.NewStyle().Foreground(statistics.style.InactiveText)
+
+	sections := []string{
+		statistics.viewPeriods(),
+		inactive.Render(fmt.Sprintf("%d plays · %s listened", summary.Plays, formatListened(summary.Listened))),
+		"",
+	}
+
+	if summary.Plays == 0 {
+		sections = append(sections, inactive.Render("nothing was listened to in this period yet"))
+	} else {
+		columnWidth := max((statistics.width-4)/3, 10)
+		rows := 1 + max(len(summary.Artists), len(summary.Albums), len(summary.Tracks))
+		separator := lipgloss.NewStyle().Foreground(statistics.style.BorderColor).Render(" │ ")
+		separator = strings.TrimSuffix(strings.Repeat(separator+"\n", rows), "\n")
+
+		sections = append(sections, lipgloss.JoinHorizontal(
+			lipgloss.Top,
+			statistics.viewTop("top artists", summary.Artists, columnWidth),
+			separator,
+			statistics.viewTop("top albums", summary.Albums, columnWidth),
+			separator,
+			statistics.viewTop("top tracks", summary.Tracks, columnWidth),
+		))
+	}
+
+	sections = append(sections, "", statistics.viewHeatmap())
+
+	return strings.Join(sections, "\n")
+}
+
+func (statistics Statistics) viewPeriods() string {
+	labels := make([]string, len(stats.Periods))
+
+	for i, period := range stats.Periods {
+		style := lipgloss.NewStyle().Foreground(statistics.style.InactiveText)
+		if period == statistics.period {
+			style = lipgloss.NewStyle().Foreground(statistics.style.CursorFg).Bold(true)
+		}
+
+		labels[i] = style.Render(period.String())
+	}
+
+	return strings.Join(labels, "  ")
+}
+
+func (statistics Statistics) viewTop(title string, counts []stats.Count, width int) string {
+	lines := []string{lipgloss.NewStyle().Foreground(statistics.style.CursorFg).Bold(true).Render(title)}
+	detailStyle := lipgloss.NewStyle().Foreground(statistics.style.InactiveText)
+
+	for i, count := range counts {
+		label := fmt.Sprintf("%2d. %s", i+1, count.Name)
+		plays := fmt.Sprintf(" %d", count.Plays)
+
+		if count.Detail != "" {
+			label += detailStyle.Render(" - " + count.Detail)
+		}
+
+		label = ansi.Truncate(label, width-len(plays), "…")
+		padding := max(width-ansi.StringWidth(label)-len(plays), 0)
+
+		lines = append(lines, label+strings.Repeat(" ", padding)+plays)
+	}
+
+	return lipgloss.NewStyle().Width(width).Render(strings.Join(lines, "\n"))
+}
+
+func (statistics Statistics) viewHeatmap() string {
+	heatmap := statistics.report.Heatmap
+
+	var busiest time.Duration
+	for _, hours := range heatmap {
+		busiest = max(busiest, slices.Max(hours[:]))
+	}
+
+	title := lipgloss.NewStyle().Foreground(statistics.style.CursorFg).Bold(true).Render("listening by hour")
+	labelStyle := lipgloss.NewStyle().Foreground(statistics.style.InactiveText)
+	cellStyle := lipgloss.NewStyle().Foreground(statistics.style.CursorFg)
+
+	lines := []string{title}
+
+	for _, weekday := range weekdays {
+		var row strings.Builder
+		row.WriteString(labelStyle.Render(weekday.String()[:3] + " "))
+
+		for _, listened := range heatmap[weekday] {
+			shade := 0
+			if busiest > 0 && listened > 0 {
+				shade = 1 + int(float64(listened)/float64(busiest)*float64(len(shades)-2)+0.5)
+			}
+
+			row.WriteString(cellStyle.Render(shades[min(shade, len(shades)-1)]))
+		}
+
+		lines = append(lines, row.String())
+	}
+
+	var hours strings.Builder
+	hours.WriteString("    ")
+	for hour := 0; hour < 24; hour += 3 {
+		hours.WriteString(fmt.Sprintf("%-6d", hour))
+	}
+
+	lines = append(lines, labelStyle.Render(hours.String()))
+
+	return strings.Join(lines, "\n")
+}
+
+func formatListened(listened time.Duration) string {
+	hours := int(listened.Hours())
+	minutes := int(listened.Minutes()) % 60
+
+	if hours == 0 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+
+	return fmt.Sprintf("%dh %dm", hours, minutes)
+}
@@ -2,16 +2,108 @@
 package ui
 
 import (
+	"fmt"
+	"time"
+
 	bubbletea "github.com/charmbracelet/bubbletea"
 
 	cli "wired/internal/cli"
+	control "wired/internal/control"
+	engine "wired/internal/engine"
+	playback "wired/internal/playback"
+	radio "wired/internal/radio"
+	stats "wired/internal/stats"
+	artwork "wired/internal/ui/artwork"
 )
 
 func Start() error {
+	model := NewModel()
+	// has to happen before bubbletea starts reading the terminal
+	model.Artwork = artwork.New(artwork.Detect())
+
+	// with a daemon running the ui is only a remote for it, otherwise it plays by itself
+	if remote, err := control.Attach(); err == nil {
+		defer remote.Close()
+
+		model.Player = remote
+		model.Stats, err = stats.Follow(remote)
+		if err != nil {
+			model.startupErrors = append(model.startupErrors, fmt.Errorf("couldn't read the listening history: %w", err))
+		}
+	} else {
+		defer model.startEngine()()
+	}
+	defer model.Stats.Close()
+
+	model.engineEvents, _ = model.Player.Subscribe()
+	model.Playlist.SetQueue(model.Player.Queue())
+	model.Statistics.SetReport(model.Stats.Report(time.Now()))
+
 	cli.ClearScreen()
 
-	p := bubbletea.NewProgram(NewModel(), bubbletea.WithAltScreen())
-	_, err := p.Run()
+	p := bubbletea.NewProgram(model, bubbletea.WithAltScreen())
+	final, err := p.Run()
+
+	// these only start once the config is loaded, so they live on the final model
+	if final, ok := final.(Model); ok {
+		if final.Scrobbler != nil {
+			final.Scrobbler.Close()
+		}
+
+		if final.MPRIS != nil {
+			final.MPRIS.Close()
+		}
+
+		if final.MPD != nil {
+			final.MPD.Close()
+		}
+
+		if final.ConfigWatcher != nil {
+			final.ConfigWatcher.Close()
+		}
+
+		if final.Watcher != nil {
+			final.Watcher.Close()
+		}
+	}
 
 	return err
 }
+
+// startEngine sets up playing without a daemon, the returned func tears it down again
+func (model *Model) startEngine() func() {
+	// without an audio player around wired still works, it just can't be heard
+	var output playback.Output
+	device, err := playback.NewDeviceOutput()
+	if err != nil {
+		output = playback.NewNullOutput()
+		model.startupErrors = append(model.startupErrors, fmt.Errorf("no audio output available: %w", err))
+	} else {
+		output = device
+	}
+
+	// the radio hears everything the engine plays, it only goes on air once the config says so
+	model.Radio = radio.New()
+	model.Engine = engine.New(model.Radio.Output(output))
+	model.Player = model.Engine
+
+	model.Stats, err = stats.Record(model.Engine)
+	if err != nil {
+		model.startupErrors = append(model.startupErrors, fmt.Errorf("couldn't read the listening history: %w", err))
+	}
+
+	// lets wired ctl and friends drive the engine, the ui works fine without it
+	model.Control, err = control.Listen(model.Engine)
+	if err != nil {
+		model.startupErrors = append(model.startupErrors, fmt.Errorf("couldn't open the control socket: %w", err))
+	}
+
+	return func() {
+		if model.Control != nil {
+			model.Control.Close()
+		}
+
+		model.Engine.Close()
+		model.Radio.Close()
+	}
+}
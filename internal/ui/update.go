@@ -4,21 +4,35 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"slices"
+	"reflect"
+	"strconv"
 	"strings"
 	"time"
 
 	spinner "github.com/charmbracelet/bubbles/spinner"
 	bubbletea "github.com/charmbracelet/bubbletea"
 
+	config "wired/internal/config"
+	engine "wired/internal/engine"
 	library "wired/internal/library"
+	radio "wired/internal/radio"
+	scrobbler "wired/internal/scrobbler"
+	stats "wired/internal/stats"
+	artwork "wired/internal/ui/artwork"
+	browser "wired/internal/ui/browser"
+	commandline "wired/internal/ui/commandline"
 	dialog "wired/internal/ui/dialog"
 	footer "wired/internal/ui/footer"
 	header "wired/internal/ui/header"
 	modal "wired/internal/ui/modal"
 	notification "wired/internal/ui/notification"
+	playlist "wired/internal/ui/playlist"
+	search "wired/internal/ui/search"
 )
 
+// how far seek_forward and seek_backward jump
+const seekStep = 5 * time.Second
+
 func (model Model) Update(msg bubbletea.Msg) (bubbletea.Model, bubbletea.Cmd) {
 	switch msg := msg.(type) {
 	case bubbletea.WindowSizeMsg:
@@ -29,9 +43,18 @@ func (model Model) Update(msg bubbletea.Msg) (bubbletea.Model, bubbletea.Cmd) {
 
 		model.Dialog.SetSize(msg.Width, contentHeight)
 		model.Modal.SetSize(msg.Width, contentHeight)
+		model.Search.SetSize(msg.Width, contentHeight)
 		model.Header.SetWidth(msg.Width)
 		model.Footer.SetWidth(msg.Width)
+		model.CommandLine.SetWidth(msg.Width)
+		model.Browser.SetSize(model.browserSize(msg.Width, contentHeight))
+		model.Playlist.SetSize(msg.Width, contentHeight)
+		model.Statistics.SetSize(msg.Width, contentHeight)
+
+		return model, model.requestCover()
 
+	case artwork.RenderedMsg:
+		model.Artwork.Update(msg)
 		return model, nil
 
 	case footer.StartCompleteMsg:
@@ -58,11 +81,27 @@ func (model Model) Update(msg bubbletea.Msg) (bubbletea.Model, bubbletea.Cmd) {
 		}
 
 		model.Config = msg.Config
+		model.applyConfig()
+
+		for _, err := range model.startupErrors {
+			model.EnqueueNotification(
+				err.Error(),
+				notification.Error,
+				time.Second*time.Duration(model.Config.Notification.NotificationDurationSecs),
+			)
+		}
 
-		model.Modal.ApplyConfig(msg.Config)
-		model.Footer.ApplyConfig(msg.Config)
-		model.Notifications.ApplyConfig(msg.Config)
-		model.Header.ApplyConfig(msg.Config)
+		model.startupErrors = nil
+
+		var scrobblerCmd, radioCmd bubbletea.Cmd
+
+		// attached to a daemon, which runs all of these itself
+		if model.Engine != nil {
+			scrobblerCmd = model.startScrobbler()
+			radioCmd = model.startRadio()
+			model.startMPRIS()
+			model.startMPD()
+		}
 
 		if msg.MusicLibraryPathCleared {
 			model.EnqueueNotification(
@@ -72,7 +111,11 @@ func (model Model) Update(msg bubbletea.Msg) (bubbletea.Model, bubbletea.Cmd) {
 			)
 		}
 
-		cmds := []bubbletea.Cmd{heartbeatCmd()}
+		cmds := []bubbletea.Cmd{heartbeatCmd(), scrobblerCmd, radioCmd}
+
+		if model.ConfigWatcher == nil {
+			cmds = append(cmds, watchConfigCmd())
+		}
 
 		if model.Config.MusicLibraryPath == "" {
 			cmds = append(cmds, model.GetUserInput(modal.MusicPath, "Music library path:", "~/Music"))
@@ -91,7 +134,9 @@ func (model Model) Update(msg bubbletea.Msg) (bubbletea.Model, bubbletea.Cmd) {
 
 		if msg.Library != nil {
 			model.Library = msg.Library
-			// TODO: library has been loaded successfully, what now?
+			model.Browser.SetLibrary(model.Library)
+			model.refreshLibrary()
+			footerCmd = bubbletea.Batch(footerCmd, model.requestCover())
 		} else {
 			model.EnqueueNotification(
 				"your library is empty, you should try scanning for files~",
@@ -100,14 +145,203 @@ func (model Model) Update(msg bubbletea.Msg) (bubbletea.Model, bubbletea.Cmd) {
 			)
 		}
 
+		// a daemon watches the library itself, two watchers would both be writing the cache
+		if model.Config.WatchLibrary && model.Watcher == nil && model.Engine != nil {
+			return model, bubbletea.Batch(
+				footerCmd,
+				watchLibraryCmd(model.Config.MusicLibraryPath, model.scanOptions()),
+			)
+		}
+
 		return model, footerCmd
 
+	case ConfigWatcherStartMsg:
+		if msg.Error != nil {
+			model.EnqueueNotification(
+				"couldn't watch the config for changes: "+msg.Error.Error(),
+				notification.Error,
+				time.Second*time.Duration(model.Config.Notification.NotificationDurationSecs),
+			)
+
+			return model, nil
+		}
+
+		model.ConfigWatcher = msg.Watcher
+
+		return model, waitForConfigEvent(model.ConfigWatcher)
+
+	case ConfigReloadMsg:
+		if len(msg.Event.Errors) > 0 {
+			model.EnqueueNotification(
+				"config not reloaded, still using the previous one:\n"+formatErrors(msg.Event.Errors),
+				notification.Error,
+				time.Second*time.Duration(model.Config.Notification.NotificationDurationSecs),
+			)
+
+			return model, waitForConfigEvent(model.ConfigWatcher)
+		}
+
+		// wired saving the config itself comes through here as well
+		if reflect.DeepEqual(msg.Event.Config, model.Config) {
+			return model, waitForConfigEvent(model.ConfigWatcher)
+		}
+
+		// the library and playback carry on untouched, services like the radio or
+		// scrobbling keep the settings they were started with until the next start
+		model.Config = msg.Event.Config
+		model.applyConfig()
+		model.resetSequence()
+
+		model.EnqueueNotification(
+			"config reloaded",
+			notification.Success,
+			time.Second*time.Duration(model.Config.Notification.NotificationDurationSecs),
+		)
+
+		return model, waitForConfigEvent(model.ConfigWatcher)
+
+	case WatcherStartMsg:
+		if msg.Error != nil {
+			model.EnqueueNotification(
+				"couldn't watch the library for changes: "+msg.Error.Error(),
+				notification.Error,
+				time.Second*time.Duration(model.Config.Notification.NotificationDurationSecs),
+			)
+
+			return model, nil
+		}
+
+		model.Watcher = msg.Watcher
+
+		return model, waitForWatchEvent(model.Watcher)
+
+	case LibraryWatchMsg:
+		if msg.Event.Error != nil {
+			model.EnqueueNotification(
+				"library watcher: "+msg.Event.Error.Error(),
+				notification.Error,
+				time.Second*time.Duration(model.Config.Notification.NotificationDurationSecs),
+			)
+
+			return model, waitForWatchEvent(model.Watcher)
+		}
+
+		// the scan is still reading the current library, so it can't be touched yet
+		if model.FileScanState != nil {
+			model.watchBacklog = append(model.watchBacklog, msg.Event.Changes...)
+			return model, waitForWatchEvent(model.Watcher)
+		}
+
+		model.applyLibraryChanges(msg.Event.Changes)
+
+		return model, bubbletea.Batch(waitForWatchEvent(model.Watcher), model.requestCover())
+
+	case EngineEventMsg:
+		switch msg.Event.Type {
+		case engine.TrackFailed:
+			model.EnqueueNotification(
+				fmt.Sprintf("couldn't play %s: %s", msg.Event.Track.Title, msg.Event.Error),
+				notification.Error,
+				time.Second*time.Duration(model.Config.Notification.NotificationDurationSecs),
+			)
+
+		case engine.QueueChanged, engine.StateChanged, engine.TrackStarted:
+			status := model.Player.Status()
+
+			model.Playlist.SetQueue(model.Player.Queue())
+			model.Playlist.SetState(status.State)
+			model.Playlist.SetStreamTitle(status.Track.StreamTitle)
+
+		case engine.MetadataChanged:
+			model.Playlist.SetStreamTitle(msg.Event.Track.StreamTitle)
+		}
+
+		return model, waitForEngineEvent(model.engineEvents)
+
+	case EngineGoneMsg:
+		model.Dialog.Show(dialog.Options{
+			Header: "Daemon Gone",
+			Body:   "lost the connection to the wired daemon, it was stopped or crashed",
+			Footer: "ctrl+c to quit",
+		})
+
+		return model, model.Footer.SetState(footer.Error)
+
+	case StatsUpdateMsg:
+		if msg.Update.Error != nil && model.Config != nil {
+			model.EnqueueNotification(
+				"couldn't save the listening history: "+msg.Update.Error.Error(),
+				notification.Error,
+				time.Second*time.Duration(model.Config.Notification.NotificationDurationSecs),
+			)
+		}
+
+		model.Statistics.SetReport(model.Stats.Report(time.Now()))
+
+		return model, waitForStatsUpdate(model.Stats)
+
+	case RadioUpdateMsg:
+		model.Footer.SetRadio(model.Radio.URL(), msg.Update.Listeners)
+
+		return model, waitForRadioUpdate(model.Radio)
+
+	case ScrobbleReportMsg:
+		model.EnqueueNotification(
+			fmt.Sprintf("%s: %s", msg.Report.Target, msg.Report.Error),
+			notification.Error,
+			time.Second*time.Duration(model.Config.Notification.NotificationDurationSecs),
+		)
+
+		return model, waitForScrobbleReport(model.Scrobbler)
+
+	case browser.QueueMsg:
+		model.queueSongs(msg.Songs, msg.Next)
+		return model, nil
+
+	case search.QueueMsg:
+		model.queueSongs([]*library.Song{msg.Song}, msg.Next)
+		return model, nil
+
+	case commandline.SubmitMsg:
+		cmd := model.execute(msg.Line)
+		return model, cmd
+
+	case commandline.CompleteMsg:
+		model.CommandLine.SetCompletions(model.complete(msg.Line))
+		return model, nil
+
+	case search.JumpMsg:
+		model.Header.SetActive(header.Library)
+		model.Browser.Reveal(msg.Song)
+
+		return model, model.requestCover()
+
+	case browser.PlayMsg:
+		model.reportEngineError(model.Player.PlayNow(model.tracksFromSongs([]*library.Song{msg.Song})...))
+		return model, nil
+
+	case playlist.PlayMsg:
+		model.reportEngineError(model.Player.PlayIndex(msg.Index))
+		return model, nil
+
+	case playlist.MoveMsg:
+		model.reportEngineError(model.Player.Move(msg.From, msg.To))
+		return model, nil
+
+	case playlist.RemoveMsg:
+		model.reportEngineError(model.Player.Remove(msg.Index))
+		return model, nil
+
+	case playlist.ClearMsg:
+		model.reportEngineError(model.Player.Clear())
+		return model, nil
+
 	case HeartbeatMsg:
 		model.Notifications.Prune()
 		return model, heartbeatCmd()
 
 	case ScanStartMsg:
-		if msg.Total == 0 {
+		if msg.ProgressChannel == nil {
 			model.FileScanState = nil
 			footerCmd := model.Footer.SetState(footer.Idle)
 
@@ -123,13 +357,13 @@ func (model Model) Update(msg bubbletea.Msg) (bubbletea.Model, bubbletea.Cmd) {
 		model.FileScanState.Total = msg.Total
 		model.FileScanState.ProgressChannel = msg.ProgressChannel
 		model.FileScanState.ResultChannel = msg.ResultChannel
-		model.Footer.SetScanState(0, model.FileScanState.Total)
+		model.Footer.SetScanState(0, 0, 0)
 
 		return model, waitForScanProgress(msg.ProgressChannel, msg.ResultChannel)
 
 	case ScanProgressMsg:
-		model.FileScanState.Current = msg.Current
-		model.Footer.SetScanState(msg.Current, model.FileScanState.Total)
+		model.FileScanState.Progress = msg.Progress
+		model.Footer.SetScanState(msg.Progress.Added, msg.Progress.Updated, msg.Progress.Removed)
 
 		return model, waitForScanProgress(model.FileScanState.ProgressChannel, model.FileScanState.ResultChannel)
 
@@ -137,7 +371,12 @@ func (model Model) Update(msg bubbletea.Msg) (bubbletea.Model, bubbletea.Cmd) {
 		model.FileScanState = nil
 		model.Footer.SetState(footer.Idle)
 
+		watchBacklog := model.watchBacklog
+		model.watchBacklog = nil
+
 		if errors.Is(msg.Error, context.Canceled) {
+			model.applyLibraryChanges(watchBacklog)
+
 			model.EnqueueNotification(
 				"library scan has been canceled",
 				notification.Info,
@@ -148,6 +387,8 @@ func (model Model) Update(msg bubbletea.Msg) (bubbletea.Model, bubbletea.Cmd) {
 		}
 
 		if msg.Error != nil {
+			model.applyLibraryChanges(watchBacklog)
+
 			model.EnqueueNotification(
 				msg.Error.Error(),
 				notification.Error,
@@ -158,22 +399,23 @@ func (model Model) Update(msg bubbletea.Msg) (bubbletea.Model, bubbletea.Cmd) {
 		}
 
 		model.Library = msg.Library
-
-		if err := model.Library.SaveCache(); err != nil {
-			model.EnqueueNotification(
-				"failed to save library cache: "+err.Error(),
-				notification.Error,
-				time.Second*time.Duration(model.Config.Notification.NotificationDurationSecs),
-			)
-		}
+		model.Library.ApplyChanges(watchBacklog, model.scanOptions())
+		model.Browser.SetLibrary(model.Library)
+		model.refreshLibrary()
+		model.saveLibraryCache()
 
 		model.EnqueueNotification(
-			"library has been scanned successfully",
+			fmt.Sprintf(
+				"library has been scanned successfully: %d added, %d updated, %d removed",
+				msg.Progress.Added,
+				msg.Progress.Updated,
+				msg.Progress.Removed,
+			),
 			notification.Success,
 			time.Second*time.Duration(model.Config.Notification.NotificationDurationSecs),
 		)
 
-		return model, nil
+		return model, model.requestCover()
 
 	case spinner.TickMsg:
 		cmd := model.Footer.Update(msg)
@@ -207,6 +449,22 @@ func (model Model) Update(msg bubbletea.Msg) (bubbletea.Model, bubbletea.Cmd) {
 			cmds = append(cmds, loadLibraryCmd)
 
 			return model, bubbletea.Batch(cmds...)
+
+		case modal.StreamURL:
+			footerCmd := model.Footer.SetState(footer.Idle)
+
+			track, ok := model.streamTrack(msg.Value)
+			if !ok {
+				model.EnqueueNotification(
+					fmt.Sprintf("%q is neither a station nor an http(s) url", msg.Value),
+					notification.Error,
+					time.Second*time.Duration(model.Config.Notification.NotificationDurationSecs),
+				)
+
+				return model, footerCmd
+			}
+
+			return model, bubbletea.Batch(footerCmd, playStreamCmd(model.Player, track))
 		}
 
 		footerCmd := model.Footer.SetState(footer.Idle)
@@ -218,11 +476,17 @@ func (model Model) Update(msg bubbletea.Msg) (bubbletea.Model, bubbletea.Cmd) {
 			return model, bubbletea.Quit
 		}
 
+		footerCmd := model.Footer.SetState(footer.Idle)
+		return model, footerCmd
+
+	case StreamOpenedMsg:
+		model.reportEngineError(msg.Error)
 		return model, nil
 
 	case bubbletea.KeyMsg:
 		if model.Dialog.Visible() {
-			if msg.String() == "ctrl+c" {
+			// ctrl+c always works, the dialog might be about the config the other keys are in
+			if msg.String() == "ctrl+c" || model.Config != nil && model.Config.Keybinds.Action(config.DialogKeybinds, msg.String()) == "quit" {
 				return model, bubbletea.Quit
 			}
 
@@ -237,78 +501,183 @@ func (model Model) Update(msg bubbletea.Msg) (bubbletea.Model, bubbletea.Cmd) {
 			}
 		}
 
-		messageStr := msg.String()
+		if model.Search.Visible() {
+			cmd := model.Search.Update(msg)
+			return model, cmd
+		}
+
+		if model.CommandLine.Visible() {
+			cmd := model.CommandLine.Update(msg)
+			return model, cmd
+		}
 
 		if model.Config == nil {
-			if messageStr == "ctrl+c" {
+			if msg.String() == "ctrl+c" {
 				return model, bubbletea.Quit
 			}
 
 			return model, nil
 		}
 
-		keybinds := model.Config.Keybinds
+		cmd := model.pressKey(msg)
+		return model, cmd
 
-		if slices.Contains(keybinds.Quit, messageStr) {
-			return model, bubbletea.Quit
+	case KeySequenceTimeoutMsg:
+		if model.Config != nil {
+			cmd := model.timeoutSequence(msg.ID)
+			return model, cmd
 		}
 
-		if slices.Contains(keybinds.ScanFiles, messageStr) {
-			if model.FileScanState != nil {
-				model.FileScanState.CancelContext()
-				return model, nil
-			}
+	default:
+		if model.Modal.Visible() && model.Config != nil {
+			cmd := model.Modal.Update(msg)
+			return model, cmd
+		}
 
-			if model.Footer.State() == footer.LibraryLoading {
-				model.EnqueueNotification(
-					"library scan can't run while the library is loading",
-					notification.Info,
-					time.Second*time.Duration(model.Config.Notification.NotificationDurationSecs),
-				)
+		if model.Search.Visible() {
+			cmd := model.Search.Update(msg)
+			return model, cmd
+		}
 
-				return model, nil
-			}
+		if model.CommandLine.Visible() {
+			cmd := model.CommandLine.Update(msg)
+			return model, cmd
+		}
+	}
 
-			if model.Config.MusicLibraryPath == "" {
-				model.EnqueueNotification(
-					"library scan can't run while the library path is invalid",
-					notification.Info,
-					time.Second*time.Duration(model.Config.Notification.NotificationDurationSecs),
-				)
+	return model, nil
+}
 
-				return model, nil
-			}
+// globalCommands is what the global keybinds run, the panels handle the rest themselves
+var globalCommands = map[string]string{
+	"quit":            "quit",
+	"scan_files":      "scan",
+	"view_library":    "view library",
+	"view_playlist":   "view playlist",
+	"view_statistics": "view statistics",
+	"toggle_pause":    "toggle",
+	"next_track":      "next",
+	"previous_track":  "previous",
+	"seek_forward":    "seek +" + strconv.Itoa(int(seekStep.Seconds())),
+	"seek_backward":   "seek -" + strconv.Itoa(int(seekStep.Seconds())),
+	"search":          "search",
+	"open_stream":     "open",
+}
 
-			ctx, cancel := context.WithCancel(context.Background())
-			model.FileScanState = &FileScanningState{CancelContext: cancel}
-			footerCmd := model.Footer.SetState(footer.LibraryScanning)
+// scan starts a library scan, or cancels the one running
+func (model *Model) scan() bubbletea.Cmd {
+	if model.FileScanState != nil {
+		model.FileScanState.CancelContext()
+		return nil
+	}
 
-			return model, bubbletea.Batch(footerCmd, scanLibraryCmd(ctx, model.Config.MusicLibraryPath))
-		}
+	if model.Footer.State() == footer.LibraryLoading {
+		model.EnqueueNotification(
+			"library scan can't run while the library is loading",
+			notification.Info,
+			time.Second*time.Duration(model.Config.Notification.NotificationDurationSecs),
+		)
 
-		if slices.Contains(keybinds.ViewLibrary, messageStr) {
-			model.Header.SetActive(header.Library)
-			return model, nil
-		}
+		return nil
+	}
 
-		if slices.Contains(keybinds.ViewPlaylist, messageStr) {
-			model.Header.SetActive(header.Playlist)
-			return model, nil
-		}
+	if model.Config.MusicLibraryPath == "" {
+		model.EnqueueNotification(
+			"library scan can't run while the library path is invalid",
+			notification.Info,
+			time.Second*time.Duration(model.Config.Notification.NotificationDurationSecs),
+		)
 
-		if slices.Contains(keybinds.ViewStatistics, messageStr) {
-			model.Header.SetActive(header.Statistics)
-			return model, nil
-		}
+		return nil
+	}
 
-	default:
-		if model.Modal.Visible() && model.Config != nil {
-			cmd := model.Modal.Update(msg)
-			return model, cmd
-		}
+	ctx, cancel := context.WithCancel(context.Background())
+	model.FileScanState = &FileScanningState{CancelContext: cancel}
+	footerCmd := model.Footer.SetState(footer.LibraryScanning)
+
+	return bubbletea.Batch(
+		footerCmd,
+		scanLibraryCmd(ctx, model.Config.MusicLibraryPath, model.Library, model.scanOptions()),
+	)
+}
+
+// saveLibraryCache writes the library for the next start, unless attached to a daemon. it owns
+// the cache and keeps it up to date itself, a scan here only refreshes what this ui shows
+func (model *Model) saveLibraryCache() {
+	if model.Engine == nil {
+		return
 	}
 
-	return model, nil
+	if err := model.Library.SaveCache(); err != nil {
+		model.EnqueueNotification(
+			"failed to save library cache: "+err.Error(),
+			notification.Error,
+			time.Second*time.Duration(model.Config.Notification.NotificationDurationSecs),
+		)
+	}
+}
+
+// applyLibraryChanges folds watcher changes into the library and the cache
+func (model *Model) applyLibraryChanges(changes []library.Change) {
+	if len(changes) == 0 {
+		return
+	}
+
+	if model.Library == nil {
+		model.Library = library.New()
+	}
+
+	progress := model.Library.ApplyChanges(changes, model.scanOptions())
+	model.Browser.SetLibrary(model.Library)
+	model.refreshLibrary()
+	model.saveLibraryCache()
+
+	model.EnqueueNotification(
+		fmt.Sprintf(
+			"library changed: %d added, %d updated, %d removed",
+			progress.Added,
+			progress.Updated,
+			progress.Removed,
+		),
+		notification.Info,
+		time.Second*time.Duration(model.Config.Notification.NotificationDurationSecs),
+	)
+}
+
+// queueSongs appends the songs to the queue, or puts them right after the current track
+func (model *Model) queueSongs(songs []*library.Song, next bool) {
+	tracks := model.tracksFromSongs(songs)
+
+	var err error
+	if next {
+		err = model.Player.InsertNext(tracks...)
+	} else {
+		err = model.Player.Append(tracks...)
+	}
+
+	if err != nil {
+		model.reportEngineError(err)
+		return
+	}
+
+	model.EnqueueNotification(
+		fmt.Sprintf("%d songs added to the queue", len(tracks)),
+		notification.Success,
+		time.Second*time.Duration(model.Config.Notification.NotificationDurationSecs),
+	)
+}
+
+// reportEngineError turns a failed queue or playback operation into a notification
+func (model *Model) reportEngineError(err error) {
+	if err == nil {
+		return
+	}
+
+	model.EnqueueNotification(
+		err.Error(),
+		notification.Error,
+		time.Second*time.Duration(model.Config.Notification.NotificationDurationSecs),
+	)
 }
 
 // TODO: observe if 100ms heartbeat is good or not
@@ -324,19 +693,28 @@ func heartbeatCmd() bubbletea.Cmd {
 	})
 }
 
-func waitForScanProgress(progressChannel <-chan int, resultChannel <-chan library.FileScanningResult) bubbletea.Cmd {
+func waitForScanProgress(
+	progressChannel <-chan library.ScanProgress,
+	resultChannel <-chan library.FileScanningResult,
+) bubbletea.Cmd {
 	return func() bubbletea.Msg {
 		val, ok := <-progressChannel
 		if !ok {
 			result := <-resultChannel
-			return ScanCompleteMsg{Library: result.Library, Error: result.Error}
+			return ScanCompleteMsg{Library: result.Library, Progress: result.Progress, Error: result.Error}
 		}
 
-		return ScanProgressMsg{Current: val}
+		return ScanProgressMsg{Progress: val}
 	}
 }
 
-func scanLibraryCmd(ctx context.Context, libraryPath string) bubbletea.Cmd {
+// previous is the library currently in memory, unchanged files are carried over from it
+func scanLibraryCmd(
+	ctx context.Context,
+	libraryPath string,
+	previous *library.Library,
+	options library.ScanOptions,
+) bubbletea.Cmd {
 	return func() bubbletea.Msg {
 		// TODO: although CountFiles is fast, it could take some seconds in an old pc
 		// there's no visual feedback when this is happening, might be nice to add
@@ -345,17 +723,18 @@ func scanLibraryCmd(ctx context.Context, libraryPath string) bubbletea.Cmd {
 			return ScanCompleteMsg{Error: err}
 		}
 
-		if total == 0 {
+		// with songs cached there's still something to scan, they've all been removed
+		if total == 0 && (previous == nil || len(previous.Songs) == 0) {
 			return ScanStartMsg{Total: 0}
 		}
 
-		progressChannel := make(chan int)
+		progressChannel := make(chan library.ScanProgress)
 		resultChannel := make(chan library.FileScanningResult, 1)
 
 		go func() {
-			lib, err := library.Scan(ctx, libraryPath, progressChannel)
+			lib, progress, err := library.Scan(ctx, libraryPath, previous, options, progressChannel)
 			close(progressChannel)
-			resultChannel <- library.FileScanningResult{Library: lib, Error: err}
+			resultChannel <- library.FileScanningResult{Library: lib, Progress: progress, Error: err}
 		}()
 
 		return ScanStartMsg{
@@ -366,6 +745,69 @@ func scanLibraryCmd(ctx context.Context, libraryPath string) bubbletea.Cmd {
 	}
 }
 
+func watchConfigCmd() bubbletea.Cmd {
+	return func() bubbletea.Msg {
+		watcher, err := config.Watch()
+		return ConfigWatcherStartMsg{Watcher: watcher, Error: err}
+	}
+}
+
+func waitForConfigEvent(watcher *config.Watcher) bubbletea.Cmd {
+	return func() bubbletea.Msg {
+		return ConfigReloadMsg{Event: <-watcher.Events()}
+	}
+}
+
+func watchLibraryCmd(libraryPath string, options library.ScanOptions) bubbletea.Cmd {
+	return func() bubbletea.Msg {
+		watcher, err := library.Watch(libraryPath, options)
+		return WatcherStartMsg{Watcher: watcher, Error: err}
+	}
+}
+
+func waitForWatchEvent(watcher *library.Watcher) bubbletea.Cmd {
+	return func() bubbletea.Msg {
+		return LibraryWatchMsg{Event: <-watcher.Events()}
+	}
+}
+
+func waitForEngineEvent(events <-chan engine.Event) bubbletea.Cmd {
+	return func() bubbletea.Msg {
+		event, ok := <-events
+		if !ok {
+			return EngineGoneMsg{}
+		}
+
+		return EngineEventMsg{Event: event}
+	}
+}
+
+func waitForStatsUpdate(recorder *stats.Recorder) bubbletea.Cmd {
+	return func() bubbletea.Msg {
+		return StatsUpdateMsg{Update: <-recorder.Updates()}
+	}
+}
+
+// connecting to a stream can take a while, PlayNow only returns once it's connected so it waits
+// off the update loop. the engine doesn't hold its lock meanwhile, status and the rest still answer
+func playStreamCmd(player player, track engine.Track) bubbletea.Cmd {
+	return func() bubbletea.Msg {
+		return StreamOpenedMsg{Error: player.PlayNow(track)}
+	}
+}
+
+func waitForRadioUpdate(station *radio.Station) bubbletea.Cmd {
+	return func() bubbletea.Msg {
+		return RadioUpdateMsg{Update: <-station.Updates()}
+	}
+}
+
+func waitForScrobbleReport(scrobbler *scrobbler.Scrobbler) bubbletea.Cmd {
+	return func() bubbletea.Msg {
+		return ScrobbleReportMsg{Report: <-scrobbler.Reports()}
+	}
+}
+
 func formatErrors(errs []error) string {
 	if len(errs) == 1 {
 		return errs[0].Error()
@@ -22,8 +22,10 @@ func (model Model) View() string {
 		base = ""
 	} else if model.Modal.Visible() {
 		base = model.Modal.View()
+	} else if model.Search.Visible() {
+		base = model.Search.View()
 	} else {
-		base = model.viewForActivePanel()
+		base = model.viewForActivePanel(model.width, contentHeight)
 	}
 
 	// Pad base to exactly contentHeight lines
@@ -47,22 +49,45 @@ func (model Model) View() string {
 		}
 	}
 
-	return model.Header.View() + "\n" + base + "\n" + model.Footer.View()
+	footer := model.Footer.View()
+	if model.CommandLine.Visible() {
+		footer = model.CommandLine.View()
+	}
+
+	return model.Header.View() + "\n" + base + "\n" + footer
 }
 
-func (model Model) viewForActivePanel() string {
+func (model Model) viewForActivePanel(width int, height int) string {
 	switch model.Header.Active() {
 	case header.Library:
-		return "Library..."
+		return model.viewLibrary(width, height)
 	case header.Playlist:
-		return "Playlist..."
+		return model.Playlist.View()
 	case header.Statistics:
-		return "Statistics..."
+		return model.Statistics.View()
 	default:
 		return "OwO Undefined"
 	}
 }
 
+func (model Model) viewLibrary(width int, height int) string {
+	content := model.Browser.View()
+
+	album := model.Browser.Album()
+	if album == nil {
+		return content
+	}
+
+	cols, rows := model.coverSize(width, height)
+
+	picture, ok := model.Artwork.Get(album.CoverImage, cols, rows)
+	if !ok {
+		return content
+	}
+
+	return lipgloss.JoinHorizontal(lipgloss.Top, content, " ", picture.View(content))
+}
+
 func (model Model) renderNotifications(notifications []notification.Notification) string {
 	bubbles := make([]string, 0, len(notifications))
 
@@ -2,12 +2,28 @@ package main
 
 import (
 	"log"
+	"os"
 
+	"wired/internal/cli"
+	"wired/internal/daemon"
 	"wired/internal/ui"
 )
 
 func main() {
-	if err := ui.Start(); err != nil {
+	var err error
+
+	switch {
+	case len(os.Args) > 1 && os.Args[1] == "auth":
+		err = cli.Auth(os.Args[2:])
+	case len(os.Args) > 1 && os.Args[1] == "ctl":
+		err = cli.Ctl(os.Args[2:])
+	case len(os.Args) > 1 && os.Args[1] == "--daemon":
+		err = daemon.Run()
+	default:
+		err = ui.Start()
+	}
+
+	if err != nil {
 		log.Fatal(err)
 	}
 }